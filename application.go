@@ -0,0 +1,533 @@
+package owl
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/kardianos/service"
+	"os"
+	"owl/contract"
+	"owl/contract/foundation"
+	"owl/log"
+	"path"
+	"path/filepath"
+	"reflect"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 内置的引导步骤，BootstrapWith 按顺序执行
+const (
+	BootstrapRegisterProviders = "register-providers" // 注册配置的服务提供者
+	BootstrapBootProviders     = "boot-providers"     // 启动服务提供者
+)
+
+var DefaultBootstrappers = []string{BootstrapRegisterProviders, BootstrapBootProviders}
+
+var (
+	ProviderNotFoundError     = errors.New("服务提供者未注册")
+	BootstrapperNotFoundError = errors.New("引导步骤不存在")
+	NamespaceNotFoundError    = errors.New("无法获取应用命名空间")
+)
+
+var _ foundation.Application = (*Application)(nil)
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// ApplicationOptions conf/app.yml
+type ApplicationOptions struct {
+	Version   string   `json:"version"`   // 应用版本号
+	Env       string   `json:"env"`       // 运行环境，环境变量 APP_ENV 优先，和 ConfManager 选择的环境配置一致
+	Locale    string   `json:"locale"`    // 区域设置
+	Providers []string `json:"providers"` // 启动时注册的服务提供者，为空时注册所有 AddProvider 添加的提供者
+}
+
+func NewApplicationOption(cfgManager *ConfManager, cfgFile string) (opt *ApplicationOptions) {
+	err := cfgManager.GetConfig(cfgFile, &opt)
+	if err != nil {
+		return nil
+	}
+	return opt
+}
+
+func DefaultApplicationOptions() *ApplicationOptions {
+	return &ApplicationOptions{
+		Version: "dev",
+		Env:     "production",
+		Locale:  "zh-CN",
+	}
+}
+
+// Application 基于 Stage 的应用生命周期：注册服务提供者 -> 启动 -> 终止
+// 服务提供者通过 AddProvider 以名称登记构造函数，构造函数的参数从 dig 容器中获取，
+// 实现了 contract.DeferrableProvider 的提供者交给 Stage 延迟加载，和 Stage.Register 使用同一个登记表
+type Application struct {
+	*Stage
+	lock sync.Mutex
+
+	version        string
+	env            string
+	locale         string
+	skipMiddleware bool
+	configured     []string // 配置中需要注册的服务提供者
+
+	constructors  map[string]any                      // 名称 -> 构造函数
+	order         []string                            // AddProvider 的顺序
+	providers     map[string]contract.ServiceProvider // 已注册的服务提供者，包括交给 Stage 延迟加载的
+	registered    []string                            // 注册顺序，也是启动顺序，不包括延迟的提供者
+	registerTimes map[string]time.Duration            // Register 耗时
+	bootstrappers map[string]func(app *Application)
+
+	booted       bool
+	bootstrapped bool
+
+	bootingCallbacks     []func()
+	bootedCallbacks      []func()
+	terminatingCallbacks []func() error
+
+	maintenance foundation.MaintenanceMode
+}
+
+// NewApplication 创建应用并放入 dig 容器，opt 为 nil 时使用默认配置
+func NewApplication(stage *Stage, opt *ApplicationOptions) *Application {
+	if opt == nil {
+		opt = DefaultApplicationOptions()
+	}
+	defaults := DefaultApplicationOptions()
+
+	i := &Application{
+		Stage:         stage,
+		version:       stringOr(opt.Version, defaults.Version),
+		env:           stringOr(stageEnvironment(stage), stringOr(opt.Env, defaults.Env)),
+		locale:        stringOr(opt.Locale, defaults.Locale),
+		configured:    opt.Providers,
+		constructors:  make(map[string]any),
+		providers:     make(map[string]contract.ServiceProvider),
+		registerTimes: make(map[string]time.Duration),
+		bootstrappers: make(map[string]func(app *Application)),
+		maintenance:   NewFileMaintenanceMode(filepath.Join(stage.StoragePath(), MaintenanceFile)),
+	}
+	i.AddBootstrapper(BootstrapRegisterProviders, (*Application).RegisterConfiguredProviders)
+	i.AddBootstrapper(BootstrapBootProviders, (*Application).Boot)
+
+	// 应用最先添加到 Lifecycle，所有服务停止之后执行终止回调
+	_ = stage.Lifecycle().OnStop("application", func(ctx context.Context) error {
+		i.Terminate()
+		return nil
+	})
+
+	_ = stage.Provide(func() *Application { return i })
+	_ = stage.Provide(func() foundation.Application { return i })
+	return i
+}
+
+// stageEnvironment 容器中有 ConfManager 时使用它选择的环境，否则读取环境变量 APP_ENV
+func stageEnvironment(stage *Stage) string {
+	env := os.Getenv(AppEnvKey)
+	_ = stage.Container.Invoke(func(cfgManager *ConfManager) {
+		env = cfgManager.Environment()
+	})
+	return env
+}
+
+func (i *Application) Version() string {
+	return i.version
+}
+
+func (i *Application) BasePath(path string) string {
+	return joinPath(i.AbsRunDir(), path)
+}
+
+func (i *Application) BootstrapPath(path string) string {
+	return joinPath(i.RuntimePath("bootstrap"), path)
+}
+
+func (i *Application) ConfigPath(path string) string {
+	return joinPath(i.Stage.ConfigPath(), path)
+}
+
+func (i *Application) DatabasePath(path string) string {
+	return joinPath(i.RuntimePath("database"), path)
+}
+
+func (i *Application) ResourcePath(path string) string {
+	return joinPath(i.Stage.ResourcePath(), path)
+}
+
+func (i *Application) StoragePath(path string) string {
+	return joinPath(i.Stage.StoragePath(), path)
+}
+
+// Environment 返回当前环境，传入 environments 时同时返回是否匹配其中之一，支持 path.Match 通配符
+func (i *Application) Environment(environments ...string) (string, bool) {
+	for _, pattern := range environments {
+		if matched, _ := path.Match(pattern, i.env); matched {
+			return i.env, true
+		}
+	}
+	return i.env, false
+}
+
+// RunningInConsole 不是由系统服务管理器启动时返回 true
+func (i *Application) RunningInConsole() bool {
+	return service.Interactive()
+}
+
+func (i *Application) RunningUnitTests() bool {
+	return i.env == "testing" || flag.Lookup("test.v") != nil
+}
+
+func (i *Application) MaintenanceMode() foundation.MaintenanceMode {
+	return i.maintenance
+}
+
+func (i *Application) IsDownForMaintenance() bool {
+	return i.maintenance.Active()
+}
+
+// AddProvider 登记服务提供者，constructor 可以是服务提供者实例，
+// 也可以是返回 (contract.ServiceProvider) 或 (contract.ServiceProvider, error) 的函数，函数参数从容器中获取
+// 实现了 contract.DeferrableProvider 的提供者在 Provides 中的类型第一次被 Invoke 时才注册和启动
+func (i *Application) AddProvider(name string, constructor any) *Application {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	if _, ok := i.constructors[name]; !ok {
+		i.order = append(i.order, name)
+	}
+	i.constructors[name] = constructor
+	return i
+}
+
+// RegisterConfiguredProviders 注册 app.providers 中的服务提供者，未配置时注册所有登记过的提供者
+func (i *Application) RegisterConfiguredProviders() {
+	i.lock.Lock()
+	names := i.configured
+	if len(names) == 0 {
+		names = append([]string(nil), i.order...)
+	}
+	i.lock.Unlock()
+
+	for _, name := range names {
+		i.RegisterProvider(name, false)
+	}
+}
+
+// RegisterProvider 按名称注册服务提供者，已注册且 force 为 false 时直接返回已有实例
+// 延迟的提供者交给 Stage，force 为 true 时立即注册；应用已启动时立即启动
+// 服务提供者实例使用 Stage.Register 注册
+func (i *Application) RegisterProvider(provider string, force bool) contract.ServiceProvider {
+	i.lock.Lock()
+	if registered, ok := i.providers[provider]; ok && !force {
+		i.lock.Unlock()
+		return registered
+	}
+	i.lock.Unlock()
+
+	instance := i.ResolveProvider(provider)
+	if deferrable, ok := instance.(contract.DeferrableProvider); ok && !force {
+		i.lock.Lock()
+		i.providers[provider] = instance
+		i.lock.Unlock()
+		i.Stage.deferProvider(deferrable)
+		return instance
+	}
+
+	register := measure(instance.Register)
+
+	i.lock.Lock()
+	if !containsString(i.registered, provider) {
+		i.registered = append(i.registered, provider)
+	}
+	i.providers[provider] = instance
+	i.registerTimes[provider] = register
+	booted := i.booted
+	i.lock.Unlock()
+
+	if booted {
+		i.Stage.bootProvider(provider, instance, register, false)
+	}
+	return instance
+}
+
+// LoadDeferredProviders 注册并启动 Stage 中所有还没有加载的延迟服务提供者
+func (i *Application) LoadDeferredProviders() {
+	i.Stage.loadAllDeferred()
+}
+
+// ResolveProvider 使用登记的构造函数创建服务提供者，参数从容器中获取，失败时 panic
+func (i *Application) ResolveProvider(provider string) contract.ServiceProvider {
+	i.lock.Lock()
+	constructor, ok := i.constructors[provider]
+	i.lock.Unlock()
+
+	if !ok {
+		panic(fmt.Errorf("%w: %s", ProviderNotFoundError, provider))
+	}
+
+	instance, err := i.invokeProvider(constructor)
+	if err != nil {
+		panic(fmt.Errorf("创建服务提供者 [%s] 失败: %w", provider, err))
+	}
+	return instance
+}
+
+// invokeProvider 把构造函数包装成 dig.Invoke 接受的形式，参数由容器注入
+func (i *Application) invokeProvider(constructor any) (contract.ServiceProvider, error) {
+	if instance, ok := constructor.(contract.ServiceProvider); ok {
+		return instance, nil
+	}
+
+	fn := reflect.ValueOf(constructor)
+	if fn.Kind() != reflect.Func {
+		return nil, fmt.Errorf("不支持的构造函数类型 %T", constructor)
+	}
+	t := fn.Type()
+	if t.NumOut() == 0 || t.NumOut() > 2 || (t.NumOut() == 2 && t.Out(1) != errorType) {
+		return nil, fmt.Errorf("构造函数 %T 需要返回 (ServiceProvider) 或 (ServiceProvider, error)", constructor)
+	}
+
+	in := make([]reflect.Type, t.NumIn())
+	for n := range in {
+		in[n] = t.In(n)
+	}
+
+	var out []reflect.Value
+	wrapper := reflect.MakeFunc(reflect.FuncOf(in, []reflect.Type{errorType}, false), func(args []reflect.Value) []reflect.Value {
+		out = fn.Call(args)
+		if len(out) == 2 && !out[1].IsNil() {
+			return []reflect.Value{out[1]}
+		}
+		return []reflect.Value{reflect.Zero(errorType)}
+	})
+	if err := i.Invoke(wrapper.Interface()); err != nil {
+		return nil, err
+	}
+
+	instance, ok := out[0].Interface().(contract.ServiceProvider)
+	if !ok || instance == nil {
+		return nil, fmt.Errorf("构造函数 %T 没有返回 ServiceProvider", constructor)
+	}
+	return instance, nil
+}
+
+// Boot 按注册顺序启动服务提供者，启动前后分别执行 Booting Booted 回调
+func (i *Application) Boot() {
+	i.lock.Lock()
+	if i.booted {
+		i.lock.Unlock()
+		return
+	}
+	booting := i.bootingCallbacks
+	i.lock.Unlock()
+
+	for _, callback := range booting {
+		callback()
+	}
+
+	// 启动过程中注册的服务提供者也会被启动
+	for n := 0; ; n++ {
+		i.lock.Lock()
+		if n >= len(i.registered) {
+			i.booted = true
+			i.lock.Unlock()
+			break
+		}
+		name := i.registered[n]
+		instance := i.providers[name]
+		register := i.registerTimes[name]
+		i.lock.Unlock()
+
+		i.Stage.bootProvider(name, instance, register, false)
+	}
+
+	i.lock.Lock()
+	booted := i.bootedCallbacks
+	i.lock.Unlock()
+
+	for _, callback := range booted {
+		callback()
+	}
+}
+
+func (i *Application) Booting(callback func()) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	i.bootingCallbacks = append(i.bootingCallbacks, callback)
+}
+
+// Booted 注册启动完成回调，应用已启动时立即执行
+func (i *Application) Booted(callback func()) {
+	i.lock.Lock()
+	booted := i.booted
+	if !booted {
+		i.bootedCallbacks = append(i.bootedCallbacks, callback)
+	}
+	i.lock.Unlock()
+
+	if booted {
+		callback()
+	}
+}
+
+// AddBootstrapper 登记引导步骤，供 BootstrapWith 按名称调用
+func (i *Application) AddBootstrapper(name string, bootstrapper func(app *Application)) *Application {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	i.bootstrappers[name] = bootstrapper
+	return i
+}
+
+// BootstrapWith 按顺序执行引导步骤，名称不存在时 panic
+func (i *Application) BootstrapWith(bootstrappers []string) {
+	for _, name := range bootstrappers {
+		i.lock.Lock()
+		bootstrapper, ok := i.bootstrappers[name]
+		i.lock.Unlock()
+
+		if !ok {
+			panic(fmt.Errorf("%w: %s", BootstrapperNotFoundError, name))
+		}
+		bootstrapper(i)
+	}
+
+	i.lock.Lock()
+	i.bootstrapped = true
+	i.lock.Unlock()
+}
+
+// Bootstrap 使用默认的引导步骤：注册服务提供者，然后启动
+func (i *Application) Bootstrap() {
+	i.BootstrapWith(DefaultBootstrappers)
+}
+
+func (i *Application) GetLocale() string {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	return i.locale
+}
+
+func (i *Application) SetLocale(locale string) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	i.locale = locale
+}
+
+// GetNamespace 读取运行目录下 go.mod 中的模块名，没有 go.mod 时使用编译信息中的模块名
+func (i *Application) GetNamespace() (string, error) {
+	if f, err := os.Open(i.BasePath("go.mod")); err == nil {
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if strings.HasPrefix(line, "module ") {
+				return strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "module ")), `"`), nil
+			}
+		}
+	}
+
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Path != "" {
+		return info.Main.Path, nil
+	}
+	return "", NamespaceNotFoundError
+}
+
+// GetProviders 获取已注册的服务提供者，provider 可以是注册名称或类型名称，例如 *cache.Provider，为空时返回全部
+// 延迟的服务提供者加载之后才会返回
+func (i *Application) GetProviders(provider string) []contract.ServiceProvider {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	var providers []contract.ServiceProvider
+	for _, name := range i.order {
+		instance, ok := i.providers[name]
+		if !ok || (!containsString(i.registered, name) && i.Stage.isDeferred(instance)) {
+			continue
+		}
+		if provider == "" || provider == name || provider == reflect.TypeOf(instance).String() {
+			providers = append(providers, instance)
+		}
+	}
+	return providers
+}
+
+func (i *Application) HasBeenBootstrapped() bool {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	return i.bootstrapped
+}
+
+// SkipMiddleware 设置是否禁用中间件，一般用于测试
+func (i *Application) SkipMiddleware(skip bool) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	i.skipMiddleware = skip
+}
+
+func (i *Application) ShouldSkipMiddleware() bool {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	return i.skipMiddleware
+}
+
+// Terminating 注册终止回调，callback 为 func() 或 func() error，其他类型 panic
+func (i *Application) Terminating(callback interface{}) foundation.Application {
+	var fn func() error
+	switch c := callback.(type) {
+	case func():
+		fn = func() error {
+			c()
+			return nil
+		}
+	case func() error:
+		fn = c
+	default:
+		panic(fmt.Errorf("不支持的终止回调类型 %T", callback))
+	}
+
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	i.terminatingCallbacks = append(i.terminatingCallbacks, fn)
+	return i
+}
+
+// Terminate 按注册顺序执行终止回调，回调只执行一次，错误只记录日志
+func (i *Application) Terminate() {
+	i.lock.Lock()
+	callbacks := i.terminatingCallbacks
+	i.terminatingCallbacks = nil
+	i.lock.Unlock()
+
+	for _, callback := range callbacks {
+		if err := callback(); err != nil {
+			log.PrintLnRed("终止回调执行失败: ", err)
+		}
+	}
+}
+
+func joinPath(base, path string) string {
+	if path == "" {
+		return base
+	}
+	return base + "/" + strings.TrimPrefix(path, "/")
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func stringOr(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,166 @@
+package owl
+
+import (
+	"errors"
+	"go.uber.org/dig"
+	"os"
+	"reflect"
+	"testing"
+)
+
+type testProvider struct {
+	name   string
+	events *[]string
+}
+
+func (i *testProvider) Register() {
+	*i.events = append(*i.events, "register:"+i.name)
+}
+
+func (i *testProvider) Boot() {
+	*i.events = append(*i.events, "boot:"+i.name)
+}
+
+func newTestApplication(t *testing.T) *Application {
+	dir := t.TempDir()
+	stage := &Stage{Container: dig.New(), runDir: dir, binDir: dir}
+	return NewApplication(stage, &ApplicationOptions{Env: "testing"})
+}
+
+func TestApplicationBootstrapSequence(t *testing.T) {
+	app := newTestApplication(t)
+	var events []string
+
+	if err := app.Provide(func() *[]string { return &events }); err != nil {
+		t.Fatal(err)
+	}
+	app.AddProvider("first", func(events *[]string) *testProvider {
+		return &testProvider{name: "first", events: events}
+	})
+	app.AddProvider("second", func(events *[]string) (*testProvider, error) {
+		return &testProvider{name: "second", events: events}, nil
+	})
+	app.AddProvider("lazy", func(events *[]string) *lazyProvider {
+		return &lazyProvider{stage: app.Stage, events: events}
+	})
+
+	app.Booting(func() { events = append(events, "booting") })
+	app.Booted(func() { events = append(events, "booted") })
+	app.Bootstrap()
+
+	want := []string{"register:first", "register:second", "booting", "boot:first", "boot:second", "booted"}
+	if !reflect.DeepEqual(events, want) {
+		t.Fatalf("events = %v, want %v", events, want)
+	}
+	if !app.HasBeenBootstrapped() {
+		t.Fatal("application should be bootstrapped")
+	}
+
+	if got := len(app.GetProviders("")); got != 2 {
+		t.Fatalf("GetProviders before loading deferred = %d, want 2", got)
+	}
+
+	// 延迟的提供者和 Stage.Register 登记的一样，第一次获取提供的类型时注册并启动
+	for n := 0; n < 2; n++ {
+		if err := app.Invoke(func(*lazyService) {}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	app.LoadDeferredProviders()
+	want = append(want, "register:lazy", "boot:lazy")
+	if !reflect.DeepEqual(events, want) {
+		t.Fatalf("events = %v, want %v", events, want)
+	}
+	if got := len(app.GetProviders("*owl.testProvider")); got != 2 {
+		t.Fatalf("GetProviders = %d, want 2", got)
+	}
+	if got := len(app.GetProviders("lazy")); got != 1 {
+		t.Fatalf("GetProviders(lazy) = %d, want 1", got)
+	}
+
+	var resolved *Application
+	if err := app.Invoke(func(a *Application) { resolved = a }); err != nil || resolved != app {
+		t.Fatalf("application should be resolvable from the container: %v", err)
+	}
+}
+
+func TestApplicationResolveProviderErrors(t *testing.T) {
+	app := newTestApplication(t)
+	app.AddProvider("broken", func() (*testProvider, error) { return nil, errors.New("boom") })
+	app.AddProvider("invalid", "not a constructor")
+
+	for _, name := range []string{"missing", "broken", "invalid"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("resolving %s should panic", name)
+				}
+			}()
+			app.ResolveProvider(name)
+		}()
+	}
+}
+
+func TestApplicationTerminate(t *testing.T) {
+	app := newTestApplication(t)
+	var calls []int
+
+	app.Terminating(func() { calls = append(calls, 1) }).
+		Terminating(func() error { calls = append(calls, 2); return errors.New("ignored") })
+	app.Terminate()
+	app.Terminate()
+
+	if !reflect.DeepEqual(calls, []int{1, 2}) {
+		t.Fatalf("calls = %v", calls)
+	}
+}
+
+func TestApplicationEnvironmentFromConfManager(t *testing.T) {
+	t.Setenv(AppEnvKey, "staging")
+	dir := t.TempDir()
+	cfgManager := NewConfigManagerFromDir(dir, nil)
+	stage := &Stage{Container: dig.New(), runDir: dir, binDir: dir}
+	if err := stage.Provide(func() *ConfManager { return cfgManager }); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv(AppEnvKey, "other")
+	app := NewApplication(stage, &ApplicationOptions{Env: "production"})
+	if env, _ := app.Environment(); env != "staging" {
+		t.Fatalf("Environment = %s, want the ConfManager environment staging", env)
+	}
+}
+
+func TestApplicationEnvironmentAndMaintenance(t *testing.T) {
+	app := newTestApplication(t)
+
+	if env, ok := app.Environment("local", "test*"); env != "testing" || !ok {
+		t.Fatalf("Environment = %s %v", env, ok)
+	}
+	if _, ok := app.Environment("production"); ok {
+		t.Fatal("production should not match")
+	}
+	if !app.RunningUnitTests() {
+		t.Fatal("RunningUnitTests should be true")
+	}
+
+	if app.IsDownForMaintenance() {
+		t.Fatal("application should be up")
+	}
+	if err := app.MaintenanceMode().Activate(map[string]any{"retry": 60}); err != nil {
+		t.Fatal(err)
+	}
+	if !app.IsDownForMaintenance() {
+		t.Fatal("application should be down")
+	}
+	if _, err := os.Stat(app.StoragePath(MaintenanceFile)); err != nil {
+		t.Fatal(err)
+	}
+	data, err := app.MaintenanceMode().Data()
+	if err != nil || data["retry"] != float64(60) {
+		t.Fatalf("Data = %v %v", data, err)
+	}
+	if err = app.MaintenanceMode().Deactivate(); err != nil || app.IsDownForMaintenance() {
+		t.Fatalf("Deactivate failed: %v", err)
+	}
+}
@@ -1,31 +1,94 @@
 package cache
 
-import "context"
+import (
+	"context"
+	contractcache "owl/contract/cache"
+	"time"
+)
 
+type arrayLockItem struct {
+	owner     string
+	expiresAt time.Time // 零值表示不过期
+}
+
+// ArrayLock 进程内的锁，实现 contract\cache\lock 接口
+// 锁的状态保存在 ArrayStore 中，同一个 ArrayStore 创建的锁互斥
 type ArrayLock struct {
+	baseLock
+	store *ArrayStore
+}
+
+func NewArrayLock(store *ArrayStore, name string, seconds int, owner string) *ArrayLock {
+	return &ArrayLock{
+		baseLock: newBaseLock(name, seconds, owner),
+		store:    store,
+	}
+}
+
+func (i *ArrayLock) Get(ctx context.Context, callback func()) error {
+	return i.get(ctx, i.acquire, i.Release, callback)
+}
+
+func (i *ArrayLock) Block(ctx context.Context, seconds int, callback func()) error {
+	return i.block(ctx, seconds, i.acquire, i.Release, callback)
 }
 
-func (a ArrayLock) Get(ctx context.Context, callback func()) error {
-	//TODO implement me
-	panic("implement me")
+func (i *ArrayLock) Release() bool {
+	i.store.mu.Lock()
+	defer i.store.mu.Unlock()
+
+	item, ok := i.store.locks[i.name]
+	if !ok || item.owner != i.owner || i.expired(item) {
+		return false
+	}
+	delete(i.store.locks, i.name)
+	return true
+}
+
+func (i *ArrayLock) ForceRelease() {
+	i.store.mu.Lock()
+	defer i.store.mu.Unlock()
+
+	delete(i.store.locks, i.name)
 }
 
-func (a ArrayLock) Block(ctx context.Context, seconds int, callback func()) error {
-	//TODO implement me
-	panic("implement me")
+func (i *ArrayLock) acquire() bool {
+	i.store.mu.Lock()
+	defer i.store.mu.Unlock()
+
+	if item, ok := i.store.locks[i.name]; ok && !i.expired(item) {
+		return false
+	}
+	i.store.locks[i.name] = arrayLockItem{owner: i.owner, expiresAt: expiresAt(i.seconds)}
+	return true
+}
+
+func (i *ArrayLock) expired(item arrayLockItem) bool {
+	return !item.expiresAt.IsZero() && !time.Now().Before(item.expiresAt)
 }
 
-func (a ArrayLock) Release() bool {
-	//TODO implement me
-	panic("implement me")
+// Lock 实现 contract\cache\lock_provider 接口
+func (i *ArrayStore) Lock(name string, seconds int, owner string) contractcache.Lock {
+	return NewArrayLock(i, name, seconds, owner)
 }
 
-func (a ArrayLock) Owner() string {
-	//TODO implement me
-	panic("implement me")
+// RestoreLock 使用持有者标识恢复锁
+func (i *ArrayStore) RestoreLock(name, owner string) contractcache.Lock {
+	return i.Lock(name, 0, owner)
 }
 
-func (a ArrayLock) ForceRelease() {
-	//TODO implement me
-	panic("implement me")
+// Locks 列出未过期的锁
+func (i *ArrayStore) Locks() ([]LockInfo, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	now := time.Now()
+	locks := make([]LockInfo, 0, len(i.locks))
+	for name, item := range i.locks {
+		if !item.expiresAt.IsZero() && !now.Before(item.expiresAt) {
+			continue
+		}
+		locks = append(locks, LockInfo{Name: name, Owner: item.owner, ExpiresAt: item.expiresAt})
+	}
+	return locks, nil
 }
@@ -0,0 +1,68 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	contractcache "owl/contract/cache"
+	"testing"
+	"time"
+)
+
+func TestArrayLock(t *testing.T) {
+	store := NewArrayStore("", 0)
+
+	first := store.Lock("job", 10, "")
+	if err := first.Get(context.Background(), nil); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if err := store.Lock("job", 10, "").Get(context.Background(), nil); !errors.Is(err, contractcache.LockNotAcquiredError) {
+		t.Fatalf("second Get() error = %v", err)
+	}
+	if store.RestoreLock("job", "someone").Release() {
+		t.Fatal("Release() by another owner should fail")
+	}
+	if !store.RestoreLock("job", first.Owner()).Release() {
+		t.Fatal("Release() by the owner should succeed")
+	}
+}
+
+func TestArrayLockExpires(t *testing.T) {
+	store := NewArrayStore("", 0)
+	_ = store.Lock("job", 10, "").Get(context.Background(), nil)
+
+	item := store.locks["job"]
+	item.expiresAt = time.Now().Add(-time.Second)
+	store.locks["job"] = item
+
+	if err := store.Lock("job", 10, "").Get(context.Background(), nil); err != nil {
+		t.Fatalf("expired lock should be acquirable, error = %v", err)
+	}
+}
+
+func TestArrayLockBlockCancel(t *testing.T) {
+	store := NewArrayStore("", 0)
+	_ = store.Lock("job", 0, "").Get(context.Background(), nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	err := store.Lock("job", 0, "").Block(ctx, 10, nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Block() error = %v, want context deadline", err)
+	}
+}
+
+func TestNoLock(t *testing.T) {
+	var lock contractcache.Lock = NewNoLock("job", 10, "")
+
+	called := false
+	if err := lock.Get(context.Background(), func() { called = true }); err != nil || !called {
+		t.Fatalf("Get() error = %v, called = %v", err, called)
+	}
+	if err := lock.Block(context.Background(), 1, nil); err != nil {
+		t.Fatalf("Block() error = %v", err)
+	}
+	if !lock.Release() {
+		t.Fatal("Release() should always succeed")
+	}
+}
@@ -1,55 +1,300 @@
 package cache
 
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ArrayStore 进程内存缓存，支持过期时间，超过容量时按 LRU 淘汰
+// 实现 contract\cache\store 接口，并发安全
 type ArrayStore struct {
-	store map[string][]byte
+	mu      sync.Mutex
+	prefix  string
+	maxSize int                            // 最多缓存的条目数，<= 0 不限制
+	items   map[string]*list.Element       // key => lru 中的节点
+	lru     *list.List                     // 最近使用的排在前面
+	locks   map[string]arrayLockItem       // 锁名称 => 持有者，供 ArrayLock 使用
+	tags    map[string]map[string]struct{} // 标签 => 标签下的 key，供 TaggedCache 使用
+	keyTags map[string]map[string]struct{} // key => key 所属的标签，淘汰或删除 key 时同时清理标签记录
+}
+
+type arrayItem struct {
+	key       string
+	value     interface{}
+	expiresAt time.Time // 零值表示永不过期
+}
+
+func (i *arrayItem) expired(now time.Time) bool {
+	return !i.expiresAt.IsZero() && !now.Before(i.expiresAt)
+}
+
+func NewArrayStore(prefix string, maxSize int) *ArrayStore {
+	return &ArrayStore{
+		prefix:  prefix,
+		maxSize: maxSize,
+		items:   make(map[string]*list.Element),
+		lru:     list.New(),
+		locks:   make(map[string]arrayLockItem),
+		tags:    make(map[string]map[string]struct{}),
+		keyTags: make(map[string]map[string]struct{}),
+	}
+}
+
+func (i *ArrayStore) Get(key interface{}) interface{} {
+	if keys, ok := key.([]string); ok {
+		return i.Many(keys)
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	item := i.get(i.prefix + keyString(key))
+	if item == nil {
+		return nil
+	}
+	return item.value
+}
+
+func (i *ArrayStore) Many(keys []string) []interface{} {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	values := make([]interface{}, len(keys))
+	for n, key := range keys {
+		if item := i.get(i.prefix + key); item != nil {
+			values[n] = item.value
+		}
+	}
+	return values
+}
+
+func (i *ArrayStore) Put(key string, value interface{}, seconds int) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.set(i.prefix+key, value, expiresAt(seconds))
+	return true
+}
+
+func (i *ArrayStore) PutMany(values []interface{}, seconds int) bool {
+	items, ok := pairs(values)
+	if !ok {
+		return false
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	expires := expiresAt(seconds)
+	for key, value := range items {
+		i.set(i.prefix+key, value, expires)
+	}
+	return true
+}
+
+// Add key 不存在或已过期时才写入
+func (i *ArrayStore) Add(key string, value interface{}, seconds int) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.get(i.prefix+key) != nil {
+		return false
+	}
+	i.set(i.prefix+key, value, expiresAt(seconds))
+	return true
+}
+
+func (i *ArrayStore) Increment(key string, value int) (int, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	item := i.get(i.prefix + key)
+	if item == nil {
+		i.set(i.prefix+key, value, time.Time{})
+		return value, true
+	}
+
+	current, ok := toInt(item.value)
+	if !ok {
+		return 0, false
+	}
+	item.value = current + value
+	return current + value, true
+}
+
+func (i *ArrayStore) Decrement(key string, value int) (int, bool) {
+	return i.Increment(key, -value)
+}
+
+func (i *ArrayStore) Forever(key string, value interface{}) bool {
+	return i.Put(key, value, 0)
+}
+
+func (i *ArrayStore) Forget(key string) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	element, ok := i.items[i.prefix+key]
+	if ok {
+		i.remove(element)
+	}
+	return ok
+}
+
+func (i *ArrayStore) Flush() bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.items = make(map[string]*list.Element)
+	i.lru.Init()
+	i.tags = make(map[string]map[string]struct{})
+	i.keyTags = make(map[string]map[string]struct{})
+	return true
+}
+
+func (i *ArrayStore) GetPrefix() string {
+	return i.prefix
 }
 
-func (a ArrayStore) Get(key interface{}) interface{} {
-	//TODO implement me
-	panic("implement me")
+// AddTagEntries 只记录已缓存的 key，标签记录随 key 的淘汰一起清理，不会超出容量
+func (i *ArrayStore) AddTagEntries(tag string, keys ...string) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for _, key := range keys {
+		if _, ok := i.items[i.prefix+key]; !ok {
+			continue
+		}
+		entries, ok := i.tags[tag]
+		if !ok {
+			entries = make(map[string]struct{})
+			i.tags[tag] = entries
+		}
+		entries[key] = struct{}{}
+
+		tags, ok := i.keyTags[key]
+		if !ok {
+			tags = make(map[string]struct{})
+			i.keyTags[key] = tags
+		}
+		tags[tag] = struct{}{}
+	}
+	return true
 }
 
-func (a ArrayStore) Many(keys []string) []interface{} {
-	//TODO implement me
-	panic("implement me")
+func (i *ArrayStore) TagEntries(tag string) []string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	keys := make([]string, 0, len(i.tags[tag]))
+	for key := range i.tags[tag] {
+		keys = append(keys, key)
+	}
+	return keys
 }
 
-func (a ArrayStore) Put(key string, value interface{}, seconds int) bool {
-	//TODO implement me
-	panic("implement me")
+func (i *ArrayStore) ForgetTag(tag string) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for key := range i.tags[tag] {
+		i.untag(tag, key)
+	}
+	return true
 }
 
-func (a ArrayStore) PutMany(values []interface{}, seconds int) bool {
-	//TODO implement me
-	panic("implement me")
+func (i *ArrayStore) ForgetTagEntries(tag string, keys ...string) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for _, key := range keys {
+		i.untag(tag, key)
+	}
+	return true
 }
 
-func (a ArrayStore) Increment(key string, value int) (int, bool) {
-	//TODO implement me
-	panic("implement me")
+// Stats 统计条目数和已过期但还未清理的条目数
+func (i *ArrayStore) Stats() (Stats, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	now := time.Now()
+	stats := Stats{Items: int64(len(i.items)), Bytes: -1}
+	for _, element := range i.items {
+		if element.Value.(*arrayItem).expired(now) {
+			stats.Expired++
+		}
+	}
+	return stats, nil
 }
 
-func (a ArrayStore) Decrement(key string, value int) (int, bool) {
-	//TODO implement me
-	panic("implement me")
+// Len 返回当前缓存的条目数（包含已过期但还未清理的条目）
+func (i *ArrayStore) Len() int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	return len(i.items)
 }
 
-func (a ArrayStore) Forever(key string, value interface{}) bool {
-	//TODO implement me
-	panic("implement me")
+// get 读取未过期的条目并标记为最近使用，已过期的条目直接删除，调用前需持有锁
+func (i *ArrayStore) get(key string) *arrayItem {
+	element, ok := i.items[key]
+	if !ok {
+		return nil
+	}
+
+	item := element.Value.(*arrayItem)
+	if item.expired(time.Now()) {
+		i.remove(element)
+		return nil
+	}
+
+	i.lru.MoveToFront(element)
+	return item
 }
 
-func (a ArrayStore) Forget(key string) bool {
-	//TODO implement me
-	panic("implement me")
+// set 写入条目，超出容量时淘汰最久未使用的条目，调用前需持有锁
+func (i *ArrayStore) set(key string, value interface{}, expires time.Time) {
+	if element, ok := i.items[key]; ok {
+		item := element.Value.(*arrayItem)
+		item.value = value
+		item.expiresAt = expires
+		i.lru.MoveToFront(element)
+		return
+	}
+
+	i.items[key] = i.lru.PushFront(&arrayItem{key: key, value: value, expiresAt: expires})
+
+	for i.maxSize > 0 && i.lru.Len() > i.maxSize {
+		i.remove(i.lru.Back())
+	}
 }
 
-func (a ArrayStore) Flush() bool {
-	//TODO implement me
-	panic("implement me")
+func (i *ArrayStore) remove(element *list.Element) {
+	i.lru.Remove(element)
+	key := element.Value.(*arrayItem).key
+	delete(i.items, key)
+
+	key = strings.TrimPrefix(key, i.prefix)
+	for tag := range i.keyTags[key] {
+		i.untag(tag, key)
+	}
 }
 
-func (a ArrayStore) GetPrefix() string {
-	//TODO implement me
-	panic("implement me")
+// untag 删除 key 和 tag 的双向记录，调用前需持有锁
+func (i *ArrayStore) untag(tag, key string) {
+	if entries, ok := i.tags[tag]; ok {
+		delete(entries, key)
+		if len(entries) == 0 {
+			delete(i.tags, tag)
+		}
+	}
+	if tags, ok := i.keyTags[key]; ok {
+		delete(tags, tag)
+		if len(tags) == 0 {
+			delete(i.keyTags, key)
+		}
+	}
 }
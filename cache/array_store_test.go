@@ -0,0 +1,111 @@
+package cache
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestArrayStorePutGet(t *testing.T) {
+	store := NewArrayStore("app:", 0)
+
+	store.Put("name", "owl", 10)
+	if v := store.Get("name"); v != "owl" {
+		t.Fatalf("Get() = %v, want owl", v)
+	}
+	if store.GetPrefix() != "app:" {
+		t.Fatalf("GetPrefix() = %q", store.GetPrefix())
+	}
+
+	if !store.Forget("name") {
+		t.Fatal("Forget() should report an existing key")
+	}
+	if v := store.Get("name"); v != nil {
+		t.Fatalf("Get() after Forget = %v, want nil", v)
+	}
+}
+
+func TestArrayStoreExpiry(t *testing.T) {
+	store := NewArrayStore("", 0)
+	store.Put("short", 1, 1)
+	store.Forever("long", 2)
+
+	element := store.items["short"]
+	element.Value.(*arrayItem).expiresAt = time.Now().Add(-time.Second)
+
+	if v := store.Get("short"); v != nil {
+		t.Fatalf("expired key returned %v", v)
+	}
+	if v := store.Get("long"); v != 2 {
+		t.Fatalf("Get(long) = %v, want 2", v)
+	}
+	if store.Len() != 1 {
+		t.Fatalf("expired key should be removed on read, Len() = %d", store.Len())
+	}
+}
+
+func TestArrayStoreManyAndPutMany(t *testing.T) {
+	store := NewArrayStore("", 0)
+
+	if store.PutMany([]interface{}{"a", 1, "b"}, 0) {
+		t.Fatal("PutMany() with odd values should fail")
+	}
+	if !store.PutMany([]interface{}{"a", 1, "b", 2}, 0) {
+		t.Fatal("PutMany() failed")
+	}
+
+	values := store.Many([]string{"a", "missing", "b"})
+	if values[0] != 1 || values[1] != nil || values[2] != 2 {
+		t.Fatalf("Many() = %v", values)
+	}
+}
+
+func TestArrayStoreIncrement(t *testing.T) {
+	store := NewArrayStore("", 0)
+
+	if n, ok := store.Increment("counter", 5); !ok || n != 5 {
+		t.Fatalf("Increment() on missing key = %d, %v", n, ok)
+	}
+	if n, ok := store.Decrement("counter", 2); !ok || n != 3 {
+		t.Fatalf("Decrement() = %d, %v", n, ok)
+	}
+
+	store.Put("text", "abc", 0)
+	if _, ok := store.Increment("text", 1); ok {
+		t.Fatal("Increment() on non numeric value should fail")
+	}
+
+	var wg sync.WaitGroup
+	for n := 0; n < 100; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			store.Increment("concurrent", 1)
+		}()
+	}
+	wg.Wait()
+	if v := store.Get("concurrent"); v != 100 {
+		t.Fatalf("concurrent Increment() = %v, want 100", v)
+	}
+}
+
+func TestArrayStoreLRUEviction(t *testing.T) {
+	store := NewArrayStore("", 2)
+
+	store.Put("a", 1, 0)
+	store.Put("b", 2, 0)
+	store.Get("a") // a 变为最近使用
+	store.Put("c", 3, 0)
+
+	if store.Get("b") != nil {
+		t.Fatal("least recently used key should be evicted")
+	}
+	if store.Get("a") != 1 || store.Get("c") != 3 {
+		t.Fatal("recently used keys should be kept")
+	}
+
+	store.Flush()
+	if store.Len() != 0 {
+		t.Fatalf("Flush() left %d items", store.Len())
+	}
+}
@@ -0,0 +1,277 @@
+package cache
+
+import (
+	"fmt"
+	"owl"
+	"owl/cache/event"
+	contractcache "owl/contract/cache"
+	"owl/database"
+	"sort"
+	"sync"
+)
+
+const (
+	DriverArray    = "array"
+	DriverFile     = "file"
+	DriverRedis    = "redis"
+	DriverDatabase = "database"
+	DriverLayered  = "layered"
+	DriverMemcache = "memcached"
+	DriverDynamo   = "dynamodb"
+	DriverNull     = "null"
+)
+
+// StoreOptions 单个缓存存储的配置
+type StoreOptions struct {
+	Driver     string `json:"driver"`     // array file redis database memcached dynamodb layered null
+	Prefix     string `json:"prefix"`     // key 前缀
+	TTL        int    `json:"ttl"`        // 默认有效期，秒，0 表示永久
+	Size       int    `json:"size"`       // array 驱动最多缓存的条目数，0 表示不限制
+	Path       string `json:"path"`       // file 驱动的缓存目录，默认 storage/framework/cache
+	Connection string `json:"connection"` // redis、database、memcached、dynamodb 驱动使用的 conf 目录下的配置文件名
+	Codec      string `json:"codec"`      // 泛型方法使用的编码 json gob msgpack，默认 json
+	Remote     string `json:"remote"`     // layered 驱动的远程存储名称
+	LocalTTL   int    `json:"local-ttl"`  // layered 驱动一级缓存的有效期，秒，默认 5
+	Channel    string `json:"channel"`    // layered 驱动广播失效消息的 redis 频道
+}
+
+// Options conf/cache.yml
+type Options struct {
+	Default string                   `json:"default"` // 默认使用的存储名称
+	Stores  map[string]*StoreOptions `json:"stores"`
+}
+
+func NewOption(cfgManager *owl.ConfManager) (opt *Options) {
+	err := cfgManager.GetConfig("cache", &opt)
+	if err != nil || opt == nil || len(opt.Stores) == 0 {
+		return DefaultOptions()
+	}
+	return opt
+}
+
+// DefaultOptions 没有缓存配置时只使用一个内存缓存
+func DefaultOptions() *Options {
+	return &Options{
+		Default: DriverArray,
+		Stores: map[string]*StoreOptions{
+			DriverArray: {Driver: DriverArray},
+		},
+	}
+}
+
+// CacheManager 实现 contract\cache\factory 接口，按名称创建并复用缓存仓库
+type CacheManager struct {
+	stage      *owl.Stage
+	cfgManager *owl.ConfManager
+	opt        *Options
+	lock       sync.Mutex
+	stores     map[string]*Repository // 已创建的仓库
+	events     *event.Dispatcher      // 所有仓库共用的事件分发器
+}
+
+func NewCacheManager(stage *owl.Stage, cfgManager *owl.ConfManager) *CacheManager {
+	return NewCacheManagerWithOptions(stage, cfgManager, NewOption(cfgManager))
+}
+
+func NewCacheManagerWithOptions(stage *owl.Stage, cfgManager *owl.ConfManager, opt *Options) *CacheManager {
+	return &CacheManager{
+		stage:      stage,
+		cfgManager: cfgManager,
+		opt:        opt,
+		stores:     make(map[string]*Repository),
+		events:     event.NewDispatcher(),
+	}
+}
+
+// Provide 把缓存管理器和默认缓存仓库注册到 stage 容器中
+func Provide(stage *owl.Stage) error {
+	if err := stage.Provide(NewCacheManager); err != nil {
+		return err
+	}
+	if err := stage.Provide(func(manager *CacheManager) contractcache.Factory {
+		return manager
+	}); err != nil {
+		return err
+	}
+	if err := stage.Provide(func(manager *CacheManager) *event.Dispatcher {
+		return manager.Events()
+	}); err != nil {
+		return err
+	}
+	return stage.Provide(func(manager *CacheManager) contractcache.Repository {
+		return manager.Store("")
+	})
+}
+
+// Events 返回缓存事件分发器，可以在服务提供者中注册监听
+func (i *CacheManager) Events() *event.Dispatcher {
+	return i.events
+}
+
+// Store 按名称获取缓存仓库，名称为空时返回默认仓库，配置错误时 panic
+func (i *CacheManager) Store(name string) contractcache.Repository {
+	repository, err := i.Resolve(name)
+	if err != nil {
+		panic(err)
+	}
+	return repository
+}
+
+// Resolve 按名称获取缓存仓库，第一次获取时创建
+func (i *CacheManager) Resolve(name string) (*Repository, error) {
+	if name == "" {
+		name = i.DefaultStoreName()
+	}
+
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	return i.resolve(name, nil)
+}
+
+// resolve 调用方需要持有锁，resolving 记录正在创建的仓库，用于发现 layered 驱动的循环引用
+func (i *CacheManager) resolve(name string, resolving []string) (*Repository, error) {
+	if repository, ok := i.stores[name]; ok {
+		return repository, nil
+	}
+
+	opt, ok := i.opt.Stores[name]
+	if !ok || opt == nil {
+		return nil, fmt.Errorf("缓存存储 [%s] 未配置，关闭缓存时使用 driver: \"null\"", name)
+	}
+
+	codec, err := NewCodec(opt.Codec)
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := i.createStore(name, opt, resolving)
+	if err != nil {
+		return nil, err
+	}
+
+	repository := NewRepository(store)
+	repository.name = name
+	repository.defaultTTL = opt.TTL
+	repository.events = i.events
+	repository.codec = codec
+	i.stores[name] = repository
+	return repository, nil
+}
+
+// DefaultStoreName 返回默认仓库名称
+func (i *CacheManager) DefaultStoreName() string {
+	return i.opt.Default
+}
+
+// StoreNames 返回配置中所有的仓库名称
+func (i *CacheManager) StoreNames() []string {
+	names := make([]string, 0, len(i.opt.Stores))
+	for name := range i.opt.Stores {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// StoreOptions 返回仓库的配置
+func (i *CacheManager) StoreOptions(name string) (*StoreOptions, bool) {
+	opt, ok := i.opt.Stores[name]
+	return opt, ok
+}
+
+func (i *CacheManager) createStore(name string, opt *StoreOptions, resolving []string) (contractcache.Store, error) {
+	switch opt.Driver {
+	case DriverArray:
+		return NewArrayStore(opt.Prefix, opt.Size), nil
+	case DriverFile:
+		path := opt.Path
+		if path == "" {
+			path = i.stage.CachePath()
+		}
+		return NewFileStore(path, opt.Prefix), nil
+	case DriverRedis:
+		connection := opt.Connection
+		if connection == "" {
+			connection = DriverRedis
+		}
+		redisOpt := NewRedisOption(i.cfgManager, connection)
+		prefix := opt.Prefix
+		if prefix == "" && redisOpt != nil {
+			prefix = redisOpt.Prefix
+		}
+		return NewRedisStore(NewRedisClient(redisOpt), prefix), nil
+	case DriverDatabase:
+		connection := opt.Connection
+		if connection == "" {
+			connection = "db"
+		}
+		if i.stage == nil {
+			return NewDatabaseStore(database.NewDatabaseServiceFromConfig(i.cfgManager, connection), opt.Prefix)
+		}
+		return NewDatabaseStore(database.NewDatabaseServiceFromStage(i.stage, i.cfgManager, connection), opt.Prefix)
+	case DriverMemcache:
+		connection := opt.Connection
+		if connection == "" {
+			connection = DriverMemcache
+		}
+		memcacheOpt := NewMemcacheOption(i.cfgManager, connection)
+		prefix := opt.Prefix
+		if prefix == "" && memcacheOpt != nil {
+			prefix = memcacheOpt.Prefix
+		}
+		return NewMemcacheStore(NewMemcacheClientFromOptions(memcacheOpt), prefix), nil
+	case DriverDynamo:
+		connection := opt.Connection
+		if connection == "" {
+			connection = DriverDynamo
+		}
+		dynamoOpt := NewDynamoOption(i.cfgManager, connection)
+		if dynamoOpt != nil && opt.Prefix != "" {
+			dynamoOpt.Prefix = opt.Prefix
+		}
+		return NewDynamoStore(dynamoOpt), nil
+	case DriverNull:
+		return NewNullStore(), nil
+	case DriverLayered:
+		return i.createLayeredStore(name, opt, resolving)
+	case "":
+		// 没有配置 driver 时不退化为 null，避免拼错 key 时悄悄关闭缓存
+		// yaml 中不加引号的 null 读取后和没有配置相同，需要写成 "null"
+		return nil, fmt.Errorf("缓存存储 [%s] 未配置 driver，关闭缓存时使用 driver: \"null\"", name)
+	default:
+		return nil, fmt.Errorf("不支持的缓存驱动 [%s]", opt.Driver)
+	}
+}
+
+// createLayeredStore 配置了 connection 时使用对应的 redis 连接广播失效消息，
+// 否则远程存储为 redis 时使用远程存储的连接，都不满足时不广播
+func (i *CacheManager) createLayeredStore(name string, opt *StoreOptions, resolving []string) (contractcache.Store, error) {
+	if opt.Remote == "" {
+		return nil, fmt.Errorf("缓存存储 [%s] 未配置 remote", name)
+	}
+	resolving = append(resolving, name)
+	for _, n := range resolving {
+		if n == opt.Remote {
+			return nil, fmt.Errorf("缓存存储 [%s] 的 remote 存在循环引用", name)
+		}
+	}
+
+	remote, err := i.resolve(opt.Remote, resolving)
+	if err != nil {
+		return nil, err
+	}
+
+	channel := opt.Channel
+	if channel == "" {
+		channel = remote.GetStore().GetPrefix() + "cache:invalidate:" + name
+	}
+
+	var bus InvalidationBus
+	if opt.Connection != "" {
+		bus = NewRedisBus(NewRedisClient(NewRedisOption(i.cfgManager, opt.Connection)), channel)
+	} else if store, ok := remote.GetStore().(*RedisStore); ok {
+		bus = NewRedisBus(store.Client(), channel)
+	}
+	return NewLayeredStore(remote.GetStore(), opt.LocalTTL, opt.Size, bus)
+}
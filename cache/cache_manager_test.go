@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"testing"
+)
+
+func TestCacheManagerResolve(t *testing.T) {
+	manager := NewCacheManagerWithOptions(nil, nil, &Options{
+		Default: "memory",
+		Stores: map[string]*StoreOptions{
+			"memory": {Driver: DriverArray, Prefix: "m:", TTL: 60},
+			"disk":   {Driver: DriverFile, Path: t.TempDir()},
+			"bad":    {Driver: "unknown"},
+			"hot":    {Driver: DriverLayered, Remote: "disk", LocalTTL: 2},
+			"loop":   {Driver: DriverLayered, Remote: "loop"},
+			"off":    {Driver: DriverNull},
+		},
+	})
+
+	repository := manager.Store("")
+	if manager.Store("memory") != repository {
+		t.Fatal("Store() should reuse the created repository")
+	}
+	if _, ok := repository.GetStore().(*ArrayStore); !ok {
+		t.Fatalf("default store = %T, want *ArrayStore", repository.GetStore())
+	}
+	if repository.GetStore().GetPrefix() != "m:" {
+		t.Fatal("store prefix should come from config")
+	}
+
+	repository.Put("name", "owl", nil)
+	if v := repository.Get("name", nil); v != "owl" {
+		t.Fatalf("Get() = %v, want owl", v)
+	}
+
+	disk, err := manager.Resolve("disk")
+	if err != nil {
+		t.Fatalf("Resolve(disk) error = %v", err)
+	}
+	if _, ok := disk.GetStore().(*FileStore); !ok {
+		t.Fatalf("disk store = %T, want *FileStore", disk.GetStore())
+	}
+
+	hot, err := manager.Resolve("hot")
+	if err != nil {
+		t.Fatalf("Resolve(hot) error = %v", err)
+	}
+	layered, ok := hot.GetStore().(*LayeredStore)
+	if !ok || layered.Remote() != disk.GetStore() {
+		t.Fatalf("hot store = %T, want *LayeredStore over disk", hot.GetStore())
+	}
+	if _, err = manager.Resolve("loop"); err == nil {
+		t.Fatal("Resolve() with a remote loop should fail")
+	}
+
+	off, err := manager.Resolve("off")
+	if err != nil {
+		t.Fatalf("Resolve(off) error = %v", err)
+	}
+	if _, ok := off.GetStore().(*NullStore); !ok {
+		t.Fatalf("off store = %T, want *NullStore", off.GetStore())
+	}
+
+	if _, err = manager.Resolve("bad"); err == nil {
+		t.Fatal("Resolve() with unknown driver should fail")
+	}
+	if _, err = manager.Resolve("missing"); err == nil {
+		t.Fatal("Resolve() with unknown name should fail")
+	}
+}
@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const (
+	CodecJSON    = "json"
+	CodecGob     = "gob"
+	CodecMsgpack = "msgpack"
+)
+
+// Codec 泛型缓存方法 Get[T]、Remember[T] 等使用的编解码方式
+// 值先编码为 []byte 再写入存储，读取时解码到 T，保证在内存、文件、redis 等存储中类型一致
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// DefaultCodec 仓库没有设置编解码方式时使用
+var DefaultCodec Codec = JSONCodec{}
+
+// NewCodec 根据名称创建编解码方式，名称为空时返回 DefaultCodec
+func NewCodec(name string) (Codec, error) {
+	switch name {
+	case "":
+		return DefaultCodec, nil
+	case CodecJSON:
+		return JSONCodec{}, nil
+	case CodecGob:
+		return GobCodec{}, nil
+	case CodecMsgpack:
+		return MsgpackCodec{}, nil
+	default:
+		return nil, fmt.Errorf("不支持的缓存编码 [%s]", name)
+	}
+}
+
+// JSONCodec 使用 jsoniter 编解码
+type JSONCodec struct {
+}
+
+func (i JSONCodec) Marshal(v any) ([]byte, error) {
+	return jsoniter.Marshal(v)
+}
+
+func (i JSONCodec) Unmarshal(data []byte, v any) error {
+	return jsoniter.Unmarshal(data, v)
+}
+
+// GobCodec 使用 gob 编解码，适合只在 Go 程序之间共享的缓存
+type GobCodec struct {
+}
+
+func (i GobCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (i GobCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// MsgpackCodec 使用 msgpack 编解码，体积比 json 小
+type MsgpackCodec struct {
+}
+
+func (i MsgpackCodec) Marshal(v any) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+func (i MsgpackCodec) Unmarshal(data []byte, v any) error {
+	return msgpack.Unmarshal(data, v)
+}
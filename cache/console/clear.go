@@ -0,0 +1,46 @@
+package console
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+)
+
+func newClearCommand(resolve ManagerResolver) *cobra.Command {
+	var tags []string
+
+	cmd := &cobra.Command{
+		Use:   "cache:clear [store]",
+		Short: "清空缓存，不指定 store 时清空默认存储",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := ""
+			if len(args) > 0 {
+				name = args[0]
+			}
+			repository, err := repository(resolve, name)
+			if err != nil {
+				return err
+			}
+
+			if len(tags) > 0 {
+				tagged, err := repository.Tags(tags...)
+				if err != nil {
+					return fmt.Errorf("缓存存储 [%s] 不支持标签", displayName(repository))
+				}
+				if !tagged.Flush() {
+					return fmt.Errorf("清空缓存存储 [%s] 的标签 %v 失败", displayName(repository), tags)
+				}
+				printf(cmd, "缓存存储 [%s] 的标签 %v 已清空\n", displayName(repository), tags)
+				return nil
+			}
+
+			if !repository.GetStore().Flush() {
+				return fmt.Errorf("清空缓存存储 [%s] 失败", displayName(repository))
+			}
+			printf(cmd, "缓存存储 [%s] 已清空\n", displayName(repository))
+			return nil
+		},
+	}
+	cmd.Flags().StringSliceVarP(&tags, "tags", "t", nil, "只清空带有这些标签的缓存")
+	return cmd
+}
@@ -0,0 +1,77 @@
+package console
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"owl"
+	"owl/cache"
+)
+
+// ManagerResolver 执行命令时获取缓存管理器，避免注册命令时就读取配置、连接存储
+type ManagerResolver func() (*cache.CacheManager, error)
+
+// Register 把缓存管理命令注册到 App.Console
+//
+//	app := owl.NewApp("demo", "demo", "demo", owl.KillAll, stage, start)
+//	console.Register(app.Console, stage)
+func Register(root *cobra.Command, stage *owl.Stage) {
+	root.AddCommand(NewCommands(StageResolver(stage))...)
+}
+
+// StageResolver 从 stage 容器中获取缓存管理器，容器中还没有时先调用 cache.Provide 注册
+func StageResolver(stage *owl.Stage) ManagerResolver {
+	return func() (manager *cache.CacheManager, err error) {
+		resolve := func(m *cache.CacheManager) {
+			manager = m
+		}
+		if err = stage.Invoke(resolve); err == nil {
+			return manager, nil
+		}
+		if err = cache.Provide(stage); err != nil {
+			return nil, err
+		}
+		if err = stage.Invoke(resolve); err != nil {
+			return nil, err
+		}
+		return manager, nil
+	}
+}
+
+// NewCommands 创建所有缓存管理命令
+func NewCommands(resolve ManagerResolver) []*cobra.Command {
+	return []*cobra.Command{
+		newClearCommand(resolve),
+		newForgetCommand(resolve),
+		newGetCommand(resolve),
+		newStatsCommand(resolve),
+		newLocksCommand(resolve),
+	}
+}
+
+// repository 按名称获取缓存仓库，名称为空时使用默认仓库
+func repository(resolve ManagerResolver, name string) (*cache.Repository, error) {
+	manager, err := resolve()
+	if err != nil {
+		return nil, err
+	}
+	return manager.Resolve(name)
+}
+
+// storeNames 参数为空时返回所有配置的仓库名称
+func storeNames(manager *cache.CacheManager, args []string) []string {
+	if len(args) > 0 {
+		return args
+	}
+	return manager.StoreNames()
+}
+
+func displayName(repository *cache.Repository) string {
+	if repository.Name() == "" {
+		return "default"
+	}
+	return repository.Name()
+}
+
+func printf(cmd *cobra.Command, format string, a ...any) {
+	_, _ = fmt.Fprintf(cmd.OutOrStdout(), format, a...)
+}
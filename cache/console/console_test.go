@@ -0,0 +1,101 @@
+package console
+
+import (
+	"bytes"
+	"context"
+	"owl/cache"
+	"strings"
+	"testing"
+)
+
+func run(t *testing.T, manager *cache.CacheManager, args ...string) (string, error) {
+	t.Helper()
+	root := NewCommands(func() (*cache.CacheManager, error) { return manager, nil })
+	for _, cmd := range root {
+		if strings.HasPrefix(cmd.Use, args[0]+" ") || cmd.Use == args[0] {
+			out := &bytes.Buffer{}
+			cmd.SetOut(out)
+			cmd.SetErr(out)
+			cmd.SetArgs(args[1:])
+			cmd.SilenceUsage = true
+			err := cmd.Execute()
+			return out.String(), err
+		}
+	}
+	t.Fatalf("command %s not found", args[0])
+	return "", nil
+}
+
+func TestCacheCommands(t *testing.T) {
+	manager := cache.NewCacheManagerWithOptions(nil, nil, &cache.Options{
+		Default: "memory",
+		Stores: map[string]*cache.StoreOptions{
+			"memory": {Driver: cache.DriverArray},
+			"disk":   {Driver: cache.DriverFile, Path: t.TempDir()},
+		},
+	})
+	memory, _ := manager.Resolve("memory")
+	disk, _ := manager.Resolve("disk")
+
+	memory.Put("name", "owl", nil)
+	memory.Put("user", map[string]any{"id": 1}, nil)
+	disk.Put("name", "disk", nil)
+
+	if out, err := run(t, manager, "cache:get", "name"); err != nil || out != "owl\n" {
+		t.Fatalf("cache:get = %q, %v", out, err)
+	}
+	if out, _ := run(t, manager, "cache:get", "user"); !strings.Contains(out, `"id": 1`) {
+		t.Fatalf("cache:get user = %q", out)
+	}
+	if _, err := run(t, manager, "cache:get", "missing"); err == nil {
+		t.Fatal("cache:get missing should fail")
+	}
+
+	out, err := run(t, manager, "cache:stats")
+	if err != nil || !strings.Contains(out, "memory") || !strings.Contains(out, "disk") {
+		t.Fatalf("cache:stats = %q, %v", out, err)
+	}
+
+	if _, err = run(t, manager, "cache:forget", "name"); err != nil || memory.Has("name") {
+		t.Fatal("cache:forget should remove the key")
+	}
+
+	if _, err = run(t, manager, "cache:clear", "disk"); err != nil || disk.Has("name") {
+		t.Fatal("cache:clear disk should flush the file store")
+	}
+	if !memory.Has("user") {
+		t.Fatal("cache:clear disk must not flush other stores")
+	}
+
+	users, _ := memory.Tags("users")
+	users.Put("u1", 1, nil)
+	if _, err = run(t, manager, "cache:clear", "--tags", "users"); err != nil || users.Has("u1") || !memory.Has("user") {
+		t.Fatal("cache:clear --tags should only flush tagged entries")
+	}
+	if _, err = run(t, manager, "cache:locks", "disk"); err == nil {
+		t.Fatal("cache:locks on file store should fail")
+	}
+}
+
+func TestLocksCommand(t *testing.T) {
+	manager := cache.NewCacheManagerWithOptions(nil, nil, cache.DefaultOptions())
+	repository, _ := manager.Resolve("")
+	store := repository.GetStore().(*cache.ArrayStore)
+
+	lock := store.Lock("report", 60, "")
+	if err := lock.Get(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := run(t, manager, "cache:locks")
+	if err != nil || !strings.Contains(out, "report") || !strings.Contains(out, lock.Owner()) {
+		t.Fatalf("cache:locks = %q, %v", out, err)
+	}
+
+	if _, err = run(t, manager, "cache:locks", "--release", "report"); err != nil {
+		t.Fatal(err)
+	}
+	if err = store.Lock("report", 60, "").Get(context.Background(), nil); err != nil {
+		t.Fatalf("lock should be released, got %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package console
+
+import (
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/spf13/cobra"
+)
+
+func newForgetCommand(resolve ManagerResolver) *cobra.Command {
+	var store string
+
+	cmd := &cobra.Command{
+		Use:   "cache:forget <key>",
+		Short: "删除缓存中的 key",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			repository, err := repository(resolve, store)
+			if err != nil {
+				return err
+			}
+
+			if repository.Forget(args[0]) {
+				printf(cmd, "已从缓存存储 [%s] 删除 [%s]\n", displayName(repository), args[0])
+			} else {
+				printf(cmd, "缓存存储 [%s] 中不存在 [%s]\n", displayName(repository), args[0])
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&store, "store", "s", "", "缓存存储名称，默认使用默认存储")
+	return cmd
+}
+
+func newGetCommand(resolve ManagerResolver) *cobra.Command {
+	var store string
+
+	cmd := &cobra.Command{
+		Use:   "cache:get <key>",
+		Short: "查看缓存中 key 的值",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			repository, err := repository(resolve, store)
+			if err != nil {
+				return err
+			}
+
+			value := repository.GetStore().Get(args[0])
+			if value == nil {
+				return fmt.Errorf("缓存存储 [%s] 中不存在 [%s]", displayName(repository), args[0])
+			}
+			printf(cmd, "%s\n", format(value))
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&store, "store", "s", "", "缓存存储名称，默认使用默认存储")
+	return cmd
+}
+
+// format 字符串原样输出，其他类型尽量输出为 json
+func format(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	}
+	data, err := jsoniter.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return fmt.Sprintf("%#v", value)
+	}
+	return string(data)
+}
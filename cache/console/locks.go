@@ -0,0 +1,80 @@
+package console
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"owl/cache"
+	contractcache "owl/contract/cache"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+func newLocksCommand(resolve ManagerResolver) *cobra.Command {
+	var release []string
+	var releaseAll bool
+
+	cmd := &cobra.Command{
+		Use:   "cache:locks [store]",
+		Short: "列出缓存存储中持有的锁，可以强制释放",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := ""
+			if len(args) > 0 {
+				name = args[0]
+			}
+			repository, err := repository(resolve, name)
+			if err != nil {
+				return err
+			}
+
+			store := repository.GetStore()
+			provider, ok := store.(contractcache.LockProvider)
+			lister, listable := store.(cache.LockLister)
+			if !ok || !listable {
+				return fmt.Errorf("缓存存储 [%s] 不支持列出锁", displayName(repository))
+			}
+
+			locks, err := lister.Locks()
+			if err != nil {
+				return err
+			}
+			sort.Slice(locks, func(a, b int) bool {
+				return locks[a].Name < locks[b].Name
+			})
+
+			if releaseAll {
+				release = make([]string, 0, len(locks))
+				for _, lock := range locks {
+					release = append(release, lock.Name)
+				}
+			}
+			if len(release) > 0 {
+				for _, lockName := range release {
+					provider.RestoreLock(lockName, "").ForceRelease()
+					printf(cmd, "已释放锁 [%s]\n", lockName)
+				}
+				return nil
+			}
+
+			if len(locks) == 0 {
+				printf(cmd, "缓存存储 [%s] 中没有持有的锁\n", displayName(repository))
+				return nil
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			_, _ = w.Write([]byte("NAME\tOWNER\tEXPIRES\n"))
+			for _, lock := range locks {
+				expires := "never"
+				if !lock.ExpiresAt.IsZero() {
+					expires = lock.ExpiresAt.Format(time.DateTime)
+				}
+				_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", lock.Name, lock.Owner, expires)
+			}
+			return w.Flush()
+		},
+	}
+	cmd.Flags().StringSliceVarP(&release, "release", "r", nil, "强制释放指定名称的锁")
+	cmd.Flags().BoolVar(&releaseAll, "all", false, "强制释放所有锁")
+	return cmd
+}
@@ -0,0 +1,60 @@
+package console
+
+import (
+	"github.com/spf13/cobra"
+	"owl/cache"
+	"strconv"
+	"text/tabwriter"
+)
+
+func newStatsCommand(resolve ManagerResolver) *cobra.Command {
+	return &cobra.Command{
+		Use:   "cache:stats [store...]",
+		Short: "查看缓存存储的统计信息，不指定 store 时列出所有存储",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			manager, err := resolve()
+			if err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			_, _ = w.Write([]byte("STORE\tDRIVER\tITEMS\tEXPIRED\tBYTES\n"))
+			for _, name := range storeNames(manager, args) {
+				driver := ""
+				if opt, ok := manager.StoreOptions(name); ok {
+					driver = opt.Driver
+				}
+
+				row := name + "\t" + driver + "\t"
+				repository, err := manager.Resolve(name)
+				if err != nil {
+					row += "error: " + err.Error() + "\t\t\n"
+					_, _ = w.Write([]byte(row))
+					continue
+				}
+
+				store, ok := repository.GetStore().(cache.StatsStore)
+				if !ok {
+					_, _ = w.Write([]byte(row + "-\t-\t-\n"))
+					continue
+				}
+				stats, err := store.Stats()
+				if err != nil {
+					_, _ = w.Write([]byte(row + "error: " + err.Error() + "\t\t\n"))
+					continue
+				}
+				row += number(stats.Items) + "\t" + number(stats.Expired) + "\t" + number(stats.Bytes) + "\n"
+				_, _ = w.Write([]byte(row))
+			}
+			return w.Flush()
+		},
+	}
+}
+
+// number -1 表示无法统计
+func number(n int64) string {
+	if n < 0 {
+		return "-"
+	}
+	return strconv.FormatInt(n, 10)
+}
@@ -1 +1,111 @@
 package cache
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	contractcache "owl/contract/cache"
+	"strings"
+	"time"
+)
+
+// CacheLockItem cache_locks 表
+type CacheLockItem struct {
+	Key        string `gorm:"primaryKey;size:255"`
+	Owner      string `gorm:"size:255"`
+	Expiration int64  `gorm:"index"`
+}
+
+func (CacheLockItem) TableName() string {
+	return "cache_locks"
+}
+
+// DatabaseLock 数据库锁，实现 contract\cache\lock 接口
+// 先尝试插入记录，主键冲突时只有锁已过期或属于自己才能更新为自己持有
+type DatabaseLock struct {
+	baseLock
+	db *gorm.DB
+}
+
+func NewDatabaseLock(db *gorm.DB, name string, seconds int, owner string) *DatabaseLock {
+	return &DatabaseLock{
+		baseLock: newBaseLock(name, seconds, owner),
+		db:       db,
+	}
+}
+
+func (i *DatabaseLock) Get(ctx context.Context, callback func()) error {
+	return i.get(ctx, i.acquire, i.Release, callback)
+}
+
+func (i *DatabaseLock) Block(ctx context.Context, seconds int, callback func()) error {
+	return i.block(ctx, seconds, i.acquire, i.Release, callback)
+}
+
+func (i *DatabaseLock) Release() bool {
+	tx := i.db.Where(map[string]interface{}{"key": i.name, "owner": i.owner}).Delete(&CacheLockItem{})
+	return tx.Error == nil && tx.RowsAffected > 0
+}
+
+func (i *DatabaseLock) ForceRelease() {
+	i.db.Where(map[string]interface{}{"key": i.name}).Delete(&CacheLockItem{})
+}
+
+// CurrentOwner 返回数据库中记录的持有者，锁不存在或已过期时返回空字符串
+func (i *DatabaseLock) CurrentOwner() string {
+	var item CacheLockItem
+	err := i.db.Where(map[string]interface{}{"key": i.name}).Take(&item).Error
+	if err != nil || item.Expiration <= time.Now().Unix() {
+		return ""
+	}
+	return item.Owner
+}
+
+func (i *DatabaseLock) acquire() bool {
+	expiration := expirationTimestamp(i.seconds)
+
+	tx := i.db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&CacheLockItem{Key: i.name, Owner: i.owner, Expiration: expiration})
+	if tx.Error == nil && tx.RowsAffected > 0 {
+		return true
+	}
+
+	tx = i.db.Model(&CacheLockItem{}).
+		Where(map[string]interface{}{"key": i.name}).
+		Where(i.db.Where(map[string]interface{}{"owner": i.owner}).
+			Or(clause.Lte{Column: clause.Column{Name: "expiration"}, Value: time.Now().Unix()})).
+		Updates(map[string]interface{}{"owner": i.owner, "expiration": expiration})
+	return tx.Error == nil && tx.RowsAffected > 0
+}
+
+// Lock 实现 contract\cache\lock_provider 接口
+func (i *DatabaseStore) Lock(name string, seconds int, owner string) contractcache.Lock {
+	return NewDatabaseLock(i.db, i.prefix+lockPrefix+name, seconds, owner)
+}
+
+// RestoreLock 使用持有者标识恢复锁，可以在其他进程中释放这个锁
+func (i *DatabaseStore) RestoreLock(name, owner string) contractcache.Lock {
+	return i.Lock(name, 0, owner)
+}
+
+// Locks 列出当前前缀下未过期的锁
+func (i *DatabaseStore) Locks() ([]LockInfo, error) {
+	prefix := i.prefix + lockPrefix
+	tx := i.db.Where(clause.Gt{Column: clause.Column{Name: "expiration"}, Value: time.Now().Unix()}).
+		Where(keyHasPrefix(prefix))
+
+	var items []CacheLockItem
+	if err := tx.Find(&items).Error; err != nil {
+		return nil, err
+	}
+
+	locks := make([]LockInfo, 0, len(items))
+	for _, item := range items {
+		info := LockInfo{Name: strings.TrimPrefix(item.Key, prefix), Owner: item.Owner}
+		if item.Expiration != foreverTimestamp {
+			info.ExpiresAt = time.Unix(item.Expiration, 0)
+		}
+		locks = append(locks, info)
+	}
+	return locks, nil
+}
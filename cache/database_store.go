@@ -1 +1,297 @@
 package cache
+
+import (
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"owl/database"
+	"strings"
+	"time"
+)
+
+// CacheItem cache 表
+type CacheItem struct {
+	Key        string `gorm:"primaryKey;size:255"`
+	Value      []byte
+	Expiration int64 `gorm:"index"`
+}
+
+func (CacheItem) TableName() string {
+	return "cache"
+}
+
+// DatabaseStore 数据库缓存，实现 contract\cache\store 接口
+// 支持 mysql、postgres、sqlite，使用 DatabaseService 中的连接
+type DatabaseStore struct {
+	db         *gorm.DB
+	prefix     string
+	serializer Serializer
+}
+
+// NewDatabaseStore 创建数据库缓存，自动迁移 cache 和 cache_locks 表
+func NewDatabaseStore(service *database.DatabaseService, prefix string) (*DatabaseStore, error) {
+	db := service.Get()
+	if db == nil {
+		return nil, errors.New("缓存数据库没有连接")
+	}
+	if err := db.AutoMigrate(&CacheItem{}, &CacheLockItem{}); err != nil {
+		return nil, fmt.Errorf("缓存表迁移失败: %w", err)
+	}
+	return &DatabaseStore{
+		db:         db,
+		prefix:     prefix,
+		serializer: GobSerializer{},
+	}, nil
+}
+
+func (i *DatabaseStore) Get(key interface{}) interface{} {
+	if keys, ok := key.([]string); ok {
+		return i.Many(keys)
+	}
+
+	var item CacheItem
+	err := i.db.Where(map[string]interface{}{"key": i.prefix + keyString(key)}).Take(&item).Error
+	if err != nil {
+		return nil
+	}
+
+	if item.Expiration <= time.Now().Unix() {
+		i.db.Where(map[string]interface{}{"key": item.Key}).Delete(&CacheItem{})
+		return nil
+	}
+	return i.unserialize(item.Value)
+}
+
+// Many 一次查询取回所有值
+func (i *DatabaseStore) Many(keys []string) []interface{} {
+	values := make([]interface{}, len(keys))
+	if len(keys) == 0 {
+		return values
+	}
+
+	prefixed := make([]string, len(keys))
+	for n, key := range keys {
+		prefixed[n] = i.prefix + key
+	}
+
+	var items []CacheItem
+	err := i.db.Where(map[string]interface{}{"key": prefixed}).
+		Where(clause.Gt{Column: clause.Column{Name: "expiration"}, Value: time.Now().Unix()}).
+		Find(&items).Error
+	if err != nil {
+		return values
+	}
+
+	found := make(map[string][]byte, len(items))
+	for _, item := range items {
+		found[item.Key] = item.Value
+	}
+	for n, key := range prefixed {
+		if data, ok := found[key]; ok {
+			values[n] = i.unserialize(data)
+		}
+	}
+	return values
+}
+
+func (i *DatabaseStore) Put(key string, value interface{}, seconds int) bool {
+	data, err := i.serializer.Serialize(value)
+	if err != nil {
+		return false
+	}
+	return i.upsert(i.db, []CacheItem{{
+		Key:        i.prefix + key,
+		Value:      data,
+		Expiration: expirationTimestamp(seconds),
+	}}) == nil
+}
+
+func (i *DatabaseStore) PutMany(values []interface{}, seconds int) bool {
+	entries, ok := pairs(values)
+	if !ok {
+		return false
+	}
+
+	expiration := expirationTimestamp(seconds)
+	items := make([]CacheItem, 0, len(entries))
+	for key, value := range entries {
+		data, err := i.serializer.Serialize(value)
+		if err != nil {
+			return false
+		}
+		items = append(items, CacheItem{Key: i.prefix + key, Value: data, Expiration: expiration})
+	}
+	if len(items) == 0 {
+		return true
+	}
+	return i.upsert(i.db, items) == nil
+}
+
+// Add 先尝试插入，主键冲突时只有已过期的记录才会被覆盖
+func (i *DatabaseStore) Add(key string, value interface{}, seconds int) bool {
+	data, err := i.serializer.Serialize(value)
+	if err != nil {
+		return false
+	}
+
+	item := CacheItem{Key: i.prefix + key, Value: data, Expiration: expirationTimestamp(seconds)}
+	tx := i.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&item)
+	if tx.Error == nil && tx.RowsAffected > 0 {
+		return true
+	}
+
+	tx = i.db.Model(&CacheItem{}).
+		Where(map[string]interface{}{"key": item.Key}).
+		Where(clause.Lte{Column: clause.Column{Name: "expiration"}, Value: time.Now().Unix()}).
+		Updates(map[string]interface{}{"value": item.Value, "expiration": item.Expiration})
+	return tx.Error == nil && tx.RowsAffected > 0
+}
+
+// Increment 使用一条 INSERT ... ON CONFLICT DO UPDATE 自增，不存在或已过期时写入 value，
+// 并发的第一次自增也不会丢失
+func (i *DatabaseStore) Increment(key string, value int) (int, bool) {
+	var result int
+	prefixed := i.prefix + key
+	data, err := i.serializer.Serialize(value)
+	if err != nil {
+		return 0, false
+	}
+
+	err = i.db.Transaction(func(tx *gorm.DB) error {
+		var item CacheItem
+		err := tx.Where(map[string]interface{}{"key": prefixed}).Take(&item).Error
+		if err == nil && item.Expiration > time.Now().Unix() {
+			if _, ok := toInt(item.Value); !ok {
+				return errors.New("缓存的值不是整数")
+			}
+		} else if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		now := time.Now().Unix()
+		valueExpr, expirationExpr := i.incrementExprs()
+		err = tx.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "key"}},
+			DoUpdates: clause.Set{
+				{Column: clause.Column{Name: "value"}, Value: gorm.Expr(valueExpr, now, value)},
+				{Column: clause.Column{Name: "expiration"}, Value: gorm.Expr(expirationExpr, now)},
+			},
+		}).Create(&CacheItem{Key: prefixed, Value: data, Expiration: foreverTimestamp}).Error
+		if err != nil {
+			return err
+		}
+
+		// 事务提交前其他连接无法修改这条记录，读到的是本次自增的结果
+		if err = tx.Where(map[string]interface{}{"key": prefixed}).Take(&item).Error; err != nil {
+			return err
+		}
+		var ok bool
+		if result, ok = toInt(item.Value); !ok {
+			return errors.New("缓存的值不是整数")
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, false
+	}
+	return result, true
+}
+
+func (i *DatabaseStore) Decrement(key string, value int) (int, bool) {
+	return i.Increment(key, -value)
+}
+
+func (i *DatabaseStore) Forever(key string, value interface{}) bool {
+	return i.Put(key, value, 0)
+}
+
+func (i *DatabaseStore) Forget(key string) bool {
+	tx := i.db.Where(map[string]interface{}{"key": i.prefix + key}).Delete(&CacheItem{})
+	return tx.Error == nil && tx.RowsAffected > 0
+}
+
+// Flush 删除当前前缀下的所有缓存，前缀为空时清空整张表
+func (i *DatabaseStore) Flush() bool {
+	tx := i.db.Session(&gorm.Session{AllowGlobalUpdate: true})
+	if i.prefix != "" {
+		tx = tx.Where(keyHasPrefix(i.prefix))
+	}
+	return tx.Delete(&CacheItem{}).Error == nil
+}
+
+func (i *DatabaseStore) GetPrefix() string {
+	return i.prefix
+}
+
+// Stats 统计当前前缀下的条目数、已过期的条目数和值占用的空间
+func (i *DatabaseStore) Stats() (Stats, error) {
+	var row struct {
+		Items   int64
+		Expired int64
+		Bytes   int64
+	}
+
+	tx := i.db.Model(&CacheItem{}).Select(
+		"COUNT(*) AS items, COALESCE(SUM(CASE WHEN expiration <= ? THEN 1 ELSE 0 END), 0) AS expired, COALESCE(SUM(LENGTH(value)), 0) AS bytes",
+		time.Now().Unix(),
+	)
+	if i.prefix != "" {
+		tx = tx.Where(keyHasPrefix(i.prefix))
+	}
+	if err := tx.Scan(&row).Error; err != nil {
+		return Stats{}, err
+	}
+	return Stats{Items: row.Items, Expired: row.Expired, Bytes: row.Bytes}, nil
+}
+
+// upsert 写入或覆盖，gorm 会按驱动生成对应的语句：
+// mysql 为 ON DUPLICATE KEY UPDATE，postgres、sqlite 为 ON CONFLICT DO UPDATE
+func (i *DatabaseStore) upsert(db *gorm.DB, items []CacheItem) error {
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "expiration"}),
+	}).Create(&items).Error
+}
+
+// incrementExprs 主键冲突时更新 value 和 expiration 的表达式，参数依次为当前时间和增量，
+// 已过期的记录使用插入的值，否则把保存的文本转为整数再相加
+// mysql 按顺序赋值，value 必须在 expiration 之前更新
+func (i *DatabaseStore) incrementExprs() (value, expiration string) {
+	table := CacheItem{}.TableName()
+	existing := table + ".%s"
+	inserted := "excluded.%s"
+	increment := "CAST(CAST(%s AS INTEGER) + ? AS BLOB)"
+	switch i.db.Dialector.Name() {
+	case "mysql":
+		existing, inserted = "%s", "VALUES(%s)"
+		increment = "CAST(CAST(%s AS SIGNED) + ? AS CHAR)"
+	case "postgres":
+		increment = "convert_to((convert_from(%s, 'UTF8')::bigint + ?)::text, 'UTF8')"
+	}
+
+	expired := fmt.Sprintf(existing, "expiration") + " <= ?"
+	value = fmt.Sprintf("CASE WHEN %s THEN %s ELSE %s END",
+		expired, fmt.Sprintf(inserted, "value"), fmt.Sprintf(increment, fmt.Sprintf(existing, "value")))
+	expiration = fmt.Sprintf("CASE WHEN %s THEN %s ELSE %s END",
+		expired, fmt.Sprintf(inserted, "expiration"), fmt.Sprintf(existing, "expiration"))
+	return value, expiration
+}
+
+// keyHasPrefix 匹配以 prefix 开头的 key，转义 prefix 中的 % _，
+// 避免默认前缀 owl_cache: 中的 _ 匹配到其他前缀
+func keyHasPrefix(prefix string) clause.Expression {
+	escaped := strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(prefix)
+	return clause.Expr{
+		SQL:  "? LIKE ? ESCAPE '!'",
+		Vars: []interface{}{clause.Column{Name: "key"}, escaped + "%"},
+	}
+}
+
+func (i *DatabaseStore) unserialize(data []byte) interface{} {
+	value, err := i.serializer.Unserialize(data)
+	if err != nil {
+		return nil
+	}
+	return value
+}
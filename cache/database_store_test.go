@@ -0,0 +1,171 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"owl/contract"
+	contractcache "owl/contract/cache"
+	"owl/database"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestDatabaseStore(t *testing.T, prefix string) *DatabaseStore {
+	t.Helper()
+	service := database.NewDatabaseService(database.NewSqliteGetter(&database.Options{
+		ServerConfig: contract.ServerConfig{Host: filepath.Join(t.TempDir(), "cache.db")},
+		Driver:       "sqlite",
+		MaxIdleConns: 1,
+		MaxConns:     1,
+	}))
+	store, err := NewDatabaseStore(service, prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestDatabaseStorePutGet(t *testing.T) {
+	store := newTestDatabaseStore(t, "owl:")
+
+	if !store.Put("name", "owl", 10) || !store.Put("name", "owl2", 10) {
+		t.Fatal("Put() failed")
+	}
+	if v := store.Get("name"); v != "owl2" {
+		t.Fatalf("Get() = %v, want owl2", v)
+	}
+
+	store.db.Model(&CacheItem{}).Where(map[string]interface{}{"key": "owl:name"}).
+		Update("expiration", time.Now().Unix()-1)
+	if v := store.Get("name"); v != nil {
+		t.Fatalf("expired key returned %v", v)
+	}
+
+	store.PutMany([]interface{}{"a", 1, "b", "two"}, 0)
+	values := store.Many([]string{"a", "missing", "b"})
+	if values[0] != 1 || values[1] != nil || values[2] != "two" {
+		t.Fatalf("Many() = %v", values)
+	}
+
+	if !store.Forget("a") || store.Get("a") != nil {
+		t.Fatal("Forget() did not remove the key")
+	}
+	if !store.Flush() || store.Get("b") != nil {
+		t.Fatal("Flush() did not remove the key")
+	}
+}
+
+func TestDatabaseStoreIncrement(t *testing.T) {
+	store := newTestDatabaseStore(t, "")
+
+	if n, ok := store.Increment("counter", 3); !ok || n != 3 {
+		t.Fatalf("Increment() on missing key = %d, %v", n, ok)
+	}
+	if n, ok := store.Increment("counter", 4); !ok || n != 7 {
+		t.Fatalf("Increment() = %d, %v", n, ok)
+	}
+	if n, ok := store.Decrement("counter", 10); !ok || n != -3 {
+		t.Fatalf("Decrement() = %d, %v", n, ok)
+	}
+	if v := store.Get("counter"); v != -3 {
+		t.Fatalf("Get() after Decrement = %v", v)
+	}
+
+	store.Put("text", "abc", 0)
+	if _, ok := store.Increment("text", 1); ok {
+		t.Fatal("Increment() on non numeric value should fail")
+	}
+}
+
+func TestDatabaseStoreConcurrentIncrement(t *testing.T) {
+	store := newTestDatabaseStore(t, "")
+
+	var wg sync.WaitGroup
+	for n := 0; n < 10; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			store.Increment("counter", 1)
+		}()
+	}
+	wg.Wait()
+
+	if v := store.Get("counter"); v != 10 {
+		t.Fatalf("Get() after concurrent Increment = %v, want 10", v)
+	}
+
+	store.Put("expired", 5, 10)
+	store.db.Model(&CacheItem{}).Where(map[string]interface{}{"key": "expired"}).
+		Update("expiration", time.Now().Unix()-1)
+	if n, ok := store.Increment("expired", 2); !ok || n != 2 {
+		t.Fatalf("Increment() on expired key = %d, %v", n, ok)
+	}
+}
+
+func TestDatabaseStorePrefixIsNotPattern(t *testing.T) {
+	store := newTestDatabaseStore(t, "owl_cache:")
+	other := &DatabaseStore{db: store.db, prefix: "owlxcache:", serializer: GobSerializer{}}
+
+	store.Put("a", 1, 0)
+	other.Put("b", 2, 0)
+	_ = other.Lock("job", 10, "").Get(context.Background(), nil)
+
+	if stats, err := store.Stats(); err != nil || stats.Items != 1 {
+		t.Fatalf("Stats() = %+v, %v", stats, err)
+	}
+	if locks, err := store.Locks(); err != nil || len(locks) != 0 {
+		t.Fatalf("Locks() = %v, %v", locks, err)
+	}
+	if !store.Flush() || other.Get("b") != 2 {
+		t.Fatal("Flush() removed another prefix")
+	}
+}
+
+func TestDatabaseLock(t *testing.T) {
+	store := newTestDatabaseStore(t, "")
+
+	first := store.Lock("job", 10, "")
+	if err := first.Get(context.Background(), nil); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	second := store.Lock("job", 10, "")
+	if err := second.Get(context.Background(), nil); !errors.Is(err, contractcache.LockNotAcquiredError) {
+		t.Fatalf("second Get() error = %v", err)
+	}
+	if second.Release() {
+		t.Fatal("Release() by another owner should fail")
+	}
+	if !store.RestoreLock("job", first.Owner()).Release() {
+		t.Fatal("Release() by the owner should succeed")
+	}
+
+	expired := store.Lock("expired", 10, "")
+	_ = expired.Get(context.Background(), nil)
+	store.db.Model(&CacheLockItem{}).Where(map[string]interface{}{"key": lockPrefix + "expired"}).
+		Update("expiration", time.Now().Unix()-1)
+	if err := store.Lock("expired", 10, "").Get(context.Background(), nil); err != nil {
+		t.Fatalf("expired lock should be acquirable, error = %v", err)
+	}
+}
+
+func TestDatabaseStoreStatsAndLocks(t *testing.T) {
+	store := newTestDatabaseStore(t, "owl:")
+	store.Put("a", "value", 10)
+	store.Put("b", "value", 10)
+	store.db.Model(&CacheItem{}).Where(map[string]interface{}{"key": "owl:b"}).
+		Update("expiration", time.Now().Unix()-1)
+
+	stats, err := store.Stats()
+	if err != nil || stats.Items != 2 || stats.Expired != 1 || stats.Bytes <= 0 {
+		t.Fatalf("Stats() = %+v, %v", stats, err)
+	}
+
+	lock := store.Lock("job", 10, "")
+	_ = lock.Get(context.Background(), nil)
+	locks, err := store.Locks()
+	if err != nil || len(locks) != 1 || locks[0].Name != "job" || locks[0].Owner != lock.Owner() || locks[0].ExpiresAt.IsZero() {
+		t.Fatalf("Locks() = %+v, %v", locks, err)
+	}
+}
@@ -1 +1,84 @@
 package cache
+
+import (
+	"context"
+	contractcache "owl/contract/cache"
+)
+
+// DynamoLock 实现 contract\cache\lock 接口
+// 使用条件写入加锁，值为持有者标识；释放时使用条件删除，只有持有者才能删除
+type DynamoLock struct {
+	baseLock
+	store *DynamoStore
+}
+
+// NewDynamoLock name 为锁在表中的完整 key，owner 为空时随机生成
+func NewDynamoLock(store *DynamoStore, name string, seconds int, owner string) *DynamoLock {
+	return &DynamoLock{
+		baseLock: newBaseLock(name, seconds, owner),
+		store:    store,
+	}
+}
+
+func (i *DynamoLock) Get(ctx context.Context, callback func()) error {
+	return i.get(ctx, i.acquire, i.Release, callback)
+}
+
+func (i *DynamoLock) Block(ctx context.Context, seconds int, callback func()) error {
+	return i.block(ctx, seconds, i.acquire, i.Release, callback)
+}
+
+func (i *DynamoLock) Release() bool {
+	return i.store.call("DeleteItem", map[string]any{
+		"TableName":           i.store.table,
+		"Key":                 i.store.itemKey(i.name),
+		"ConditionExpression": "#value = :owner",
+		"ExpressionAttributeNames": map[string]string{
+			"#value": i.store.valueAttr,
+		},
+		"ExpressionAttributeValues": map[string]dynamoValue{
+			":owner": dynamoString(i.owner),
+		},
+	}, nil) == nil
+}
+
+func (i *DynamoLock) ForceRelease() {
+	_ = i.store.call("DeleteItem", map[string]any{
+		"TableName": i.store.table,
+		"Key":       i.store.itemKey(i.name),
+	}, nil)
+}
+
+// CurrentOwner 返回表中记录的持有者，锁不存在或已过期时返回空字符串
+func (i *DynamoLock) CurrentOwner() string {
+	var output struct {
+		Item dynamoItem
+	}
+	err := i.store.call("GetItem", map[string]any{
+		"TableName":      i.store.table,
+		"Key":            i.store.itemKey(i.name),
+		"ConsistentRead": true,
+	}, &output)
+	if err != nil || output.Item == nil {
+		return ""
+	}
+	owner, _ := i.store.value(output.Item).(string)
+	return owner
+}
+
+func (i *DynamoLock) acquire() bool {
+	item := i.store.itemKey(i.name)
+	item[i.store.valueAttr] = dynamoString(i.owner)
+	item[i.store.expireAttr] = dynamoNumber(i.store.expiration(i.seconds))
+	return i.store.putIfMissing(item) == nil
+}
+
+// Lock 实现 contract\cache\lock_provider 接口
+func (i *DynamoStore) Lock(name string, seconds int, owner string) contractcache.Lock {
+	return NewDynamoLock(i, i.prefix+lockPrefix+name, seconds, owner)
+}
+
+// RestoreLock 使用持有者标识恢复锁，可以在其他进程中释放这个锁
+func (i *DynamoStore) RestoreLock(name, owner string) contractcache.Lock {
+	return i.Lock(name, 0, owner)
+}
@@ -1 +1,405 @@
 package cache
+
+import (
+	"context"
+	"errors"
+	"owl"
+	"strconv"
+	"time"
+)
+
+const (
+	dynamoBatchGetSize   = 100 // BatchGetItem 一次最多读取的条目数
+	dynamoBatchWriteSize = 25  // BatchWriteItem 一次最多写入的条目数
+)
+
+type DynamoOptions struct {
+	Key                 string `json:"key"`
+	Secret              string `json:"secret"`
+	Token               string `json:"token"`
+	Region              string `json:"region"`
+	Endpoint            string `json:"endpoint"` // 为空时使用 AWS 的地址，可以指向 DynamoDB Local
+	Table               string `json:"table"`
+	Prefix              string `json:"prefix"`
+	Timeout             int    `json:"timeout"`              // 请求超时，毫秒
+	KeyAttribute        string `json:"key-attribute"`        // 分区键属性名，默认 key
+	ValueAttribute      string `json:"value-attribute"`      // 值属性名，默认 value
+	ExpirationAttribute string `json:"expiration-attribute"` // 过期时间属性名，默认 expires_at，需要在表上开启 TTL
+}
+
+// NewDynamoOption 读取 DynamoDB 配置，cfgFile 为 conf 目录下的文件名，例如 dynamodb 对应 conf/dynamodb.yml
+func NewDynamoOption(cfgManager *owl.ConfManager, cfgFile string) (opt *DynamoOptions) {
+	err := cfgManager.GetConfig(cfgFile, &opt)
+	if err != nil {
+		return nil
+	}
+	return opt
+}
+
+func DefaultDynamoOptions() *DynamoOptions {
+	return &DynamoOptions{
+		Region:              "us-east-1",
+		Table:               "cache",
+		Timeout:             5000,
+		KeyAttribute:        "key",
+		ValueAttribute:      "value",
+		ExpirationAttribute: "expires_at",
+	}
+}
+
+// DynamoStore DynamoDB 缓存，实现 contract\cache\store 接口
+// 整数保存为数字类型，可以使用 Increment；其他值序列化后保存为二进制类型。
+// DynamoDB 的 TTL 删除有延迟，读取时会检查过期时间属性。DynamoDB 不支持清空表，Flush 返回 false
+type DynamoStore struct {
+	client     *DynamoClient
+	table      string
+	prefix     string
+	keyAttr    string
+	valueAttr  string
+	expireAttr string
+	serializer Serializer
+	now        func() time.Time
+}
+
+// NewDynamoStore opt 为 nil 时使用默认配置，未配置的字段使用默认值
+func NewDynamoStore(opt *DynamoOptions) *DynamoStore {
+	defaults := DefaultDynamoOptions()
+	if opt == nil {
+		opt = defaults
+	}
+
+	timeout := time.Duration(opt.Timeout) * time.Millisecond
+	region := stringOr(opt.Region, defaults.Region)
+	return &DynamoStore{
+		client:     NewDynamoClient(opt.Endpoint, region, opt.Key, opt.Secret, opt.Token, timeout),
+		table:      stringOr(opt.Table, defaults.Table),
+		prefix:     opt.Prefix,
+		keyAttr:    stringOr(opt.KeyAttribute, defaults.KeyAttribute),
+		valueAttr:  stringOr(opt.ValueAttribute, defaults.ValueAttribute),
+		expireAttr: stringOr(opt.ExpirationAttribute, defaults.ExpirationAttribute),
+		serializer: GobSerializer{},
+		now:        time.Now,
+	}
+}
+
+// NewDynamoStoreFromConfig 使用 conf/{cfgFile}.yml 中的配置创建 DynamoDB 缓存
+func NewDynamoStoreFromConfig(cfgManager *owl.ConfManager, cfgFile string) *DynamoStore {
+	return NewDynamoStore(NewDynamoOption(cfgManager, cfgFile))
+}
+
+// Client 返回底层的 DynamoDB 客户端
+func (i *DynamoStore) Client() *DynamoClient {
+	return i.client
+}
+
+// Table 返回缓存表名
+func (i *DynamoStore) Table() string {
+	return i.table
+}
+
+func (i *DynamoStore) Get(key interface{}) interface{} {
+	if keys, ok := key.([]string); ok {
+		return i.Many(keys)
+	}
+
+	var output struct {
+		Item dynamoItem
+	}
+	err := i.call("GetItem", map[string]any{
+		"TableName":      i.table,
+		"Key":            i.itemKey(i.prefix + keyString(key)),
+		"ConsistentRead": true,
+	}, &output)
+	if err != nil || output.Item == nil {
+		return nil
+	}
+	return i.value(output.Item)
+}
+
+// Many 使用 BatchGetItem 每 100 个 key 一次取回
+func (i *DynamoStore) Many(keys []string) []interface{} {
+	values := make([]interface{}, len(keys))
+	positions := make(map[string][]int, len(keys))
+	for n, key := range keys {
+		positions[i.prefix+key] = append(positions[i.prefix+key], n)
+	}
+
+	pending := make([]string, 0, len(positions))
+	for key := range positions {
+		pending = append(pending, key)
+	}
+
+	for len(pending) > 0 {
+		size := len(pending)
+		if size > dynamoBatchGetSize {
+			size = dynamoBatchGetSize
+		}
+		batch := pending[:size]
+		pending = pending[size:]
+
+		requestKeys := make([]dynamoItem, len(batch))
+		for n, key := range batch {
+			requestKeys[n] = i.itemKey(key)
+		}
+
+		var output struct {
+			Responses       map[string][]dynamoItem
+			UnprocessedKeys map[string]struct {
+				Keys []dynamoItem
+			}
+		}
+		err := i.call("BatchGetItem", map[string]any{
+			"RequestItems": map[string]any{
+				i.table: map[string]any{"Keys": requestKeys, "ConsistentRead": true},
+			},
+		}, &output)
+		if err != nil {
+			return values
+		}
+
+		for _, item := range output.Responses[i.table] {
+			key := item[i.keyAttr].S
+			if key == nil {
+				continue
+			}
+			value := i.value(item)
+			for _, n := range positions[*key] {
+				values[n] = value
+			}
+		}
+		// 超出吞吐量时没有处理的 key 放回队列重试
+		for _, item := range output.UnprocessedKeys[i.table].Keys {
+			if key := item[i.keyAttr].S; key != nil {
+				pending = append(pending, *key)
+			}
+		}
+	}
+	return values
+}
+
+func (i *DynamoStore) Put(key string, value interface{}, seconds int) bool {
+	item, err := i.item(i.prefix+key, value, seconds)
+	if err != nil {
+		return false
+	}
+	return i.call("PutItem", map[string]any{
+		"TableName": i.table,
+		"Item":      item,
+	}, nil) == nil
+}
+
+// PutMany 使用 BatchWriteItem 每 25 个条目一次写入
+func (i *DynamoStore) PutMany(values []interface{}, seconds int) bool {
+	entries, ok := pairs(values)
+	if !ok {
+		return false
+	}
+
+	requests := make([]any, 0, len(entries))
+	for key, value := range entries {
+		item, err := i.item(i.prefix+key, value, seconds)
+		if err != nil {
+			return false
+		}
+		requests = append(requests, map[string]any{"PutRequest": map[string]any{"Item": item}})
+	}
+
+	for len(requests) > 0 {
+		size := len(requests)
+		if size > dynamoBatchWriteSize {
+			size = dynamoBatchWriteSize
+		}
+		batch := requests[:size]
+		requests = requests[size:]
+
+		var output struct {
+			UnprocessedItems map[string][]any
+		}
+		err := i.call("BatchWriteItem", map[string]any{
+			"RequestItems": map[string]any{i.table: batch},
+		}, &output)
+		if err != nil {
+			return false
+		}
+		requests = append(requests, output.UnprocessedItems[i.table]...)
+	}
+	return true
+}
+
+// Add 使用条件写入，key 不存在或已过期时才写入
+func (i *DynamoStore) Add(key string, value interface{}, seconds int) bool {
+	item, err := i.item(i.prefix+key, value, seconds)
+	if err != nil {
+		return false
+	}
+	return i.putIfMissing(item) == nil
+}
+
+// Increment 使用 UpdateItem 原子自增，key 不存在或已过期时写入 value
+func (i *DynamoStore) Increment(key string, value int) (int, bool) {
+	prefixed := i.prefix + key
+
+	for attempt := 0; attempt < 2; attempt++ {
+		var output struct {
+			Attributes dynamoItem
+		}
+		err := i.call("UpdateItem", map[string]any{
+			"TableName":           i.table,
+			"Key":                 i.itemKey(prefixed),
+			"ConditionExpression": "attribute_exists(#key) AND #expires_at > :now",
+			"UpdateExpression":    "SET #value = #value + :amount",
+			"ExpressionAttributeNames": map[string]string{
+				"#key":        i.keyAttr,
+				"#value":      i.valueAttr,
+				"#expires_at": i.expireAttr,
+			},
+			"ExpressionAttributeValues": map[string]dynamoValue{
+				":now":    dynamoNumber(i.now().Unix()),
+				":amount": dynamoNumber(int64(value)),
+			},
+			"ReturnValues": "UPDATED_NEW",
+		}, &output)
+		if err == nil {
+			n := output.Attributes[i.valueAttr].N
+			if n == nil {
+				return 0, false
+			}
+			result, err := strconv.Atoi(*n)
+			return result, err == nil
+		}
+		if !conditionalCheckFailed(err) {
+			return 0, false
+		}
+
+		// 不存在或已过期，尝试写入初始值，被并发写入时再自增一次
+		item, _ := i.item(prefixed, value, 0)
+		err = i.putIfMissing(item)
+		if err == nil {
+			return value, true
+		}
+		if !conditionalCheckFailed(err) {
+			return 0, false
+		}
+	}
+	return 0, false
+}
+
+func (i *DynamoStore) Decrement(key string, value int) (int, bool) {
+	return i.Increment(key, -value)
+}
+
+func (i *DynamoStore) Forever(key string, value interface{}) bool {
+	return i.Put(key, value, 0)
+}
+
+func (i *DynamoStore) Forget(key string) bool {
+	var output struct {
+		Attributes dynamoItem
+	}
+	err := i.call("DeleteItem", map[string]any{
+		"TableName":    i.table,
+		"Key":          i.itemKey(i.prefix + key),
+		"ReturnValues": "ALL_OLD",
+	}, &output)
+	return err == nil && len(output.Attributes) > 0
+}
+
+// Flush DynamoDB 不支持清空表，需要清空时删除并重建表
+func (i *DynamoStore) Flush() bool {
+	return false
+}
+
+func (i *DynamoStore) GetPrefix() string {
+	return i.prefix
+}
+
+// putIfMissing 条件写入，已存在未过期的条目时返回 ConditionalCheckFailedException
+func (i *DynamoStore) putIfMissing(item dynamoItem) error {
+	return i.call("PutItem", map[string]any{
+		"TableName":           i.table,
+		"Item":                item,
+		"ConditionExpression": "attribute_not_exists(#key) OR #expires_at <= :now",
+		"ExpressionAttributeNames": map[string]string{
+			"#key":        i.keyAttr,
+			"#expires_at": i.expireAttr,
+		},
+		"ExpressionAttributeValues": map[string]dynamoValue{
+			":now": dynamoNumber(i.now().Unix()),
+		},
+	}, nil)
+}
+
+func (i *DynamoStore) call(operation string, input, output any) error {
+	return i.client.Call(context.Background(), operation, input, output)
+}
+
+func (i *DynamoStore) itemKey(key string) dynamoItem {
+	return dynamoItem{i.keyAttr: dynamoString(key)}
+}
+
+// item 整数保存为数字类型，其他值序列化后保存为二进制类型
+func (i *DynamoStore) item(key string, value interface{}, seconds int) (dynamoItem, error) {
+	item := i.itemKey(key)
+	item[i.expireAttr] = dynamoNumber(i.expiration(seconds))
+
+	switch value.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		n, _ := toInt(value)
+		item[i.valueAttr] = dynamoNumber(int64(n))
+		return item, nil
+	}
+
+	data, err := i.serializer.Serialize(value)
+	if err != nil {
+		return nil, err
+	}
+	item[i.valueAttr] = dynamoValue{B: data}
+	return item, nil
+}
+
+// value 读取未过期条目的值，DynamoDB 的 TTL 删除有延迟，可能读到已过期的条目
+func (i *DynamoStore) value(item dynamoItem) interface{} {
+	if expiration := item[i.expireAttr].N; expiration != nil {
+		if ts, err := strconv.ParseInt(*expiration, 10, 64); err == nil && ts <= i.now().Unix() {
+			return nil
+		}
+	}
+
+	attr := item[i.valueAttr]
+	switch {
+	case attr.N != nil:
+		n, err := strconv.Atoi(*attr.N)
+		if err != nil {
+			return nil
+		}
+		return n
+	case attr.S != nil:
+		return *attr.S
+	case attr.B != nil:
+		value, err := i.serializer.Unserialize(attr.B)
+		if err != nil {
+			return nil
+		}
+		return value
+	}
+	return nil
+}
+
+func (i *DynamoStore) expiration(seconds int) int64 {
+	if seconds <= 0 {
+		return foreverTimestamp
+	}
+	return i.now().Unix() + int64(seconds)
+}
+
+func stringOr(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+func conditionalCheckFailed(err error) bool {
+	var e *DynamoError
+	return errors.As(err, &e) && e.ConditionalCheckFailed()
+}
@@ -0,0 +1,153 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"owl/cache/dynamotest"
+	contractcache "owl/contract/cache"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestDynamoStore(t *testing.T, prefix string) (*DynamoStore, *dynamotest.Server) {
+	t.Helper()
+	server := dynamotest.NewServer()
+	t.Cleanup(server.Close)
+	return NewDynamoStore(&DynamoOptions{
+		Key:      "test",
+		Secret:   "secret",
+		Region:   "local",
+		Endpoint: server.URL,
+		Table:    "cache",
+		Prefix:   prefix,
+	}), server
+}
+
+func TestDynamoStorePutGet(t *testing.T) {
+	store, server := newTestDynamoStore(t, "owl:")
+
+	user := redisTestUser{Name: "owl", Age: 3}
+	if !store.Put("user", user, 10) {
+		t.Fatal("Put() failed")
+	}
+	if got, ok := store.Get("user").(redisTestUser); !ok || got != user {
+		t.Fatalf("Get() = %#v, want %#v", store.Get("user"), user)
+	}
+	if _, ok := server.Item("cache", "owl:user"); !ok {
+		t.Fatal("key should be stored with prefix")
+	}
+
+	// 服务端还没删除已过期的条目时也不能读到
+	store.now = func() time.Time { return time.Now().Add(11 * time.Second) }
+	if store.Get("user") != nil {
+		t.Fatal("expired item should miss")
+	}
+	if !store.Add("user", "new", 10) {
+		t.Fatal("Add() should overwrite an expired item")
+	}
+	store.now = time.Now
+
+	if store.Add("user", "other", 10) {
+		t.Fatal("Add() should not overwrite a live item")
+	}
+	if !store.Forget("user") || store.Forget("user") {
+		t.Fatal("Forget() should report whether the item existed")
+	}
+	if store.Flush() {
+		t.Fatal("Flush() is not supported")
+	}
+}
+
+func TestDynamoStoreManyAndIncrement(t *testing.T) {
+	store, _ := newTestDynamoStore(t, "")
+
+	values := make([]interface{}, 0, 120)
+	keys := make([]string, 0, 61)
+	for n := 0; n < 60; n++ {
+		values = append(values, fmt.Sprintf("k%d", n), n)
+		keys = append(keys, fmt.Sprintf("k%d", n))
+	}
+	if !store.PutMany(values, 0) {
+		t.Fatal("PutMany() failed")
+	}
+	keys = append(keys, "missing")
+	got := store.Many(keys)
+	if got[0] != 0 || got[59] != 59 || got[60] != nil {
+		t.Fatalf("Many() = %v", got)
+	}
+
+	if n, ok := store.Increment("k5", 10); !ok || n != 15 {
+		t.Fatalf("Increment() = %d, %v", n, ok)
+	}
+	if n, ok := store.Decrement("counter", 2); !ok || n != -2 {
+		t.Fatalf("Decrement() on missing key = %d, %v", n, ok)
+	}
+	store.Put("name", "owl", 0)
+	if _, ok := store.Increment("name", 1); ok {
+		t.Fatal("Increment() on a non-numeric value should fail")
+	}
+}
+
+func TestDynamoLock(t *testing.T) {
+	store, server := newTestDynamoStore(t, "owl:")
+
+	first := store.Lock("job", 10, "")
+	if err := first.Get(context.Background(), nil); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	second := store.Lock("job", 10, "")
+	if err := second.Get(context.Background(), nil); !errors.Is(err, contractcache.LockNotAcquiredError) {
+		t.Fatalf("second Get() error = %v", err)
+	}
+	if second.Release() {
+		t.Fatal("Release() by another owner should fail")
+	}
+	if owner := first.(*DynamoLock).CurrentOwner(); owner != first.Owner() {
+		t.Fatalf("CurrentOwner() = %q, want %q", owner, first.Owner())
+	}
+	if !store.RestoreLock("job", first.Owner()).Release() {
+		t.Fatal("Release() by the owner should succeed")
+	}
+	if _, ok := server.Item("cache", "owl:lock:job"); ok {
+		t.Fatal("released lock should be deleted")
+	}
+
+	_ = store.Lock("expired", 1, "").Get(context.Background(), nil)
+	store.now = func() time.Time { return time.Now().Add(2 * time.Second) }
+	if err := store.Lock("expired", 1, "").Get(context.Background(), nil); err != nil {
+		t.Fatalf("expired lock should be acquirable, error = %v", err)
+	}
+}
+
+func TestDynamoClientSignsRequests(t *testing.T) {
+	var authorization, target string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		target = r.Header.Get("X-Amz-Target")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"__type":"com.amazonaws.dynamodb.v20120810#ConditionalCheckFailedException","message":"failed"}`))
+	}))
+	defer server.Close()
+
+	client := NewDynamoClient(server.URL, "us-east-1", "AKIDEXAMPLE", "secret", "", 0)
+	client.now = func() time.Time { return time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC) }
+
+	err := client.Call(context.Background(), "PutItem", map[string]any{"TableName": "cache"}, nil)
+	var e *DynamoError
+	if !errors.As(err, &e) || !e.ConditionalCheckFailed() {
+		t.Fatalf("Call() error = %v, want ConditionalCheckFailedException", err)
+	}
+	if target != "DynamoDB_20120810.PutItem" {
+		t.Fatalf("X-Amz-Target = %q", target)
+	}
+
+	prefix := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20240102/us-east-1/dynamodb/aws4_request, " +
+		"SignedHeaders=content-type;host;x-amz-date;x-amz-target, Signature="
+	if !strings.HasPrefix(authorization, prefix) || len(authorization) != len(prefix)+64 {
+		t.Fatalf("Authorization = %q", authorization)
+	}
+}
@@ -0,0 +1,554 @@
+// Package dynamotest 提供进程内的 DynamoDB HTTP 服务，用于离线测试 DynamoDB 缓存
+//
+// 实现了 GetItem PutItem DeleteItem UpdateItem BatchGetItem BatchWriteItem 接口，
+// 条件表达式支持 attribute_exists、attribute_not_exists、比较运算和 AND、OR，
+// 更新表达式只支持 SET。表在第一次使用时自动创建，分区键属性名为 key，
+// 其他分区键需要先调用 CreateTable；和 DynamoDB 一样不会立即删除已过期的条目
+package dynamotest
+
+import (
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const errorPrefix = "com.amazonaws.dynamodb.v20120810#"
+
+// Value 属性值，例如 {"S": "owl"}、{"N": "1"}
+type Value map[string]any
+
+// Item 一个条目的所有属性
+type Item map[string]Value
+
+// Server 进程内的 DynamoDB 服务
+type Server struct {
+	*httptest.Server
+
+	mu     sync.Mutex
+	tables map[string]*table
+}
+
+type table struct {
+	keyAttr string
+	items   map[string]Item // 分区键的值 => 条目
+}
+
+// NewServer 启动服务，URL 字段为 endpoint
+func NewServer() *Server {
+	server := &Server{
+		tables: make(map[string]*table),
+	}
+	server.Server = httptest.NewServer(http.HandlerFunc(server.handle))
+	return server
+}
+
+// CreateTable 创建分区键属性名为 keyAttr 的表，已存在时清空
+func (i *Server) CreateTable(name, keyAttr string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.tables[name] = &table{keyAttr: keyAttr, items: make(map[string]Item)}
+}
+
+// Len 返回表中的条目数，包含已过期还未删除的条目
+func (i *Server) Len(name string) int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return len(i.table(name).items)
+}
+
+// Item 返回表中分区键的值为 key 的条目
+func (i *Server) Item(name, key string) (Item, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	item, ok := i.table(name).items[key]
+	return item, ok
+}
+
+type apiError struct {
+	status  int
+	errType string
+	message string
+}
+
+func (i *apiError) Error() string {
+	return i.errType + ": " + i.message
+}
+
+func validationError(format string, a ...any) *apiError {
+	return &apiError{http.StatusBadRequest, "ValidationException", fmt.Sprintf(format, a...)}
+}
+
+var conditionalCheckFailed = &apiError{http.StatusBadRequest, "ConditionalCheckFailedException", "The conditional request failed"}
+
+func (i *Server) handle(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=") || r.Header.Get("X-Amz-Date") == "" {
+		writeError(w, &apiError{http.StatusBadRequest, "MissingAuthenticationTokenException", "Request is missing Authentication Token"})
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, validationError("%s", err))
+		return
+	}
+	var input map[string]jsoniter.RawMessage
+	if err = jsoniter.Unmarshal(body, &input); err != nil {
+		writeError(w, &apiError{http.StatusBadRequest, "SerializationException", err.Error()})
+		return
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	var output any
+	target := strings.TrimPrefix(r.Header.Get("X-Amz-Target"), "DynamoDB_20120810.")
+	switch target {
+	case "GetItem":
+		output, err = i.getItem(input)
+	case "PutItem":
+		output, err = i.putItem(input)
+	case "DeleteItem":
+		output, err = i.deleteItem(input)
+	case "UpdateItem":
+		output, err = i.updateItem(input)
+	case "BatchGetItem":
+		output, err = i.batchGetItem(input)
+	case "BatchWriteItem":
+		output, err = i.batchWriteItem(input)
+	default:
+		err = &apiError{http.StatusBadRequest, "UnknownOperationException", target}
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+	data, _ := jsoniter.Marshal(output)
+	_, _ = w.Write(data)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	e, ok := err.(*apiError)
+	if !ok {
+		e = validationError("%s", err)
+	}
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+	w.WriteHeader(e.status)
+	data, _ := jsoniter.Marshal(map[string]string{"__type": errorPrefix + e.errType, "message": e.message})
+	_, _ = w.Write(data)
+}
+
+// request 单个条目操作的公共参数
+type request struct {
+	TableName                 string
+	Key                       Item
+	Item                      Item
+	ConditionExpression       string
+	UpdateExpression          string
+	ExpressionAttributeNames  map[string]string
+	ExpressionAttributeValues map[string]Value
+	ReturnValues              string
+}
+
+func decode(input map[string]jsoniter.RawMessage) (*request, error) {
+	data, _ := jsoniter.Marshal(input)
+	req := &request{}
+	if err := jsoniter.Unmarshal(data, req); err != nil {
+		return nil, validationError("%s", err)
+	}
+	if req.TableName == "" {
+		return nil, validationError("TableName is required")
+	}
+	return req, nil
+}
+
+// table 调用方需要持有锁，表不存在时创建
+func (i *Server) table(name string) *table {
+	t, ok := i.tables[name]
+	if !ok {
+		t = &table{keyAttr: "key", items: make(map[string]Item)}
+		i.tables[name] = t
+	}
+	return t
+}
+
+// key 返回条目或 Key 参数中分区键的值，只支持只有分区键的表
+func (i *table) key(item Item) (string, error) {
+	value, ok := item[i.keyAttr]
+	if !ok {
+		return "", validationError("one of the required keys was not given a value")
+	}
+	return fmt.Sprint(value.raw()), nil
+}
+
+func (i *Server) getItem(input map[string]jsoniter.RawMessage) (any, error) {
+	req, err := decode(input)
+	if err != nil {
+		return nil, err
+	}
+	t := i.table(req.TableName)
+	key, err := t.key(req.Key)
+	if err != nil {
+		return nil, err
+	}
+	output := map[string]any{}
+	if item, ok := t.items[key]; ok {
+		output["Item"] = item
+	}
+	return output, nil
+}
+
+func (i *Server) putItem(input map[string]jsoniter.RawMessage) (any, error) {
+	req, err := decode(input)
+	if err != nil {
+		return nil, err
+	}
+	t := i.table(req.TableName)
+	key, err := t.key(req.Item)
+	if err != nil {
+		return nil, err
+	}
+
+	old := t.items[key]
+	if err = req.check(old); err != nil {
+		return nil, err
+	}
+	t.items[key] = req.Item
+	return returnValues(req.ReturnValues, old, nil), nil
+}
+
+func (i *Server) deleteItem(input map[string]jsoniter.RawMessage) (any, error) {
+	req, err := decode(input)
+	if err != nil {
+		return nil, err
+	}
+	t := i.table(req.TableName)
+	key, err := t.key(req.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	old := t.items[key]
+	if err = req.check(old); err != nil {
+		return nil, err
+	}
+	delete(t.items, key)
+	return returnValues(req.ReturnValues, old, nil), nil
+}
+
+func (i *Server) updateItem(input map[string]jsoniter.RawMessage) (any, error) {
+	req, err := decode(input)
+	if err != nil {
+		return nil, err
+	}
+	t := i.table(req.TableName)
+	key, err := t.key(req.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	old := t.items[key]
+	if err = req.check(old); err != nil {
+		return nil, err
+	}
+
+	item := Item{}
+	for name, value := range old {
+		item[name] = value
+	}
+	for name, value := range req.Key {
+		item[name] = value
+	}
+	updated, err := req.update(item)
+	if err != nil {
+		return nil, err
+	}
+	t.items[key] = item
+	return returnValues(req.ReturnValues, old, updated), nil
+}
+
+func (i *Server) batchGetItem(input map[string]jsoniter.RawMessage) (any, error) {
+	var requestItems map[string]struct {
+		Keys []Item
+	}
+	if err := jsoniter.Unmarshal(input["RequestItems"], &requestItems); err != nil {
+		return nil, validationError("%s", err)
+	}
+
+	responses := make(map[string][]Item)
+	for name, request := range requestItems {
+		if len(request.Keys) > 100 {
+			return nil, validationError("too many items requested for the BatchGetItem call")
+		}
+		t := i.table(name)
+		items := make([]Item, 0, len(request.Keys))
+		for _, k := range request.Keys {
+			key, err := t.key(k)
+			if err != nil {
+				return nil, err
+			}
+			if item, ok := t.items[key]; ok {
+				items = append(items, item)
+			}
+		}
+		responses[name] = items
+	}
+	return map[string]any{"Responses": responses, "UnprocessedKeys": map[string]any{}}, nil
+}
+
+func (i *Server) batchWriteItem(input map[string]jsoniter.RawMessage) (any, error) {
+	var requestItems map[string][]struct {
+		PutRequest *struct {
+			Item Item
+		}
+		DeleteRequest *struct {
+			Key Item
+		}
+	}
+	if err := jsoniter.Unmarshal(input["RequestItems"], &requestItems); err != nil {
+		return nil, validationError("%s", err)
+	}
+
+	for name, requests := range requestItems {
+		if len(requests) > 25 {
+			return nil, validationError("too many items requested for the BatchWriteItem call")
+		}
+		t := i.table(name)
+		for _, request := range requests {
+			switch {
+			case request.PutRequest != nil:
+				key, err := t.key(request.PutRequest.Item)
+				if err != nil {
+					return nil, err
+				}
+				t.items[key] = request.PutRequest.Item
+			case request.DeleteRequest != nil:
+				key, err := t.key(request.DeleteRequest.Key)
+				if err != nil {
+					return nil, err
+				}
+				delete(t.items, key)
+			}
+		}
+	}
+	return map[string]any{"UnprocessedItems": map[string]any{}}, nil
+}
+
+func returnValues(mode string, old Item, updated Item) map[string]any {
+	output := map[string]any{}
+	switch mode {
+	case "ALL_OLD":
+		if old != nil {
+			output["Attributes"] = old
+		}
+	case "UPDATED_NEW", "ALL_NEW":
+		output["Attributes"] = updated
+	}
+	return output
+}
+
+// check 计算条件表达式，条件不满足时返回 ConditionalCheckFailedException
+func (i *request) check(item Item) error {
+	if i.ConditionExpression == "" {
+		return nil
+	}
+	ok, err := i.evaluate(item, i.ConditionExpression)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return conditionalCheckFailed
+	}
+	return nil
+}
+
+// evaluate OR 的优先级低于 AND，不支持括号分组
+func (i *request) evaluate(item Item, expression string) (bool, error) {
+	for _, or := range strings.Split(expression, " OR ") {
+		matched := true
+		for _, and := range strings.Split(or, " AND ") {
+			ok, err := i.condition(item, strings.TrimSpace(and))
+			if err != nil {
+				return false, err
+			}
+			if !ok {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (i *request) condition(item Item, condition string) (bool, error) {
+	for _, fn := range []string{"attribute_not_exists", "attribute_exists"} {
+		if strings.HasPrefix(condition, fn+"(") && strings.HasSuffix(condition, ")") {
+			name, err := i.name(strings.TrimSuffix(strings.TrimPrefix(condition, fn+"("), ")"))
+			if err != nil {
+				return false, err
+			}
+			_, exists := item[name]
+			return exists == (fn == "attribute_exists"), nil
+		}
+	}
+
+	parts := strings.Fields(condition)
+	if len(parts) != 3 {
+		return false, validationError("unsupported condition %q", condition)
+	}
+	left, err := i.operand(item, parts[0])
+	if err != nil {
+		return false, err
+	}
+	right, err := i.operand(item, parts[2])
+	if err != nil {
+		return false, err
+	}
+	if left == nil || right == nil {
+		return false, nil
+	}
+
+	c, err := compare(left, right)
+	if err != nil {
+		return false, err
+	}
+	switch parts[1] {
+	case "=":
+		return c == 0, nil
+	case "<>":
+		return c != 0, nil
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	case ">=":
+		return c >= 0, nil
+	}
+	return false, validationError("unsupported operator %q", parts[1])
+}
+
+// update 执行 SET 更新表达式，返回更新后的属性
+func (i *request) update(item Item) (Item, error) {
+	expression := strings.TrimSpace(i.UpdateExpression)
+	if !strings.HasPrefix(expression, "SET ") {
+		return nil, validationError("unsupported update expression %q", expression)
+	}
+
+	updated := Item{}
+	for _, assignment := range strings.Split(strings.TrimPrefix(expression, "SET "), ",") {
+		parts := strings.Fields(assignment)
+		if len(parts) != 3 && len(parts) != 5 || parts[1] != "=" {
+			return nil, validationError("unsupported assignment %q", assignment)
+		}
+		name, err := i.name(parts[0])
+		if err != nil {
+			return nil, err
+		}
+		value, err := i.operand(item, parts[2])
+		if err != nil {
+			return nil, err
+		}
+		if value == nil {
+			return nil, validationError("the provided expression refers to an attribute that does not exist in the item")
+		}
+
+		if len(parts) == 5 {
+			other, err := i.operand(item, parts[4])
+			if err != nil {
+				return nil, err
+			}
+			if value, err = arithmetic(value, other, parts[3]); err != nil {
+				return nil, err
+			}
+		}
+		item[name] = value
+		updated[name] = value
+	}
+	return updated, nil
+}
+
+func (i *request) name(placeholder string) (string, error) {
+	if !strings.HasPrefix(placeholder, "#") {
+		return placeholder, nil
+	}
+	name, ok := i.ExpressionAttributeNames[placeholder]
+	if !ok {
+		return "", validationError("undefined attribute name %s", placeholder)
+	}
+	return name, nil
+}
+
+// operand :value 为表达式中的值，其他为属性名，属性不存在时返回 nil
+func (i *request) operand(item Item, token string) (Value, error) {
+	if strings.HasPrefix(token, ":") {
+		value, ok := i.ExpressionAttributeValues[token]
+		if !ok {
+			return nil, validationError("undefined attribute value %s", token)
+		}
+		return value, nil
+	}
+	name, err := i.name(token)
+	if err != nil {
+		return nil, err
+	}
+	return item[name], nil
+}
+
+func (i Value) raw() any {
+	for _, value := range i {
+		return value
+	}
+	return nil
+}
+
+func (i Value) number() (float64, bool) {
+	n, ok := i["N"].(string)
+	if !ok {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(n, 64)
+	return f, err == nil
+}
+
+func compare(a, b Value) (int, error) {
+	if x, ok := a.number(); ok {
+		y, ok := b.number()
+		if !ok {
+			return 0, validationError("type mismatch in comparison")
+		}
+		switch {
+		case x < y:
+			return -1, nil
+		case x > y:
+			return 1, nil
+		}
+		return 0, nil
+	}
+	return strings.Compare(fmt.Sprint(a.raw()), fmt.Sprint(b.raw())), nil
+}
+
+func arithmetic(a, b Value, operator string) (Value, error) {
+	x, ok1 := a.number()
+	y, ok2 := b.number()
+	if !ok1 || !ok2 {
+		return nil, validationError("an operand in the update expression has an incorrect data type")
+	}
+	switch operator {
+	case "+":
+		return Value{"N": strconv.FormatFloat(x+y, 'f', -1, 64)}, nil
+	case "-":
+		return Value{"N": strconv.FormatFloat(x-y, 'f', -1, 64)}, nil
+	}
+	return nil, validationError("unsupported operator %q", operator)
+}
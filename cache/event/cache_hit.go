@@ -1 +1,11 @@
 package event
+
+// CacheHit 读取缓存命中
+type CacheHit struct {
+	CacheEvent
+	Value any
+}
+
+func (CacheHit) EventName() string {
+	return CacheHitName
+}
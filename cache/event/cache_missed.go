@@ -1 +1,10 @@
 package event
+
+// CacheMissed 读取缓存未命中
+type CacheMissed struct {
+	CacheEvent
+}
+
+func (CacheMissed) EventName() string {
+	return CacheMissedName
+}
@@ -0,0 +1,59 @@
+package event
+
+import "sync"
+
+// AllEvents 监听所有事件时使用的名称
+const AllEvents = "*"
+
+type Listener func(e Event)
+
+// Dispatcher 同步的事件分发器，监听者按注册顺序执行
+type Dispatcher struct {
+	lock      sync.RWMutex
+	listeners map[string][]Listener
+}
+
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{
+		listeners: make(map[string][]Listener),
+	}
+}
+
+// Listen 监听指定名称的事件，name 为 AllEvents 时监听所有事件
+func (i *Dispatcher) Listen(name string, listener Listener) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	i.listeners[name] = append(i.listeners[name], listener)
+}
+
+// HasListeners 是否有监听者，没有监听者时可以跳过构造事件
+func (i *Dispatcher) HasListeners(name string) bool {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+
+	return len(i.listeners[name]) > 0 || len(i.listeners[AllEvents]) > 0
+}
+
+// Dispatch 分发事件
+func (i *Dispatcher) Dispatch(e Event) {
+	i.lock.RLock()
+	listeners := make([]Listener, 0, len(i.listeners[e.EventName()])+len(i.listeners[AllEvents]))
+	listeners = append(listeners, i.listeners[e.EventName()]...)
+	listeners = append(listeners, i.listeners[AllEvents]...)
+	i.lock.RUnlock()
+
+	for _, listener := range listeners {
+		listener(e)
+	}
+}
+
+// Listen 按事件类型监听，例如 event.Listen(d, func(e event.CacheHit) {})
+func Listen[T Event](d *Dispatcher, listener func(e T)) {
+	var zero T
+	d.Listen(zero.EventName(), func(e Event) {
+		if typed, ok := e.(T); ok {
+			listener(typed)
+		}
+	})
+}
@@ -0,0 +1,22 @@
+package event
+
+import "time"
+
+const (
+	CacheHitName     = "cache.hit"
+	CacheMissedName  = "cache.missed"
+	KeyWrittenName   = "cache.written"
+	KeyForgottenName = "cache.forgotten"
+)
+
+// Event 缓存事件
+type Event interface {
+	EventName() string
+}
+
+// CacheEvent 所有缓存事件共有的字段
+type CacheEvent struct {
+	Key       string        // 缓存的 key
+	StoreName string        // 缓存配置中的存储名称
+	Duration  time.Duration // 本次操作存储的耗时
+}
@@ -1 +1,10 @@
 package event
+
+// KeyForgotten 删除缓存成功
+type KeyForgotten struct {
+	CacheEvent
+}
+
+func (KeyForgotten) EventName() string {
+	return KeyForgottenName
+}
@@ -1 +1,12 @@
 package event
+
+// KeyWritten 写入缓存成功
+type KeyWritten struct {
+	CacheEvent
+	Value any
+	TTL   int // 有效期，秒，0 表示永久
+}
+
+func (KeyWritten) EventName() string {
+	return KeyWrittenName
+}
@@ -1 +1,371 @@
 package cache
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"owl"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	fileExpiryLength = 10        // 文件头部保存过期时间戳的长度
+	fileTmpPattern   = ".tmp-"   // 写入中的临时文件名
+	fileTmpMaxAge    = time.Hour // 超过这个时间的临时文件视为写入失败的残留
+	fileDirPerm      = os.FileMode(0755)
+	filePerm         = os.FileMode(0644)
+)
+
+// FileStore 文件缓存，实现 contract\cache\store 接口
+// 每个前缀使用缓存目录下单独的子目录，多个前缀不同的 FileStore 可以共用一个缓存目录，Flush 互不影响
+// 每个 key 按 sha1 分散到两级子目录中，文件内容为 10 位过期时间戳 + 序列化后的值，
+// 写入时先写临时文件再重命名，保证读到的总是完整的内容；过期文件在读取时删除，也可以定期调用 GC 清理
+type FileStore struct {
+	mu         sync.Mutex // 保证同进程内自增自减的读写是原子的
+	directory  string
+	root       string // 当前前缀的子目录
+	prefix     string
+	serializer Serializer
+}
+
+func NewFileStore(directory, prefix string) *FileStore {
+	return &FileStore{
+		directory:  directory,
+		root:       filepath.Join(directory, prefixDir(prefix)),
+		prefix:     prefix,
+		serializer: GobSerializer{},
+	}
+}
+
+// prefixDir 前缀对应的子目录名，前缀中可能有 : 等不能用于文件名的字符，使用 sha1
+func prefixDir(prefix string) string {
+	sum := sha1.Sum([]byte(prefix))
+	return "store-" + hex.EncodeToString(sum[:8])
+}
+
+// NewFileStoreFromStage 在 storage/framework/cache 下创建文件缓存
+func NewFileStoreFromStage(stage *owl.Stage) *FileStore {
+	return NewFileStore(stage.CachePath(), "")
+}
+
+// Directory 返回缓存文件所在目录
+func (i *FileStore) Directory() string {
+	return i.directory
+}
+
+func (i *FileStore) Get(key interface{}) interface{} {
+	if keys, ok := key.([]string); ok {
+		return i.Many(keys)
+	}
+
+	value, _, ok := i.read(keyString(key))
+	if !ok {
+		return nil
+	}
+	return value
+}
+
+func (i *FileStore) Many(keys []string) []interface{} {
+	values := make([]interface{}, len(keys))
+	for n, key := range keys {
+		values[n] = i.Get(key)
+	}
+	return values
+}
+
+func (i *FileStore) Put(key string, value interface{}, seconds int) bool {
+	return i.write(key, value, expirationTimestamp(seconds)) == nil
+}
+
+func (i *FileStore) PutMany(values []interface{}, seconds int) bool {
+	items, ok := pairs(values)
+	if !ok {
+		return false
+	}
+
+	expiration := expirationTimestamp(seconds)
+	for key, value := range items {
+		if i.write(key, value, expiration) != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Add key 不存在或已过期时才写入，只保证同一进程内是原子的
+func (i *FileStore) Add(key string, value interface{}, seconds int) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if _, _, ok := i.read(key); ok {
+		return false
+	}
+	return i.write(key, value, expirationTimestamp(seconds)) == nil
+}
+
+func (i *FileStore) Increment(key string, value int) (int, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	current, expiration, ok := i.read(key)
+	if !ok {
+		return value, i.write(key, value, foreverTimestamp) == nil
+	}
+
+	n, ok := toInt(current)
+	if !ok {
+		return 0, false
+	}
+	return n + value, i.write(key, n+value, expiration) == nil
+}
+
+func (i *FileStore) Decrement(key string, value int) (int, bool) {
+	return i.Increment(key, -value)
+}
+
+func (i *FileStore) Forever(key string, value interface{}) bool {
+	return i.Put(key, value, 0)
+}
+
+func (i *FileStore) Forget(key string) bool {
+	return os.Remove(i.path(key)) == nil
+}
+
+// Flush 删除当前前缀的所有缓存文件，不影响同一个目录下其他前缀的缓存
+func (i *FileStore) Flush() bool {
+	return os.RemoveAll(i.root) == nil
+}
+
+func (i *FileStore) GetPrefix() string {
+	return i.prefix
+}
+
+// AddTagEntries 标签下的 key 保存在 tag:{tag}:entries 对应的缓存文件中
+func (i *FileStore) AddTagEntries(tag string, keys ...string) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	entries := i.tagEntries(tag)
+	exists := make(map[string]struct{}, len(entries))
+	for _, key := range entries {
+		exists[key] = struct{}{}
+	}
+	for _, key := range keys {
+		if _, ok := exists[key]; !ok {
+			exists[key] = struct{}{}
+			entries = append(entries, key)
+		}
+	}
+	return i.write(tagEntriesKey(tag), entries, foreverTimestamp) == nil
+}
+
+func (i *FileStore) TagEntries(tag string) []string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	return i.tagEntries(tag)
+}
+
+func (i *FileStore) ForgetTag(tag string) bool {
+	err := os.Remove(i.path(tagEntriesKey(tag)))
+	return err == nil || os.IsNotExist(err)
+}
+
+// ForgetTagEntries 删除后标签下没有 key 时删除标签文件
+func (i *FileStore) ForgetTagEntries(tag string, keys ...string) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	forget := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		forget[key] = struct{}{}
+	}
+	var entries []string
+	for _, key := range i.tagEntries(tag) {
+		if _, ok := forget[key]; !ok {
+			entries = append(entries, key)
+		}
+	}
+	if len(entries) == 0 {
+		err := os.Remove(i.path(tagEntriesKey(tag)))
+		return err == nil || os.IsNotExist(err)
+	}
+	return i.write(tagEntriesKey(tag), entries, foreverTimestamp) == nil
+}
+
+func (i *FileStore) tagEntries(tag string) []string {
+	value, _, ok := i.read(tagEntriesKey(tag))
+	if !ok {
+		return nil
+	}
+	entries, _ := value.([]string)
+	return entries
+}
+
+// GC 清理当前前缀已过期的缓存文件和写入失败残留的临时文件，返回删除的文件数
+func (i *FileStore) GC() (int, error) {
+	now := time.Now()
+	removed := 0
+
+	err := filepath.WalkDir(i.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		if strings.Contains(d.Name(), fileTmpPattern) {
+			info, err := d.Info()
+			if err == nil && now.Sub(info.ModTime()) > fileTmpMaxAge && os.Remove(path) == nil {
+				removed++
+			}
+			return nil
+		}
+
+		expiration, ok := readFileExpiration(path)
+		if ok && expiration <= now.Unix() && os.Remove(path) == nil {
+			removed++
+		}
+		return nil
+	})
+	return removed, err
+}
+
+// Stats 遍历当前前缀的缓存目录统计文件数、已过期的文件数和占用的空间
+func (i *FileStore) Stats() (Stats, error) {
+	now := time.Now().Unix()
+	var stats Stats
+
+	err := filepath.WalkDir(i.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || strings.Contains(d.Name(), fileTmpPattern) {
+			return nil
+		}
+
+		if info, err := d.Info(); err == nil {
+			stats.Bytes += info.Size()
+		}
+		stats.Items++
+		if expiration, ok := readFileExpiration(path); ok && expiration <= now {
+			stats.Expired++
+		}
+		return nil
+	})
+	return stats, err
+}
+
+// StartGC 每隔 interval 执行一次 GC，直到 ctx 结束
+func (i *FileStore) StartGC(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				_, _ = i.GC()
+			}
+		}
+	}()
+}
+
+// path key 对应的缓存文件路径，例如 {directory}/store-{prefix sha1}/ab/cd/abcd...
+func (i *FileStore) path(key string) string {
+	sum := sha1.Sum([]byte(i.prefix + key))
+	hash := hex.EncodeToString(sum[:])
+	return filepath.Join(i.root, hash[0:2], hash[2:4], hash)
+}
+
+// read 读取未过期的值，过期的文件直接删除
+func (i *FileStore) read(key string) (value interface{}, expiration int64, ok bool) {
+	path := i.path(key)
+	content, err := os.ReadFile(path)
+	if err != nil || len(content) < fileExpiryLength {
+		return nil, 0, false
+	}
+
+	expiration, err = strconv.ParseInt(string(content[:fileExpiryLength]), 10, 64)
+	if err != nil {
+		return nil, 0, false
+	}
+	if expiration <= time.Now().Unix() {
+		_ = os.Remove(path)
+		return nil, 0, false
+	}
+
+	value, err = i.serializer.Unserialize(content[fileExpiryLength:])
+	if err != nil {
+		return nil, 0, false
+	}
+	return value, expiration, true
+}
+
+// write 先写入同目录下的临时文件，再重命名为目标文件
+func (i *FileStore) write(key string, value interface{}, expiration int64) error {
+	data, err := i.serializer.Serialize(value)
+	if err != nil {
+		return err
+	}
+
+	path := i.path(key)
+	dir := filepath.Dir(path)
+	if err = os.MkdirAll(dir, fileDirPerm); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+fileTmpPattern+"*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = fmt.Fprintf(tmp, "%010d", expiration)
+	if err == nil {
+		_, err = tmp.Write(data)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = os.Chmod(tmp.Name(), filePerm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// readFileExpiration 只读取文件头部的过期时间戳
+func readFileExpiration(path string) (int64, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, false
+	}
+	defer f.Close()
+
+	header := make([]byte, fileExpiryLength)
+	if _, err = io.ReadFull(f, header); err != nil {
+		return 0, false
+	}
+	expiration, err := strconv.ParseInt(string(header), 10, 64)
+	return expiration, err == nil
+}
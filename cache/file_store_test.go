@@ -0,0 +1,115 @@
+package cache
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileStorePutGet(t *testing.T) {
+	store := NewFileStore(t.TempDir(), "")
+
+	if !store.Put("name", "owl", 10) {
+		t.Fatal("Put() failed")
+	}
+	if v := store.Get("name"); v != "owl" {
+		t.Fatalf("Get() = %v, want owl", v)
+	}
+
+	// 重新创建 store 模拟进程重启
+	restarted := NewFileStore(store.Directory(), "")
+	if v := restarted.Get("name"); v != "owl" {
+		t.Fatalf("Get() after restart = %v, want owl", v)
+	}
+
+	path := store.path("name")
+	rel, _ := filepath.Rel(store.root, path)
+	if parts := strings.Split(filepath.ToSlash(rel), "/"); len(parts) != 3 {
+		t.Fatalf("cache file should be sharded, got %s", rel)
+	}
+
+	if !store.Forget("name") || store.Get("name") != nil {
+		t.Fatal("Forget() did not remove the key")
+	}
+}
+
+func TestFileStoreExpiry(t *testing.T) {
+	store := NewFileStore(t.TempDir(), "")
+	store.Put("old", 1, 10)
+
+	content, _ := os.ReadFile(store.path("old"))
+	expired := fmt.Sprintf("%010d", time.Now().Unix()-1)
+	_ = os.WriteFile(store.path("old"), append([]byte(expired), content[fileExpiryLength:]...), filePerm)
+
+	if v := store.Get("old"); v != nil {
+		t.Fatalf("expired key returned %v", v)
+	}
+	if _, err := os.Stat(store.path("old")); !os.IsNotExist(err) {
+		t.Fatal("expired file should be deleted on read")
+	}
+}
+
+func TestFileStoreIncrementAndMany(t *testing.T) {
+	store := NewFileStore(t.TempDir(), "app")
+
+	if n, ok := store.Increment("counter", 2); !ok || n != 2 {
+		t.Fatalf("Increment() = %d, %v", n, ok)
+	}
+	if n, ok := store.Decrement("counter", 5); !ok || n != -3 {
+		t.Fatalf("Decrement() = %d, %v", n, ok)
+	}
+
+	store.PutMany([]interface{}{"a", "x", "b", []string{"y"}}, 0)
+	values := store.Many([]string{"a", "b", "c"})
+	if values[0] != "x" || values[2] != nil {
+		t.Fatalf("Many() = %v", values)
+	}
+	if v, ok := values[1].([]string); !ok || v[0] != "y" {
+		t.Fatalf("Many() slice value = %#v", values[1])
+	}
+}
+
+func TestFileStoreGCAndFlush(t *testing.T) {
+	store := NewFileStore(t.TempDir(), "")
+	store.Put("keep", 1, 0)
+	store.Put("drop", 2, 10)
+
+	_ = os.WriteFile(store.path("drop"), []byte(fmt.Sprintf("%010d", time.Now().Unix()-1)), filePerm)
+
+	removed, err := store.GC()
+	if err != nil || removed != 1 {
+		t.Fatalf("GC() = %d, %v", removed, err)
+	}
+	if store.Get("keep") != 1 {
+		t.Fatal("GC() removed a live entry")
+	}
+
+	if !store.Flush() {
+		t.Fatal("Flush() failed")
+	}
+	entries, _ := os.ReadDir(store.Directory())
+	if len(entries) != 0 {
+		t.Fatalf("Flush() left %d entries", len(entries))
+	}
+}
+
+func TestFileStoreFlushOnlyPrefix(t *testing.T) {
+	dir := t.TempDir()
+	app := NewFileStore(dir, "app:")
+	other := NewFileStore(dir, "other:")
+	app.Put("key", 1, 0)
+	other.Put("key", 2, 0)
+
+	if !app.Flush() {
+		t.Fatal("Flush() failed")
+	}
+	if app.Get("key") != nil {
+		t.Fatal("Flush() left prefixed entries")
+	}
+	if other.Get("key") != 2 {
+		t.Fatal("Flush() removed entries of another prefix")
+	}
+}
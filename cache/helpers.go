@@ -0,0 +1,88 @@
+package cache
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// foreverTimestamp 文件、数据库等以时间戳记录过期时间的存储，永不过期时写入的时间戳
+const foreverTimestamp int64 = 9999999999
+
+// keyString 把 Store.Get 传入的 key 统一转为字符串
+func keyString(key interface{}) string {
+	switch k := key.(type) {
+	case string:
+		return k
+	case fmt.Stringer:
+		return k.String()
+	default:
+		return fmt.Sprint(k)
+	}
+}
+
+// pairs 解析 PutMany 的参数，values 为 key, value 交替排列，例如 []interface{}{"a", 1, "b", 2}
+func pairs(values []interface{}) (map[string]interface{}, bool) {
+	if len(values)%2 != 0 {
+		return nil, false
+	}
+	items := make(map[string]interface{}, len(values)/2)
+	for n := 0; n < len(values); n += 2 {
+		key, ok := values[n].(string)
+		if !ok {
+			return nil, false
+		}
+		items[key] = values[n+1]
+	}
+	return items, true
+}
+
+// toInt 把缓存中的值转为整数，用于自增自减
+func toInt(value interface{}) (int, bool) {
+	switch v := value.(type) {
+	case int:
+		return v, true
+	case int8:
+		return int(v), true
+	case int16:
+		return int(v), true
+	case int32:
+		return int(v), true
+	case int64:
+		return int(v), true
+	case uint:
+		return int(v), true
+	case uint8:
+		return int(v), true
+	case uint16:
+		return int(v), true
+	case uint32:
+		return int(v), true
+	case uint64:
+		return int(v), true
+	case string:
+		n, err := strconv.Atoi(v)
+		return n, err == nil
+	case []byte:
+		n, err := strconv.Atoi(string(v))
+		return n, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// expiresAt 计算过期时间，seconds <= 0 表示永不过期，返回零值
+func expiresAt(seconds int) time.Time {
+	if seconds <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(time.Duration(seconds) * time.Second)
+}
+
+// expirationTimestamp 计算以时间戳记录的过期时间，seconds <= 0 表示永不过期
+func expirationTimestamp(seconds int) int64 {
+	if seconds <= 0 {
+		return foreverTimestamp
+	}
+	return time.Now().Unix() + int64(seconds)
+}
@@ -0,0 +1,109 @@
+package cache
+
+import (
+	"github.com/go-redis/redis"
+	jsoniter "github.com/json-iterator/go"
+	"sync"
+)
+
+// InvalidationMessage 分层缓存节点之间广播的失效消息
+type InvalidationMessage struct {
+	Node  string   `json:"node"`  // 发送消息的节点，节点收到自己发出的消息时忽略
+	Keys  []string `json:"keys"`  // 需要从一级缓存中删除的 key
+	Flush bool     `json:"flush"` // 为 true 时清空一级缓存
+}
+
+// InvalidationBus 分层缓存的失效消息通道
+type InvalidationBus interface {
+	// Publish 广播失效消息
+	Publish(message InvalidationMessage) error
+
+	// Subscribe 订阅失效消息，返回取消订阅的函数
+	Subscribe(handler func(message InvalidationMessage)) (func(), error)
+}
+
+// MemoryBus 进程内的消息通道，同步投递给所有订阅者，用于测试或单机部署
+type MemoryBus struct {
+	mu       sync.RWMutex
+	next     int
+	handlers map[int]func(message InvalidationMessage)
+}
+
+func NewMemoryBus() *MemoryBus {
+	return &MemoryBus{
+		handlers: make(map[int]func(message InvalidationMessage)),
+	}
+}
+
+func (i *MemoryBus) Publish(message InvalidationMessage) error {
+	i.mu.RLock()
+	handlers := make([]func(message InvalidationMessage), 0, len(i.handlers))
+	for _, handler := range i.handlers {
+		handlers = append(handlers, handler)
+	}
+	i.mu.RUnlock()
+
+	for _, handler := range handlers {
+		handler(message)
+	}
+	return nil
+}
+
+func (i *MemoryBus) Subscribe(handler func(message InvalidationMessage)) (func(), error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	id := i.next
+	i.next++
+	i.handlers[id] = handler
+	return func() {
+		i.mu.Lock()
+		defer i.mu.Unlock()
+		delete(i.handlers, id)
+	}, nil
+}
+
+// RedisBus 使用 redis pub/sub 在多个节点之间广播失效消息
+type RedisBus struct {
+	client  *redis.Client
+	channel string
+}
+
+func NewRedisBus(client *redis.Client, channel string) *RedisBus {
+	return &RedisBus{
+		client:  client,
+		channel: channel,
+	}
+}
+
+func (i *RedisBus) Publish(message InvalidationMessage) error {
+	data, err := jsoniter.Marshal(message)
+	if err != nil {
+		return err
+	}
+	return i.client.Publish(i.channel, data).Err()
+}
+
+// Subscribe 等待订阅确认后在后台协程中接收消息，无法解析的消息直接丢弃
+func (i *RedisBus) Subscribe(handler func(message InvalidationMessage)) (func(), error) {
+	pubSub := i.client.Subscribe(i.channel)
+	if _, err := pubSub.Receive(); err != nil {
+		_ = pubSub.Close()
+		return nil, err
+	}
+
+	go func() {
+		for msg := range pubSub.Channel() {
+			var message InvalidationMessage
+			if err := jsoniter.UnmarshalFromString(msg.Payload, &message); err != nil {
+				continue
+			}
+			handler(message)
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { _ = pubSub.Close() })
+	}, nil
+}
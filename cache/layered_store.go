@@ -0,0 +1,270 @@
+package cache
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	contractcache "owl/contract/cache"
+)
+
+// DefaultLocalTTL 一级缓存默认的有效期，秒
+const DefaultLocalTTL = 5
+
+// LayeredStore 两级缓存，实现 contract\cache\store 接口
+// 读取时先查进程内的 ArrayStore，未命中再读远程存储并写入一级缓存；
+// 写入、删除时更新远程存储，并通过 InvalidationBus 通知其他节点删除一级缓存中的 key。
+// 一级缓存的有效期很短，即使丢失了失效消息，节点间的不一致也只会持续 localTTL 秒
+// 一级缓存保存序列化再还原后的值，和远程存储读到的值类型相同，也不会和调用方共享同一个对象
+type LayeredStore struct {
+	local       *ArrayStore
+	remote      contractcache.Store
+	serializer  Serializer
+	localTTL    int
+	bus         InvalidationBus
+	node        string
+	unsubscribe func()
+}
+
+// NewLayeredStore localTTL <= 0 时使用 DefaultLocalTTL，size 为一级缓存最多缓存的条目数，bus 为 nil 时不广播
+func NewLayeredStore(remote contractcache.Store, localTTL, size int, bus InvalidationBus) (*LayeredStore, error) {
+	if localTTL <= 0 {
+		localTTL = DefaultLocalTTL
+	}
+
+	store := &LayeredStore{
+		local:      NewArrayStore("", size),
+		remote:     remote,
+		serializer: GobSerializer{},
+		localTTL:   localTTL,
+		bus:        bus,
+		node:       uuid.New().String(),
+	}
+
+	if bus != nil {
+		unsubscribe, err := bus.Subscribe(store.invalidate)
+		if err != nil {
+			return nil, err
+		}
+		store.unsubscribe = unsubscribe
+	}
+	return store, nil
+}
+
+// Local 返回一级缓存
+func (i *LayeredStore) Local() *ArrayStore {
+	return i.local
+}
+
+// Remote 返回远程存储
+func (i *LayeredStore) Remote() contractcache.Store {
+	return i.remote
+}
+
+// Node 返回当前节点标识
+func (i *LayeredStore) Node() string {
+	return i.node
+}
+
+// Close 取消订阅失效消息
+func (i *LayeredStore) Close() error {
+	if i.unsubscribe != nil {
+		i.unsubscribe()
+	}
+	return nil
+}
+
+func (i *LayeredStore) Get(key interface{}) interface{} {
+	if keys, ok := key.([]string); ok {
+		return i.Many(keys)
+	}
+
+	k := keyString(key)
+	if value := i.local.Get(k); value != nil {
+		return value
+	}
+
+	value := i.remote.Get(k)
+	if value != nil {
+		i.putLocal(k, value, i.localTTL)
+	}
+	return value
+}
+
+// Many 一级缓存未命中的 key 一次从远程存储取回
+func (i *LayeredStore) Many(keys []string) []interface{} {
+	values := i.local.Many(keys)
+
+	var missed []string
+	var positions []int
+	for n, value := range values {
+		if value == nil {
+			missed = append(missed, keys[n])
+			positions = append(positions, n)
+		}
+	}
+	if len(missed) == 0 {
+		return values
+	}
+
+	for n, value := range i.remote.Many(missed) {
+		if value != nil {
+			values[positions[n]] = value
+			i.putLocal(missed[n], value, i.localTTL)
+		}
+	}
+	return values
+}
+
+func (i *LayeredStore) Put(key string, value interface{}, seconds int) bool {
+	if !i.remote.Put(key, value, seconds) {
+		return false
+	}
+	i.putLocal(key, value, i.ttl(seconds))
+	i.publish(key)
+	return true
+}
+
+func (i *LayeredStore) PutMany(values []interface{}, seconds int) bool {
+	items, ok := pairs(values)
+	if !ok || !i.remote.PutMany(values, seconds) {
+		return false
+	}
+
+	keys := make([]string, 0, len(items))
+	for key, value := range items {
+		i.putLocal(key, value, i.ttl(seconds))
+		keys = append(keys, key)
+	}
+	i.publish(keys...)
+	return true
+}
+
+// Add 远程存储实现了 AddStore 时是原子操作
+func (i *LayeredStore) Add(key string, value interface{}, seconds int) bool {
+	var result bool
+	if store, ok := i.remote.(AddStore); ok {
+		result = store.Add(key, value, seconds)
+	} else if i.remote.Get(key) == nil {
+		result = i.remote.Put(key, value, seconds)
+	}
+
+	if result {
+		i.local.Forget(key)
+		i.publish(key)
+	}
+	return result
+}
+
+// Increment 计数器只在远程存储中维护，一级缓存中的旧值直接删除
+func (i *LayeredStore) Increment(key string, value int) (int, bool) {
+	n, ok := i.remote.Increment(key, value)
+	if ok {
+		i.local.Forget(key)
+		i.publish(key)
+	}
+	return n, ok
+}
+
+func (i *LayeredStore) Decrement(key string, value int) (int, bool) {
+	n, ok := i.remote.Decrement(key, value)
+	if ok {
+		i.local.Forget(key)
+		i.publish(key)
+	}
+	return n, ok
+}
+
+func (i *LayeredStore) Forever(key string, value interface{}) bool {
+	return i.Put(key, value, 0)
+}
+
+func (i *LayeredStore) Forget(key string) bool {
+	i.local.Forget(key)
+	result := i.remote.Forget(key)
+	i.publish(key)
+	return result
+}
+
+func (i *LayeredStore) Flush() bool {
+	i.local.Flush()
+	result := i.remote.Flush()
+	if i.bus != nil {
+		_ = i.bus.Publish(InvalidationMessage{Node: i.node, Flush: true})
+	}
+	return result
+}
+
+func (i *LayeredStore) GetPrefix() string {
+	return i.remote.GetPrefix()
+}
+
+// Lock 远程存储支持锁时使用远程锁，否则只能在当前进程内互斥
+func (i *LayeredStore) Lock(name string, seconds int, owner string) contractcache.Lock {
+	if provider, ok := i.remote.(contractcache.LockProvider); ok {
+		return provider.Lock(name, seconds, owner)
+	}
+	return i.local.Lock(name, seconds, owner)
+}
+
+func (i *LayeredStore) RestoreLock(name, owner string) contractcache.Lock {
+	return i.Lock(name, 0, owner)
+}
+
+// Stats 返回远程存储的统计信息
+func (i *LayeredStore) Stats() (Stats, error) {
+	if store, ok := i.remote.(StatsStore); ok {
+		return store.Stats()
+	}
+	return i.local.Stats()
+}
+
+// Locks 与 Lock 一致，远程存储支持锁时列出远程存储中的锁
+func (i *LayeredStore) Locks() ([]LockInfo, error) {
+	if _, ok := i.remote.(contractcache.LockProvider); ok {
+		if lister, ok := i.remote.(LockLister); ok {
+			return lister.Locks()
+		}
+		return nil, errors.New("远程存储不支持列出锁")
+	}
+	return i.local.Locks()
+}
+
+// putLocal 把值序列化再还原后写入一级缓存，无法序列化时只删除一级缓存中的旧值，之后从远程存储读取
+func (i *LayeredStore) putLocal(key string, value interface{}, seconds int) {
+	data, err := i.serializer.Serialize(value)
+	if err == nil {
+		value, err = i.serializer.Unserialize(data)
+	}
+	if err != nil {
+		i.local.Forget(key)
+		return
+	}
+	i.local.Put(key, value, seconds)
+}
+
+// ttl 一级缓存的有效期不超过远程存储中的有效期
+func (i *LayeredStore) ttl(seconds int) int {
+	if seconds > 0 && seconds < i.localTTL {
+		return seconds
+	}
+	return i.localTTL
+}
+
+func (i *LayeredStore) publish(keys ...string) {
+	if i.bus != nil {
+		_ = i.bus.Publish(InvalidationMessage{Node: i.node, Keys: keys})
+	}
+}
+
+// invalidate 处理其他节点发出的失效消息
+func (i *LayeredStore) invalidate(message InvalidationMessage) {
+	if message.Node == i.node {
+		return
+	}
+	if message.Flush {
+		i.local.Flush()
+		return
+	}
+	for _, key := range message.Keys {
+		i.local.Forget(key)
+	}
+}
@@ -0,0 +1,133 @@
+package cache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestLayeredStoreReadThrough(t *testing.T) {
+	remote := NewArrayStore("", 0)
+	store, err := NewLayeredStore(remote, 60, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	remote.Put("name", "owl", 0)
+	if v := store.Get("name"); v != "owl" {
+		t.Fatalf("Get() = %v, want owl", v)
+	}
+	if v := store.Local().Get("name"); v != "owl" {
+		t.Fatal("remote hit should be written to local")
+	}
+
+	// 一级缓存命中时不再读远程存储
+	remote.Put("name", "changed", 0)
+	if v := store.Get("name"); v != "owl" {
+		t.Fatalf("Get() = %v, want cached owl", v)
+	}
+
+	remote.Put("other", 2, 0)
+	values := store.Many([]string{"name", "other", "missing"})
+	if values[0] != "owl" || values[1] != 2 || values[2] != nil {
+		t.Fatalf("Many() = %v", values)
+	}
+
+	if n, ok := store.Increment("other", 3); !ok || n != 5 || store.Get("other") != 5 {
+		t.Fatalf("Increment() = %d, %v, local should be refreshed", n, ok)
+	}
+}
+
+func TestLayeredStoreInvalidation(t *testing.T) {
+	remote := NewArrayStore("", 0)
+	bus := NewMemoryBus()
+	a, _ := NewLayeredStore(remote, 60, 0, bus)
+	b, _ := NewLayeredStore(remote, 60, 0, bus)
+
+	a.Put("name", "v1", 60)
+	if v := b.Get("name"); v != "v1" {
+		t.Fatalf("b.Get() = %v, want v1", v)
+	}
+
+	a.Put("name", "v2", 60)
+	if v := b.Get("name"); v != "v2" {
+		t.Fatalf("b.Get() after invalidation = %v, want v2", v)
+	}
+	if v := a.Local().Get("name"); v != "v2" {
+		t.Fatal("writer should keep its own local copy")
+	}
+
+	a.Forget("name")
+	if b.Local().Get("name") != nil || b.Get("name") != nil {
+		t.Fatal("Forget() should invalidate other nodes")
+	}
+
+	b.Put("x", 1, 0)
+	a.Get("x")
+	b.Flush()
+	if a.Local().Len() != 0 {
+		t.Fatal("Flush() should clear local caches on other nodes")
+	}
+
+	_ = b.Close()
+	a.Put("x", 2, 0)
+	b.Local().Put("x", 1, 60)
+	a.Put("x", 3, 0)
+	if b.Local().Get("x") != 1 {
+		t.Fatal("closed store should not receive messages")
+	}
+}
+
+func TestLayeredStoreRedisBus(t *testing.T) {
+	remote, _ := newTestRedisStore(t, "app:")
+	a, err := NewLayeredStore(remote, 60, 0, NewRedisBus(remote.Client(), "invalidate"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewLayeredStore(remote, 60, 0, NewRedisBus(remote.Client(), "invalidate"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = a.Close()
+		_ = b.Close()
+	})
+
+	a.Put("name", "v1", 60)
+	if v := b.Get("name"); v != "v1" {
+		t.Fatalf("b.Get() = %v, want v1", v)
+	}
+
+	a.Put("name", "v2", 60)
+	deadline := time.Now().Add(time.Second)
+	for b.Local().Get("name") != nil && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if v := b.Get("name"); v != "v2" {
+		t.Fatalf("b.Get() after invalidation = %v, want v2", v)
+	}
+}
+
+func TestLayeredStoreLocalMatchesRemote(t *testing.T) {
+	store, err := NewLayeredStore(NewFileStore(t.TempDir(), ""), 60, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tags := []string{"a"}
+	store.Put("count", int64(5), 0)
+	store.Put("tags", tags, 0)
+	tags[0] = "changed"
+
+	local := store.Many([]string{"count", "tags"})
+	store.Local().Flush()
+	remote := store.Many([]string{"count", "tags"})
+	for n := range local {
+		if fmt.Sprintf("%T %v", local[n], local[n]) != fmt.Sprintf("%T %v", remote[n], remote[n]) {
+			t.Fatalf("local value %#v, remote value %#v", local[n], remote[n])
+		}
+	}
+	if v := remote[1].([]string); v[0] != "a" {
+		t.Fatalf("local cache should not share the caller's slice, got %v", v)
+	}
+}
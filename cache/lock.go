@@ -0,0 +1,88 @@
+package cache
+
+import (
+	"context"
+	"github.com/google/uuid"
+	contractcache "owl/contract/cache"
+	"time"
+)
+
+const (
+	lockPrefix      = "lock:"                 // 锁在存储中的 key 前缀
+	lockSleep       = 50 * time.Millisecond   // Block 轮询的初始间隔
+	lockMaxSleep    = 1000 * time.Millisecond // Block 轮询的最大间隔
+	lockOwnerLength = 16
+)
+
+// baseLock 各种锁实现共用的获取、阻塞等待逻辑，具体的加锁解锁由实现者提供
+type baseLock struct {
+	name    string
+	seconds int    // 锁的有效期，<= 0 表示不过期
+	owner   string // 锁的持有者标识，释放时校验
+}
+
+func newBaseLock(name string, seconds int, owner string) baseLock {
+	if owner == "" {
+		owner = newLockOwner()
+	}
+	return baseLock{
+		name:    name,
+		seconds: seconds,
+		owner:   owner,
+	}
+}
+
+// newLockOwner 生成随机的持有者标识
+func newLockOwner() string {
+	return uuid.New().String()[:lockOwnerLength]
+}
+
+func (i *baseLock) Owner() string {
+	return i.owner
+}
+
+// get 尝试获取一次锁，获取成功且 callback 不为空时执行 callback 并释放锁
+func (i *baseLock) get(ctx context.Context, acquire func() bool, release func() bool, callback func()) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if !acquire() {
+		return contractcache.LockNotAcquiredError
+	}
+	if callback != nil {
+		defer release()
+		callback()
+	}
+	return nil
+}
+
+// block 在 seconds 秒内以指数退避的方式轮询获取锁，超时返回 LockTimeoutExceededError
+func (i *baseLock) block(ctx context.Context, seconds int, acquire func() bool, release func() bool, callback func()) error {
+	deadline := time.Now().Add(time.Duration(seconds) * time.Second)
+	sleep := lockSleep
+
+	for !acquire() {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return contractcache.LockTimeoutExceededError
+		}
+
+		timer := time.NewTimer(min(sleep, remaining))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		if sleep *= 2; sleep > lockMaxSleep {
+			sleep = lockMaxSleep
+		}
+	}
+
+	if callback != nil {
+		defer release()
+		callback()
+	}
+	return nil
+}
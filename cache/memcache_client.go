@@ -0,0 +1,371 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	memcacheMaxKeyLength   = 250
+	memcacheRelativeExpiry = 60 * 60 * 24 * 30 // 超过 30 天的有效期 memcached 会当作时间戳处理
+)
+
+var (
+	MemcacheCacheMissError   = errors.New("memcache: cache miss")
+	MemcacheNotStoredError   = errors.New("memcache: item not stored")
+	MemcacheCasConflictError = errors.New("memcache: compare-and-swap conflict")
+	MemcacheInvalidKeyError  = errors.New("memcache: key is too long or contains invalid characters")
+)
+
+// MemcacheItem memcached 中的一个条目
+type MemcacheItem struct {
+	Key        string
+	Value      []byte
+	Flags      uint32
+	Expiration int    // 有效期，秒，0 表示不过期，负数表示立即过期
+	CasID      uint64 // Gets 返回的版本号，CompareAndSwap 时使用
+}
+
+// MemcacheClient memcached 文本协议客户端，连接一台服务器，空闲连接放回连接池复用
+type MemcacheClient struct {
+	addr    string
+	timeout time.Duration
+	maxIdle int
+
+	mu   sync.Mutex
+	idle []*memcacheConn
+}
+
+type memcacheConn struct {
+	nc net.Conn
+	rw *bufio.ReadWriter
+}
+
+func NewMemcacheClient(addr string, timeout time.Duration, maxIdle int) *MemcacheClient {
+	if timeout <= 0 {
+		timeout = time.Second
+	}
+	if maxIdle <= 0 {
+		maxIdle = 2
+	}
+	return &MemcacheClient{
+		addr:    addr,
+		timeout: timeout,
+		maxIdle: maxIdle,
+	}
+}
+
+// Get 读取一个条目，不存在时返回 MemcacheCacheMissError
+func (i *MemcacheClient) Get(key string) (*MemcacheItem, error) {
+	items, err := i.GetMulti([]string{key})
+	if err != nil {
+		return nil, err
+	}
+	item, ok := items[key]
+	if !ok {
+		return nil, MemcacheCacheMissError
+	}
+	return item, nil
+}
+
+// GetMulti 使用一条 gets 命令读取多个条目，返回的 map 中只包含存在的条目
+func (i *MemcacheClient) GetMulti(keys []string) (map[string]*MemcacheItem, error) {
+	items := make(map[string]*MemcacheItem, len(keys))
+	if len(keys) == 0 {
+		return items, nil
+	}
+	for _, key := range keys {
+		if !validMemcacheKey(key) {
+			return nil, MemcacheInvalidKeyError
+		}
+	}
+
+	err := i.do(func(c *memcacheConn) error {
+		if _, err := fmt.Fprintf(c.rw, "gets %s\r\n", strings.Join(keys, " ")); err != nil {
+			return err
+		}
+		if err := c.rw.Flush(); err != nil {
+			return err
+		}
+		return readMemcacheItems(c.rw.Reader, items)
+	})
+	return items, err
+}
+
+// Set 写入条目
+func (i *MemcacheClient) Set(item *MemcacheItem) error {
+	return i.store("set", item)
+}
+
+// Add 条目不存在时才写入，已存在时返回 MemcacheNotStoredError
+func (i *MemcacheClient) Add(item *MemcacheItem) error {
+	return i.store("add", item)
+}
+
+// CompareAndSwap 条目的版本号与 item.CasID 一致时才写入，
+// 被其他客户端修改过时返回 MemcacheCasConflictError，不存在时返回 MemcacheCacheMissError
+func (i *MemcacheClient) CompareAndSwap(item *MemcacheItem) error {
+	return i.store("cas", item)
+}
+
+// Delete 删除条目，不存在时返回 MemcacheCacheMissError
+func (i *MemcacheClient) Delete(key string) error {
+	if !validMemcacheKey(key) {
+		return MemcacheInvalidKeyError
+	}
+	line, err := i.command("delete %s\r\n", key)
+	if err != nil {
+		return err
+	}
+	switch line {
+	case "DELETED":
+		return nil
+	case "NOT_FOUND":
+		return MemcacheCacheMissError
+	default:
+		return memcacheResponseError(line)
+	}
+}
+
+// Increment 自增，条目不存在时返回 MemcacheCacheMissError
+func (i *MemcacheClient) Increment(key string, delta uint64) (uint64, error) {
+	return i.incrDecr("incr", key, delta)
+}
+
+// Decrement 自减，memcached 中的值最小为 0
+func (i *MemcacheClient) Decrement(key string, delta uint64) (uint64, error) {
+	return i.incrDecr("decr", key, delta)
+}
+
+// FlushAll 清空服务器上的所有条目
+func (i *MemcacheClient) FlushAll() error {
+	line, err := i.command("flush_all\r\n")
+	if err != nil {
+		return err
+	}
+	if line != "OK" {
+		return memcacheResponseError(line)
+	}
+	return nil
+}
+
+// Close 关闭所有空闲连接
+func (i *MemcacheClient) Close() error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for _, c := range i.idle {
+		_ = c.nc.Close()
+	}
+	i.idle = nil
+	return nil
+}
+
+func (i *MemcacheClient) store(verb string, item *MemcacheItem) error {
+	if !validMemcacheKey(item.Key) {
+		return MemcacheInvalidKeyError
+	}
+
+	var line string
+	err := i.do(func(c *memcacheConn) error {
+		var err error
+		if verb == "cas" {
+			_, err = fmt.Fprintf(c.rw, "cas %s %d %d %d %d\r\n", item.Key, item.Flags, memcacheExpiration(item.Expiration), len(item.Value), item.CasID)
+		} else {
+			_, err = fmt.Fprintf(c.rw, "%s %s %d %d %d\r\n", verb, item.Key, item.Flags, memcacheExpiration(item.Expiration), len(item.Value))
+		}
+		if err != nil {
+			return err
+		}
+		if _, err = c.rw.Write(item.Value); err != nil {
+			return err
+		}
+		if _, err = c.rw.WriteString("\r\n"); err != nil {
+			return err
+		}
+		if err = c.rw.Flush(); err != nil {
+			return err
+		}
+		line, err = readMemcacheLine(c.rw.Reader)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	switch line {
+	case "STORED":
+		return nil
+	case "NOT_STORED":
+		return MemcacheNotStoredError
+	case "EXISTS":
+		return MemcacheCasConflictError
+	case "NOT_FOUND":
+		return MemcacheCacheMissError
+	default:
+		return memcacheResponseError(line)
+	}
+}
+
+func (i *MemcacheClient) incrDecr(verb, key string, delta uint64) (uint64, error) {
+	if !validMemcacheKey(key) {
+		return 0, MemcacheInvalidKeyError
+	}
+	line, err := i.command("%s %s %d\r\n", verb, key, delta)
+	if err != nil {
+		return 0, err
+	}
+	if line == "NOT_FOUND" {
+		return 0, MemcacheCacheMissError
+	}
+	n, err := strconv.ParseUint(line, 10, 64)
+	if err != nil {
+		return 0, memcacheResponseError(line)
+	}
+	return n, nil
+}
+
+// command 发送一行命令并读取一行响应
+func (i *MemcacheClient) command(format string, a ...any) (string, error) {
+	var line string
+	err := i.do(func(c *memcacheConn) error {
+		if _, err := fmt.Fprintf(c.rw, format, a...); err != nil {
+			return err
+		}
+		if err := c.rw.Flush(); err != nil {
+			return err
+		}
+		var err error
+		line, err = readMemcacheLine(c.rw.Reader)
+		return err
+	})
+	return line, err
+}
+
+// do 取出一个连接执行 fn，出错的连接直接关闭，避免读到上一次请求残留的响应
+func (i *MemcacheClient) do(fn func(c *memcacheConn) error) error {
+	c, err := i.conn()
+	if err != nil {
+		return err
+	}
+	if err = c.nc.SetDeadline(time.Now().Add(i.timeout)); err == nil {
+		err = fn(c)
+	}
+	if err != nil {
+		_ = c.nc.Close()
+		return err
+	}
+	i.release(c)
+	return nil
+}
+
+func (i *MemcacheClient) conn() (*memcacheConn, error) {
+	i.mu.Lock()
+	if n := len(i.idle); n > 0 {
+		c := i.idle[n-1]
+		i.idle = i.idle[:n-1]
+		i.mu.Unlock()
+		return c, nil
+	}
+	i.mu.Unlock()
+
+	nc, err := net.DialTimeout("tcp", i.addr, i.timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &memcacheConn{
+		nc: nc,
+		rw: bufio.NewReadWriter(bufio.NewReader(nc), bufio.NewWriter(nc)),
+	}, nil
+}
+
+func (i *MemcacheClient) release(c *memcacheConn) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if len(i.idle) >= i.maxIdle {
+		_ = c.nc.Close()
+		return
+	}
+	i.idle = append(i.idle, c)
+}
+
+// readMemcacheItems 读取 VALUE <key> <flags> <bytes> [<cas>] 直到 END
+func readMemcacheItems(r *bufio.Reader, items map[string]*MemcacheItem) error {
+	for {
+		line, err := readMemcacheLine(r)
+		if err != nil {
+			return err
+		}
+		if line == "END" {
+			return nil
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 4 || fields[0] != "VALUE" {
+			return memcacheResponseError(line)
+		}
+		flags, err1 := strconv.ParseUint(fields[2], 10, 32)
+		size, err2 := strconv.Atoi(fields[3])
+		if err1 != nil || err2 != nil {
+			return memcacheResponseError(line)
+		}
+
+		item := &MemcacheItem{Key: fields[1], Flags: uint32(flags)}
+		if len(fields) > 4 {
+			if item.CasID, err = strconv.ParseUint(fields[4], 10, 64); err != nil {
+				return memcacheResponseError(line)
+			}
+		}
+
+		data := make([]byte, size+2)
+		if _, err = io.ReadFull(r, data); err != nil {
+			return err
+		}
+		if !bytes.HasSuffix(data, []byte("\r\n")) {
+			return memcacheResponseError(line)
+		}
+		item.Value = data[:size]
+		items[item.Key] = item
+	}
+}
+
+func readMemcacheLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func memcacheResponseError(line string) error {
+	return fmt.Errorf("memcache: unexpected response %q", line)
+}
+
+// memcacheExpiration 超过 30 天的有效期转为时间戳
+func memcacheExpiration(seconds int) int64 {
+	if seconds > memcacheRelativeExpiry {
+		return time.Now().Unix() + int64(seconds)
+	}
+	return int64(seconds)
+}
+
+func validMemcacheKey(key string) bool {
+	if len(key) == 0 || len(key) > memcacheMaxKeyLength {
+		return false
+	}
+	for n := 0; n < len(key); n++ {
+		if key[n] <= ' ' || key[n] == 0x7f {
+			return false
+		}
+	}
+	return true
+}
@@ -1 +1,66 @@
 package cache
+
+import (
+	"context"
+	contractcache "owl/contract/cache"
+)
+
+// MemcacheLock 实现 contract\cache\lock 接口
+// 使用 add 加锁，值为持有者标识；释放时先 gets 确认持有者，再用 cas 把锁改为立即过期，
+// 保证在确认之后被其他持有者重新获取的锁不会被误删
+type MemcacheLock struct {
+	baseLock
+	client *MemcacheClient
+}
+
+// NewMemcacheLock name 为锁在 memcached 中的完整 key，owner 为空时随机生成
+func NewMemcacheLock(client *MemcacheClient, name string, seconds int, owner string) *MemcacheLock {
+	return &MemcacheLock{
+		baseLock: newBaseLock(name, seconds, owner),
+		client:   client,
+	}
+}
+
+func (i *MemcacheLock) Get(ctx context.Context, callback func()) error {
+	return i.get(ctx, i.acquire, i.Release, callback)
+}
+
+func (i *MemcacheLock) Block(ctx context.Context, seconds int, callback func()) error {
+	return i.block(ctx, seconds, i.acquire, i.Release, callback)
+}
+
+func (i *MemcacheLock) Release() bool {
+	item, err := i.client.Get(i.name)
+	if err != nil || string(item.Value) != i.owner {
+		return false
+	}
+	item.Expiration = -1
+	return i.client.CompareAndSwap(item) == nil
+}
+
+func (i *MemcacheLock) ForceRelease() {
+	_ = i.client.Delete(i.name)
+}
+
+// CurrentOwner 返回 memcached 中记录的持有者，锁不存在时返回空字符串
+func (i *MemcacheLock) CurrentOwner() string {
+	item, err := i.client.Get(i.name)
+	if err != nil {
+		return ""
+	}
+	return string(item.Value)
+}
+
+func (i *MemcacheLock) acquire() bool {
+	return i.client.Add(&MemcacheItem{Key: i.name, Value: []byte(i.owner), Expiration: i.seconds}) == nil
+}
+
+// Lock 实现 contract\cache\lock_provider 接口
+func (i *MemcacheStore) Lock(name string, seconds int, owner string) contractcache.Lock {
+	return NewMemcacheLock(i.client, i.prefix+lockPrefix+name, seconds, owner)
+}
+
+// RestoreLock 使用持有者标识恢复锁，可以在其他进程中释放这个锁
+func (i *MemcacheStore) RestoreLock(name, owner string) contractcache.Lock {
+	return i.Lock(name, 0, owner)
+}
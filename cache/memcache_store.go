@@ -1 +1,218 @@
 package cache
+
+import (
+	"errors"
+	"fmt"
+	"owl"
+	"owl/contract"
+	"strconv"
+	"time"
+)
+
+type MemcacheOptions struct {
+	contract.ServerConfig
+	Prefix       string `json:"prefix"`
+	Timeout      int    `json:"timeout"`        // 读写超时，毫秒，默认 1000
+	MaxIdleConns int    `json:"max-idle-conns"` // 最多保留的空闲连接数
+}
+
+// NewMemcacheOption 读取 memcached 配置，cfgFile 为 conf 目录下的文件名，例如 memcached 对应 conf/memcached.yml
+func NewMemcacheOption(cfgManager *owl.ConfManager, cfgFile string) (opt *MemcacheOptions) {
+	err := cfgManager.GetConfig(cfgFile, &opt)
+	if err != nil {
+		return nil
+	}
+	return opt
+}
+
+func DefaultMemcacheOptions() *MemcacheOptions {
+	return &MemcacheOptions{
+		ServerConfig: contract.ServerConfig{
+			Host: "127.0.0.1",
+			Port: 11211,
+		},
+		Timeout:      1000,
+		MaxIdleConns: 10,
+	}
+}
+
+// NewMemcacheClientFromOptions 根据配置创建 memcached 客户端，opt 为 nil 时使用默认配置
+func NewMemcacheClientFromOptions(opt *MemcacheOptions) *MemcacheClient {
+	if opt == nil {
+		opt = DefaultMemcacheOptions()
+	}
+	return NewMemcacheClient(
+		fmt.Sprintf("%s:%d", opt.Host, opt.Port),
+		time.Duration(opt.Timeout)*time.Millisecond,
+		opt.MaxIdleConns,
+	)
+}
+
+// MemcacheStore memcached 缓存，实现 contract\cache\store 接口
+// memcached 不支持按前缀删除，Flush 会清空整个服务器
+type MemcacheStore struct {
+	client     *MemcacheClient
+	prefix     string
+	serializer Serializer
+}
+
+func NewMemcacheStore(client *MemcacheClient, prefix string) *MemcacheStore {
+	return &MemcacheStore{
+		client:     client,
+		prefix:     prefix,
+		serializer: GobSerializer{},
+	}
+}
+
+// NewMemcacheStoreFromConfig 使用 conf/{cfgFile}.yml 中的配置创建 memcached 缓存
+func NewMemcacheStoreFromConfig(cfgManager *owl.ConfManager, cfgFile string) *MemcacheStore {
+	opt := NewMemcacheOption(cfgManager, cfgFile)
+	prefix := ""
+	if opt != nil {
+		prefix = opt.Prefix
+	}
+	return NewMemcacheStore(NewMemcacheClientFromOptions(opt), prefix)
+}
+
+// Client 返回底层的 memcached 客户端
+func (i *MemcacheStore) Client() *MemcacheClient {
+	return i.client
+}
+
+func (i *MemcacheStore) Get(key interface{}) interface{} {
+	if keys, ok := key.([]string); ok {
+		return i.Many(keys)
+	}
+
+	item, err := i.client.Get(i.prefix + keyString(key))
+	if err != nil {
+		return nil
+	}
+	return i.unserialize(item.Value)
+}
+
+// Many 使用一条 gets 命令取回所有值
+func (i *MemcacheStore) Many(keys []string) []interface{} {
+	values := make([]interface{}, len(keys))
+	if len(keys) == 0 {
+		return values
+	}
+
+	prefixed := make([]string, len(keys))
+	for n, key := range keys {
+		prefixed[n] = i.prefix + key
+	}
+
+	items, err := i.client.GetMulti(prefixed)
+	if err != nil {
+		return values
+	}
+	for n, key := range prefixed {
+		if item, ok := items[key]; ok {
+			values[n] = i.unserialize(item.Value)
+		}
+	}
+	return values
+}
+
+func (i *MemcacheStore) Put(key string, value interface{}, seconds int) bool {
+	data, err := i.serializer.Serialize(value)
+	if err != nil {
+		return false
+	}
+	return i.client.Set(&MemcacheItem{Key: i.prefix + key, Value: data, Expiration: seconds}) == nil
+}
+
+// PutMany 文本协议没有批量写入命令，逐个写入
+func (i *MemcacheStore) PutMany(values []interface{}, seconds int) bool {
+	items, ok := pairs(values)
+	if !ok {
+		return false
+	}
+	for key, value := range items {
+		if !i.Put(key, value, seconds) {
+			return false
+		}
+	}
+	return true
+}
+
+// Add 使用 add 命令写入
+func (i *MemcacheStore) Add(key string, value interface{}, seconds int) bool {
+	data, err := i.serializer.Serialize(value)
+	if err != nil {
+		return false
+	}
+	return i.client.Add(&MemcacheItem{Key: i.prefix + key, Value: data, Expiration: seconds}) == nil
+}
+
+// Increment 使用 incr 命令，key 不存在时写入 value；value 为负数时使用 decr，结果最小为 0
+func (i *MemcacheStore) Increment(key string, value int) (int, bool) {
+	if value < 0 {
+		return i.Decrement(key, -value)
+	}
+	return i.incrDecr(key, value, i.client.Increment)
+}
+
+// Decrement 使用 decr 命令，memcached 中的计数器不会小于 0
+func (i *MemcacheStore) Decrement(key string, value int) (int, bool) {
+	if value < 0 {
+		return i.Increment(key, -value)
+	}
+	return i.incrDecr(key, -value, func(key string, delta uint64) (uint64, error) {
+		return i.client.Decrement(key, delta)
+	})
+}
+
+func (i *MemcacheStore) Forever(key string, value interface{}) bool {
+	return i.Put(key, value, 0)
+}
+
+func (i *MemcacheStore) Forget(key string) bool {
+	return i.client.Delete(i.prefix+key) == nil
+}
+
+// Flush 清空整个 memcached 服务器，会影响使用同一服务器的其他前缀
+func (i *MemcacheStore) Flush() bool {
+	return i.client.FlushAll() == nil
+}
+
+func (i *MemcacheStore) GetPrefix() string {
+	return i.prefix
+}
+
+// incrDecr key 不存在时用 add 写入初始值，add 失败说明被并发写入，再执行一次 incr/decr
+func (i *MemcacheStore) incrDecr(key string, initial int, fn func(key string, delta uint64) (uint64, error)) (int, bool) {
+	delta := uint64(initial)
+	if initial < 0 {
+		delta = uint64(-initial)
+	}
+
+	prefixed := i.prefix + key
+	n, err := fn(prefixed, delta)
+	if errors.Is(err, MemcacheCacheMissError) {
+		if initial < 0 {
+			initial = 0
+		}
+		err = i.client.Add(&MemcacheItem{Key: prefixed, Value: []byte(strconv.Itoa(initial))})
+		if err == nil {
+			return initial, true
+		}
+		if !errors.Is(err, MemcacheNotStoredError) {
+			return 0, false
+		}
+		n, err = fn(prefixed, delta)
+	}
+	if err != nil {
+		return 0, false
+	}
+	return int(n), true
+}
+
+func (i *MemcacheStore) unserialize(data []byte) interface{} {
+	value, err := i.serializer.Unserialize(data)
+	if err != nil {
+		return nil
+	}
+	return value
+}
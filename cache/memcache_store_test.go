@@ -0,0 +1,128 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"owl/cache/memcachetest"
+	contractcache "owl/contract/cache"
+	"testing"
+	"time"
+)
+
+func newTestMemcacheStore(t *testing.T, prefix string) (*MemcacheStore, *memcachetest.Server) {
+	t.Helper()
+	server, err := memcachetest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewMemcacheClient(server.Addr(), time.Second, 2)
+	t.Cleanup(func() {
+		_ = client.Close()
+		server.Close()
+	})
+	return NewMemcacheStore(client, prefix), server
+}
+
+func TestMemcacheStorePutGet(t *testing.T) {
+	store, server := newTestMemcacheStore(t, "owl:")
+
+	user := redisTestUser{Name: "owl", Age: 3}
+	if !store.Put("user", user, 10) {
+		t.Fatal("Put() failed")
+	}
+	if got, ok := store.Get("user").(redisTestUser); !ok || got != user {
+		t.Fatalf("Get() = %#v, want %#v", store.Get("user"), user)
+	}
+	if !server.Exists("owl:user") {
+		t.Fatal("key should be stored with prefix")
+	}
+
+	server.FastForward(11 * time.Second)
+	if store.Get("user") != nil {
+		t.Fatal("expired key should miss")
+	}
+
+	if !store.Add("name", "owl", 10) || store.Add("name", "other", 10) {
+		t.Fatal("Add() should only write missing keys")
+	}
+	if !store.Forget("name") || store.Forget("name") {
+		t.Fatal("Forget() should report whether the key existed")
+	}
+	if store.Put("bad key", 1, 0) {
+		t.Fatal("Put() with an invalid key should fail")
+	}
+}
+
+func TestMemcacheStoreManyAndIncrement(t *testing.T) {
+	store, server := newTestMemcacheStore(t, "")
+
+	if !store.PutMany([]interface{}{"a", 1, "b", "two"}, 0) {
+		t.Fatal("PutMany() failed")
+	}
+	values := store.Many([]string{"a", "missing", "b"})
+	if values[0] != 1 || values[1] != nil || values[2] != "two" {
+		t.Fatalf("Many() = %v", values)
+	}
+
+	if n, ok := store.Increment("a", 4); !ok || n != 5 {
+		t.Fatalf("Increment() = %d, %v", n, ok)
+	}
+	if n, ok := store.Increment("counter", 3); !ok || n != 3 {
+		t.Fatalf("Increment() on missing key = %d, %v", n, ok)
+	}
+	if n, ok := store.Decrement("counter", 1); !ok || n != 2 || store.Get("counter") != 2 {
+		t.Fatalf("Decrement() = %d, %v", n, ok)
+	}
+	if _, ok := store.Increment("b", 1); ok {
+		t.Fatal("Increment() on a non-numeric value should fail")
+	}
+
+	if !store.Flush() || server.Len() != 0 {
+		t.Fatal("Flush() should clear the server")
+	}
+}
+
+func TestMemcacheLock(t *testing.T) {
+	store, server := newTestMemcacheStore(t, "owl:")
+
+	first := store.Lock("job", 10, "")
+	if err := first.Get(context.Background(), nil); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if !server.Exists("owl:lock:job") {
+		t.Fatal("lock key should use the lock prefix")
+	}
+
+	second := store.Lock("job", 10, "")
+	if err := second.Get(context.Background(), nil); !errors.Is(err, contractcache.LockNotAcquiredError) {
+		t.Fatalf("second Get() error = %v", err)
+	}
+	if second.Release() {
+		t.Fatal("Release() by another owner should fail")
+	}
+	if first.(*MemcacheLock).CurrentOwner() != first.Owner() {
+		t.Fatal("CurrentOwner() should return the holder")
+	}
+	if !store.RestoreLock("job", first.Owner()).Release() {
+		t.Fatal("Release() by the owner should succeed")
+	}
+	if server.Exists("owl:lock:job") {
+		t.Fatal("released lock should be removed")
+	}
+
+	expiring := store.Lock("expiring", 1, "")
+	_ = expiring.Get(context.Background(), nil)
+	server.FastForward(2 * time.Second)
+	if err := store.Lock("expiring", 1, "").Get(context.Background(), nil); err != nil {
+		t.Fatalf("expired lock should be acquirable, error = %v", err)
+	}
+
+	store.Lock("job", 10, "").ForceRelease()
+	done := false
+	if err := second.Get(context.Background(), func() { done = true }); err != nil || !done {
+		t.Fatalf("Get() with callback error = %v", err)
+	}
+	if server.Exists("owl:lock:job") {
+		t.Fatal("lock should be released after the callback")
+	}
+}
@@ -0,0 +1,349 @@
+// Package memcachetest 提供进程内的 memcached 服务器，用于离线测试 memcached 缓存
+//
+// 实现了文本协议中的 get gets set add replace cas delete incr decr flush_all 命令，
+// 数据只保存在内存中，过期时间使用服务器自己的时钟，可以用 FastForward 快进
+package memcachetest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const relativeExpiry = 60 * 60 * 24 * 30
+
+type item struct {
+	value     []byte
+	flags     uint32
+	cas       uint64
+	expiresAt time.Time // 零值表示不过期
+}
+
+// Server 进程内的 memcached 服务器
+type Server struct {
+	listener net.Listener
+
+	mu     sync.Mutex
+	items  map[string]*item
+	casID  uint64
+	offset time.Duration // FastForward 累计快进的时间
+	conns  map[net.Conn]struct{}
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// NewServer 在 127.0.0.1 的随机端口上启动服务器
+func NewServer() (*Server, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	server := &Server{
+		listener: listener,
+		items:    make(map[string]*item),
+		conns:    make(map[net.Conn]struct{}),
+	}
+	server.wg.Add(1)
+	go server.serve()
+	return server, nil
+}
+
+// Addr 返回服务器监听的地址
+func (i *Server) Addr() string {
+	return i.listener.Addr().String()
+}
+
+// Close 关闭服务器和所有连接
+func (i *Server) Close() {
+	i.mu.Lock()
+	i.closed = true
+	for conn := range i.conns {
+		_ = conn.Close()
+	}
+	i.mu.Unlock()
+
+	_ = i.listener.Close()
+	i.wg.Wait()
+}
+
+// FastForward 让服务器的时钟快进 d
+func (i *Server) FastForward(d time.Duration) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.offset += d
+}
+
+// Exists key 是否存在且未过期
+func (i *Server) Exists(key string) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.get(key) != nil
+}
+
+// Len 返回未过期的条目数
+func (i *Server) Len() int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	n := 0
+	for key := range i.items {
+		if i.get(key) != nil {
+			n++
+		}
+	}
+	return n
+}
+
+func (i *Server) serve() {
+	defer i.wg.Done()
+	for {
+		conn, err := i.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		i.mu.Lock()
+		if i.closed {
+			i.mu.Unlock()
+			_ = conn.Close()
+			return
+		}
+		i.conns[conn] = struct{}{}
+		i.mu.Unlock()
+
+		i.wg.Add(1)
+		go i.handle(conn)
+	}
+}
+
+func (i *Server) handle(conn net.Conn) {
+	defer i.wg.Done()
+	defer func() {
+		i.mu.Lock()
+		delete(i.conns, conn)
+		i.mu.Unlock()
+		_ = conn.Close()
+	}()
+
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(strings.TrimRight(line, "\r\n"))
+		if len(fields) == 0 {
+			continue
+		}
+		if err = i.dispatch(fields, r, w); err != nil {
+			return
+		}
+		if err = w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func (i *Server) dispatch(fields []string, r *bufio.Reader, w *bufio.Writer) error {
+	switch fields[0] {
+	case "get", "gets":
+		i.retrieve(w, fields[1:], fields[0] == "gets")
+	case "set", "add", "replace", "cas":
+		return i.store(fields, r, w)
+	case "delete":
+		if len(fields) < 2 {
+			_, _ = w.WriteString("ERROR\r\n")
+			return nil
+		}
+		i.mu.Lock()
+		_, ok := i.items[fields[1]]
+		found := ok && i.get(fields[1]) != nil
+		delete(i.items, fields[1])
+		i.mu.Unlock()
+		if found {
+			_, _ = w.WriteString("DELETED\r\n")
+		} else {
+			_, _ = w.WriteString("NOT_FOUND\r\n")
+		}
+	case "incr", "decr":
+		i.incrDecr(w, fields)
+	case "flush_all":
+		i.mu.Lock()
+		i.items = make(map[string]*item)
+		i.mu.Unlock()
+		_, _ = w.WriteString("OK\r\n")
+	case "quit":
+		return io.EOF
+	default:
+		_, _ = w.WriteString("ERROR\r\n")
+	}
+	return nil
+}
+
+func (i *Server) retrieve(w *bufio.Writer, keys []string, withCas bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for _, key := range keys {
+		it := i.get(key)
+		if it == nil {
+			continue
+		}
+		if withCas {
+			_, _ = fmt.Fprintf(w, "VALUE %s %d %d %d\r\n", key, it.flags, len(it.value), it.cas)
+		} else {
+			_, _ = fmt.Fprintf(w, "VALUE %s %d %d\r\n", key, it.flags, len(it.value))
+		}
+		_, _ = w.Write(it.value)
+		_, _ = w.WriteString("\r\n")
+	}
+	_, _ = w.WriteString("END\r\n")
+}
+
+// store <command> <key> <flags> <exptime> <bytes> [<cas unique>]
+func (i *Server) store(fields []string, r *bufio.Reader, w *bufio.Writer) error {
+	if len(fields) < 5 || (fields[0] == "cas" && len(fields) < 6) {
+		_, _ = w.WriteString("ERROR\r\n")
+		return nil
+	}
+	flags, err1 := strconv.ParseUint(fields[2], 10, 32)
+	exptime, err2 := strconv.ParseInt(fields[3], 10, 64)
+	size, err3 := strconv.Atoi(fields[4])
+	if err1 != nil || err2 != nil || err3 != nil || size < 0 {
+		_, _ = w.WriteString("CLIENT_ERROR bad command line format\r\n")
+		return nil
+	}
+
+	data := make([]byte, size+2)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return err
+	}
+	if string(data[size:]) != "\r\n" {
+		_, _ = w.WriteString("CLIENT_ERROR bad data chunk\r\n")
+		return nil
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	key := fields[1]
+	current := i.get(key)
+	switch fields[0] {
+	case "add":
+		if current != nil {
+			_, _ = w.WriteString("NOT_STORED\r\n")
+			return nil
+		}
+	case "replace":
+		if current == nil {
+			_, _ = w.WriteString("NOT_STORED\r\n")
+			return nil
+		}
+	case "cas":
+		cas, err := strconv.ParseUint(fields[5], 10, 64)
+		if err != nil {
+			_, _ = w.WriteString("CLIENT_ERROR bad command line format\r\n")
+			return nil
+		}
+		if current == nil {
+			_, _ = w.WriteString("NOT_FOUND\r\n")
+			return nil
+		}
+		if current.cas != cas {
+			_, _ = w.WriteString("EXISTS\r\n")
+			return nil
+		}
+	}
+
+	if exptime < 0 {
+		delete(i.items, key)
+		_, _ = w.WriteString("STORED\r\n")
+		return nil
+	}
+
+	i.casID++
+	i.items[key] = &item{
+		value:     data[:size],
+		flags:     uint32(flags),
+		cas:       i.casID,
+		expiresAt: i.expiresAt(exptime),
+	}
+	_, _ = w.WriteString("STORED\r\n")
+	return nil
+}
+
+// incrDecr <command> <key> <value>，decr 的结果最小为 0
+func (i *Server) incrDecr(w *bufio.Writer, fields []string) {
+	if len(fields) < 3 {
+		_, _ = w.WriteString("ERROR\r\n")
+		return
+	}
+	delta, err := strconv.ParseUint(fields[2], 10, 64)
+	if err != nil {
+		_, _ = w.WriteString("CLIENT_ERROR invalid numeric delta argument\r\n")
+		return
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	it := i.get(fields[1])
+	if it == nil {
+		_, _ = w.WriteString("NOT_FOUND\r\n")
+		return
+	}
+	n, err := strconv.ParseUint(string(it.value), 10, 64)
+	if err != nil {
+		_, _ = w.WriteString("CLIENT_ERROR cannot increment or decrement non-numeric value\r\n")
+		return
+	}
+
+	if fields[0] == "incr" {
+		n += delta
+	} else if delta > n {
+		n = 0
+	} else {
+		n -= delta
+	}
+
+	i.casID++
+	it.value = []byte(strconv.FormatUint(n, 10))
+	it.cas = i.casID
+	_, _ = fmt.Fprintf(w, "%d\r\n", n)
+}
+
+// get 调用方需要持有锁，过期的条目直接删除
+func (i *Server) get(key string) *item {
+	it, ok := i.items[key]
+	if !ok {
+		return nil
+	}
+	if !it.expiresAt.IsZero() && !i.now().Before(it.expiresAt) {
+		delete(i.items, key)
+		return nil
+	}
+	return it
+}
+
+func (i *Server) now() time.Time {
+	return time.Now().Add(i.offset)
+}
+
+// expiresAt 0 表示不过期，超过 30 天的值是时间戳
+func (i *Server) expiresAt(exptime int64) time.Time {
+	switch {
+	case exptime == 0:
+		return time.Time{}
+	case exptime > relativeExpiry:
+		return time.Unix(exptime, 0)
+	default:
+		return i.now().Add(time.Duration(exptime) * time.Second)
+	}
+}
@@ -1 +1,33 @@
 package cache
+
+import "context"
+
+// NoLock 总是能获取成功的锁，用于测试和单实例部署
+type NoLock struct {
+	baseLock
+}
+
+func NewNoLock(name string, seconds int, owner string) *NoLock {
+	return &NoLock{
+		baseLock: newBaseLock(name, seconds, owner),
+	}
+}
+
+func (i *NoLock) Get(ctx context.Context, callback func()) error {
+	return i.get(ctx, i.acquire, i.Release, callback)
+}
+
+func (i *NoLock) Block(ctx context.Context, seconds int, callback func()) error {
+	return i.block(ctx, seconds, i.acquire, i.Release, callback)
+}
+
+func (i *NoLock) Release() bool {
+	return true
+}
+
+func (i *NoLock) ForceRelease() {
+}
+
+func (i *NoLock) acquire() bool {
+	return true
+}
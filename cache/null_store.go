@@ -1 +1,85 @@
 package cache
+
+import contractcache "owl/contract/cache"
+
+// NullStore 不保存任何数据的缓存，实现 contract\cache\store 接口
+// 读取总是未命中，写入会被丢弃但返回成功，用于在某个环境中关闭缓存；
+// 同样支持标签和锁，业务代码切换到这个存储时不需要修改
+type NullStore struct{}
+
+func NewNullStore() *NullStore {
+	return &NullStore{}
+}
+
+func (i *NullStore) Get(key interface{}) interface{} {
+	if keys, ok := key.([]string); ok {
+		return i.Many(keys)
+	}
+	return nil
+}
+
+func (i *NullStore) Many(keys []string) []interface{} {
+	return make([]interface{}, len(keys))
+}
+
+func (i *NullStore) Put(key string, value interface{}, seconds int) bool {
+	return true
+}
+
+func (i *NullStore) PutMany(values []interface{}, seconds int) bool {
+	return true
+}
+
+func (i *NullStore) Add(key string, value interface{}, seconds int) bool {
+	return true
+}
+
+// Increment 没有保存过的计数器，结果总是从 0 开始计算
+func (i *NullStore) Increment(key string, value int) (int, bool) {
+	return value, true
+}
+
+func (i *NullStore) Decrement(key string, value int) (int, bool) {
+	return -value, true
+}
+
+func (i *NullStore) Forever(key string, value interface{}) bool {
+	return true
+}
+
+func (i *NullStore) Forget(key string) bool {
+	return true
+}
+
+func (i *NullStore) Flush() bool {
+	return true
+}
+
+func (i *NullStore) GetPrefix() string {
+	return ""
+}
+
+func (i *NullStore) AddTagEntries(tag string, keys ...string) bool {
+	return true
+}
+
+func (i *NullStore) TagEntries(tag string) []string {
+	return nil
+}
+
+func (i *NullStore) ForgetTag(tag string) bool {
+	return true
+}
+
+func (i *NullStore) ForgetTagEntries(tag string, keys ...string) bool {
+	return true
+}
+
+// Lock 返回总是能获取成功的 NoLock
+func (i *NullStore) Lock(name string, seconds int, owner string) contractcache.Lock {
+	return NewNoLock(name, seconds, owner)
+}
+
+func (i *NullStore) RestoreLock(name, owner string) contractcache.Lock {
+	return i.Lock(name, 0, owner)
+}
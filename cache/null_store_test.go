@@ -0,0 +1,75 @@
+package cache
+
+import (
+	"context"
+	"os"
+	"owl"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNullStore(t *testing.T) {
+	repository := NewRepository(NewNullStore())
+
+	if !repository.Put("name", "owl", 60) || !repository.Forever("name", "owl") || !repository.Add("name", "owl", 60) {
+		t.Fatal("writes to the null store should be accepted")
+	}
+	if err := Put(repository, "typed", "owl", 60); err != nil {
+		t.Fatalf("Put[T]() error = %v", err)
+	}
+	if n, ok := repository.Increment("counter", 2); !ok || n != 2 {
+		t.Fatalf("Increment() = %d, %v", n, ok)
+	}
+	if repository.Has("name") || repository.Get("name", "default") != "default" {
+		t.Fatal("null store should always miss")
+	}
+
+	calls := 0
+	for n := 0; n < 2; n++ {
+		repository.Remember("name", 60, func() any {
+			calls++
+			return "owl"
+		})
+	}
+	if calls != 2 {
+		t.Fatalf("Remember() callback calls = %d, want 2", calls)
+	}
+
+	mustTags(t, repository, "users").Put("u1", 1, 60)
+	if !mustTags(t, repository, "users").Flush() {
+		t.Fatal("tagged flush should succeed")
+	}
+
+	store := repository.GetStore().(*NullStore)
+	if err := store.Lock("job", 10, "").Get(context.Background(), nil); err != nil {
+		t.Fatalf("Lock().Get() error = %v", err)
+	}
+	if values := store.Many([]string{"a", "b"}); len(values) != 2 || values[0] != nil {
+		t.Fatalf("Many() = %v", values)
+	}
+}
+
+func TestNullDriverFromYAML(t *testing.T) {
+	dir := t.TempDir()
+	content := "default: none\nstores:\n  none:\n    driver: null\n  quoted:\n    driver: \"null\"\n  typo:\n    drvier: array\n"
+	if err := os.WriteFile(filepath.Join(dir, "cache.yml"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	manager := NewCacheManagerWithOptions(nil, nil, NewOption(owl.NewConfigManagerFromDir(dir, nil)))
+
+	repository, err := manager.Resolve("quoted")
+	if err != nil {
+		t.Fatalf("Resolve(quoted) error = %v", err)
+	}
+	if _, ok := repository.GetStore().(*NullStore); !ok {
+		t.Fatalf("quoted store = %T, want *NullStore", repository.GetStore())
+	}
+
+	// 不加引号的 null 读取后为空值，不能悄悄关闭缓存
+	for _, name := range []string{"none", "typo"} {
+		if _, err = manager.Resolve(name); err == nil || !strings.Contains(err.Error(), `driver: "null"`) {
+			t.Fatalf("Resolve(%s) error = %v, want missing driver", name, err)
+		}
+	}
+}
@@ -3,34 +3,93 @@ package cache
 import (
 	"context"
 	"github.com/go-redis/redis"
+	contractcache "owl/contract/cache"
+	"strings"
+	"time"
 )
 
-// 实现 contract\cache\lock 接口
+// releaseScript 只有持有者才能删除锁
+var releaseScript = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+    return redis.call("del", KEYS[1])
+else
+    return 0
+end
+`)
+
+// RedisLock 实现 contract\cache\lock 接口
+// 使用 SET NX PX 加锁，值为持有者标识
 type RedisLock struct {
+	baseLock
 	c *redis.Client
 }
 
-func (r RedisLock) Get(ctx context.Context, callback func()) error {
-	//TODO implement me
-	panic("implement me")
+// NewRedisLock name 为锁在 redis 中的完整 key，owner 为空时随机生成
+func NewRedisLock(c *redis.Client, name string, seconds int, owner string) *RedisLock {
+	return &RedisLock{
+		baseLock: newBaseLock(name, seconds, owner),
+		c:        c,
+	}
+}
+
+func (i *RedisLock) Get(ctx context.Context, callback func()) error {
+	return i.get(ctx, i.acquire, i.Release, callback)
+}
+
+func (i *RedisLock) Block(ctx context.Context, seconds int, callback func()) error {
+	return i.block(ctx, seconds, i.acquire, i.Release, callback)
+}
+
+func (i *RedisLock) Release() bool {
+	n, err := releaseScript.Run(i.c, []string{i.name}, i.owner).Int64()
+	return err == nil && n > 0
+}
+
+func (i *RedisLock) ForceRelease() {
+	i.c.Del(i.name)
+}
+
+// CurrentOwner 返回 redis 中记录的持有者，锁不存在时返回空字符串
+func (i *RedisLock) CurrentOwner() string {
+	owner, err := i.c.Get(i.name).Result()
+	if err != nil {
+		return ""
+	}
+	return owner
 }
 
-func (r RedisLock) Block(ctx context.Context, seconds int, callback func()) error {
-	//TODO implement me
-	panic("implement me")
+func (i *RedisLock) acquire() bool {
+	ok, err := i.c.SetNX(i.name, i.owner, ttl(i.seconds)).Result()
+	return err == nil && ok
 }
 
-func (r RedisLock) Release() bool {
-	//TODO implement me
-	panic("implement me")
+// Lock 实现 contract\cache\lock_provider 接口
+func (i *RedisStore) Lock(name string, seconds int, owner string) contractcache.Lock {
+	return NewRedisLock(i.client, i.prefix+lockPrefix+name, seconds, owner)
 }
 
-func (r RedisLock) Owner() string {
-	//TODO implement me
-	panic("implement me")
+// RestoreLock 使用持有者标识恢复锁，可以在其他进程中释放这个锁
+func (i *RedisStore) RestoreLock(name, owner string) contractcache.Lock {
+	return i.Lock(name, 0, owner)
 }
 
-func (r RedisLock) ForceRelease() {
-	//TODO implement me
-	panic("implement me")
+// Locks 扫描当前前缀下的锁
+func (i *RedisStore) Locks() ([]LockInfo, error) {
+	prefix := i.prefix + lockPrefix
+	var locks []LockInfo
+	err := i.scan(globPrefix(prefix), func(keys []string) error {
+		for _, key := range keys {
+			owner, err := i.client.Get(key).Result()
+			if err != nil {
+				continue
+			}
+			info := LockInfo{Name: strings.TrimPrefix(key, prefix), Owner: owner}
+			if d, err := i.client.PTTL(key).Result(); err == nil && d > 0 {
+				info.ExpiresAt = time.Now().Add(d)
+			}
+			locks = append(locks, info)
+		}
+		return nil
+	})
+	return locks, err
 }
@@ -0,0 +1,94 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	contractcache "owl/contract/cache"
+	"testing"
+	"time"
+)
+
+func TestRedisLockOwnership(t *testing.T) {
+	store, server := newTestRedisStore(t, "owl:")
+
+	first := store.Lock("job", 10, "")
+	if err := first.Get(context.Background(), nil); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got, _ := server.Get("owl:lock:job"); got != first.Owner() {
+		t.Fatalf("lock value = %q, want owner %q", got, first.Owner())
+	}
+
+	second := store.Lock("job", 10, "")
+	if err := second.Get(context.Background(), nil); !errors.Is(err, contractcache.LockNotAcquiredError) {
+		t.Fatalf("second Get() error = %v", err)
+	}
+	if second.Release() {
+		t.Fatal("Release() by another owner should fail")
+	}
+
+	restored := store.RestoreLock("job", first.Owner())
+	if !restored.Release() {
+		t.Fatal("restored lock should release")
+	}
+	if server.Exists("owl:lock:job") {
+		t.Fatal("lock key still exists after Release()")
+	}
+}
+
+func TestRedisLockCallbackReleases(t *testing.T) {
+	store, server := newTestRedisStore(t, "")
+
+	called := false
+	err := store.Lock("job", 10, "").Get(context.Background(), func() {
+		called = true
+		if !server.Exists("lock:job") {
+			t.Error("lock should be held during callback")
+		}
+	})
+	if err != nil || !called {
+		t.Fatalf("Get() error = %v, called = %v", err, called)
+	}
+	if server.Exists("lock:job") {
+		t.Fatal("lock should be released after callback")
+	}
+}
+
+func TestRedisLockBlock(t *testing.T) {
+	store, _ := newTestRedisStore(t, "")
+
+	held := store.Lock("job", 10, "")
+	_ = held.Get(context.Background(), nil)
+
+	start := time.Now()
+	err := store.Lock("job", 10, "").Block(context.Background(), 1, nil)
+	if !errors.Is(err, contractcache.LockTimeoutExceededError) {
+		t.Fatalf("Block() error = %v", err)
+	}
+	if time.Since(start) < time.Second {
+		t.Fatal("Block() returned before the deadline")
+	}
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		held.ForceRelease()
+	}()
+	if err = store.Lock("job", 10, "").Block(context.Background(), 2, nil); err != nil {
+		t.Fatalf("Block() after release error = %v", err)
+	}
+}
+
+func TestRedisStoreLocks(t *testing.T) {
+	store, _ := newTestRedisStore(t, "owl:")
+	lock := store.Lock("job", 10, "")
+	_ = lock.Get(context.Background(), nil)
+	store.Put("name", "owl", 0)
+
+	locks, err := store.Locks()
+	if err != nil || len(locks) != 1 || locks[0].Name != "job" || locks[0].Owner != lock.Owner() {
+		t.Fatalf("Locks() = %+v, %v", locks, err)
+	}
+	if stats, err := store.Stats(); err != nil || stats.Items != 2 {
+		t.Fatalf("Stats() = %+v, %v", stats, err)
+	}
+}
@@ -1,57 +1,300 @@
 package cache
 
-import "github.com/go-redis/redis"
+import (
+	"fmt"
+	"github.com/go-redis/redis"
+	"owl"
+	"owl/contract"
+	"strings"
+	"time"
+)
 
+type RedisOptions struct {
+	contract.ServerConfig
+	Database int    `json:"database"`
+	Prefix   string `json:"prefix"`
+	PoolSize int    `json:"pool-size"`
+}
+
+// NewRedisOption 读取 redis 配置，cfgFile 为 conf 目录下的文件名，例如 redis 对应 conf/redis.yml
+func NewRedisOption(cfgManager *owl.ConfManager, cfgFile string) (opt *RedisOptions) {
+	err := cfgManager.GetConfig(cfgFile, &opt)
+	if err != nil {
+		return nil
+	}
+	return opt
+}
+
+func DefaultRedisOptions() *RedisOptions {
+	return &RedisOptions{
+		ServerConfig: contract.ServerConfig{
+			Host: "127.0.0.1",
+			Port: 6379,
+		},
+		Database: 0,
+		PoolSize: 10,
+	}
+}
+
+// NewRedisClient 根据配置创建 redis 客户端，opt 为 nil 时使用默认配置
+func NewRedisClient(opt *RedisOptions) *redis.Client {
+	if opt == nil {
+		opt = DefaultRedisOptions()
+	}
+	return redis.NewClient(&redis.Options{
+		Addr:     fmt.Sprintf("%s:%d", opt.Host, opt.Port),
+		Password: opt.Password,
+		DB:       opt.Database,
+		PoolSize: opt.PoolSize,
+	})
+}
+
+// RedisStore 实现 contract\cache\store 接口
 type RedisStore struct {
-	client *redis.Client
+	client     *redis.Client
+	prefix     string
+	serializer Serializer
+}
+
+func NewRedisStore(client *redis.Client, prefix string) *RedisStore {
+	return &RedisStore{
+		client:     client,
+		prefix:     prefix,
+		serializer: GobSerializer{},
+	}
+}
+
+// NewRedisStoreFromConfig 使用 conf/{cfgFile}.yml 中的配置创建 redis 缓存
+func NewRedisStoreFromConfig(cfgManager *owl.ConfManager, cfgFile string) *RedisStore {
+	opt := NewRedisOption(cfgManager, cfgFile)
+	prefix := ""
+	if opt != nil {
+		prefix = opt.Prefix
+	}
+	return NewRedisStore(NewRedisClient(opt), prefix)
+}
+
+// Client 返回底层的 redis 客户端
+func (i *RedisStore) Client() *redis.Client {
+	return i.client
+}
+
+func (i *RedisStore) Get(key interface{}) interface{} {
+	if keys, ok := key.([]string); ok {
+		return i.Many(keys)
+	}
+
+	data, err := i.client.Get(i.prefix + keyString(key)).Bytes()
+	if err != nil {
+		return nil
+	}
+	return i.unserialize(data)
+}
+
+// Many 使用 MGET 一次往返取回所有值
+func (i *RedisStore) Many(keys []string) []interface{} {
+	values := make([]interface{}, len(keys))
+	if len(keys) == 0 {
+		return values
+	}
+
+	prefixed := make([]string, len(keys))
+	for n, key := range keys {
+		prefixed[n] = i.prefix + key
+	}
+
+	result, err := i.client.MGet(prefixed...).Result()
+	if err != nil {
+		return values
+	}
+
+	for n, value := range result {
+		if s, ok := value.(string); ok {
+			values[n] = i.unserialize([]byte(s))
+		}
+	}
+	return values
+}
+
+func (i *RedisStore) Put(key string, value interface{}, seconds int) bool {
+	data, err := i.serializer.Serialize(value)
+	if err != nil {
+		return false
+	}
+	return i.client.Set(i.prefix+key, data, ttl(seconds)).Err() == nil
+}
+
+// PutMany 使用事务管道一次写入
+func (i *RedisStore) PutMany(values []interface{}, seconds int) bool {
+	items, ok := pairs(values)
+	if !ok {
+		return false
+	}
+
+	_, err := i.client.TxPipelined(func(pipe redis.Pipeliner) error {
+		for key, value := range items {
+			data, err := i.serializer.Serialize(value)
+			if err != nil {
+				return err
+			}
+			pipe.Set(i.prefix+key, data, ttl(seconds))
+		}
+		return nil
+	})
+	return err == nil
+}
+
+// Add 使用 SET NX 写入
+func (i *RedisStore) Add(key string, value interface{}, seconds int) bool {
+	data, err := i.serializer.Serialize(value)
+	if err != nil {
+		return false
+	}
+	ok, err := i.client.SetNX(i.prefix+key, data, ttl(seconds)).Result()
+	return err == nil && ok
+}
+
+func (i *RedisStore) Increment(key string, value int) (int, bool) {
+	n, err := i.client.IncrBy(i.prefix+key, int64(value)).Result()
+	if err != nil {
+		return 0, false
+	}
+	return int(n), true
+}
+
+func (i *RedisStore) Decrement(key string, value int) (int, bool) {
+	n, err := i.client.DecrBy(i.prefix+key, int64(value)).Result()
+	if err != nil {
+		return 0, false
+	}
+	return int(n), true
+}
+
+func (i *RedisStore) Forever(key string, value interface{}) bool {
+	return i.Put(key, value, 0)
+}
+
+func (i *RedisStore) Forget(key string) bool {
+	n, err := i.client.Del(i.prefix + key).Result()
+	return err == nil && n > 0
+}
+
+// Flush 使用 SCAN 删除当前前缀下的所有 key，不会影响同一个库中的其他数据
+// 前缀为空时整个库都属于这个缓存，使用 FlushDB 清空
+func (i *RedisStore) Flush() bool {
+	if i.prefix == "" {
+		return i.FlushDB()
+	}
+	return i.deleteMatch(globPrefix(i.prefix)) == nil
+}
+
+// FlushDB 清空整个 redis 库，包括其他前缀和非缓存的数据
+func (i *RedisStore) FlushDB() bool {
+	return i.client.FlushDB().Err() == nil
+}
+
+func (i *RedisStore) GetPrefix() string {
+	return i.prefix
 }
 
-func (r RedisStore) Get(key interface{}) interface{} {
-	//TODO implement me
-	panic("implement me")
+// AddTagEntries 标签下的 key 保存在集合 {prefix}tag:{tag}:entries 中
+func (i *RedisStore) AddTagEntries(tag string, keys ...string) bool {
+	if len(keys) == 0 {
+		return true
+	}
+	members := make([]interface{}, len(keys))
+	for n, key := range keys {
+		members[n] = key
+	}
+	return i.client.SAdd(i.prefix+tagEntriesKey(tag), members...).Err() == nil
 }
 
-func (r RedisStore) Many(keys []string) []interface{} {
-	//TODO implement me
-	panic("implement me")
+func (i *RedisStore) TagEntries(tag string) []string {
+	keys, err := i.client.SMembers(i.prefix + tagEntriesKey(tag)).Result()
+	if err != nil {
+		return nil
+	}
+	return keys
 }
 
-func (r RedisStore) Put(key string, value interface{}, seconds int) bool {
-	//TODO implement me
-	panic("implement me")
+func (i *RedisStore) ForgetTag(tag string) bool {
+	return i.client.Del(i.prefix+tagEntriesKey(tag)).Err() == nil
 }
 
-func (r RedisStore) PutMany(values []interface{}, seconds int) bool {
-	//TODO implement me
-	panic("implement me")
+func (i *RedisStore) ForgetTagEntries(tag string, keys ...string) bool {
+	if len(keys) == 0 {
+		return true
+	}
+	members := make([]interface{}, len(keys))
+	for n, key := range keys {
+		members[n] = key
+	}
+	return i.client.SRem(i.prefix+tagEntriesKey(tag), members...).Err() == nil
 }
 
-func (r RedisStore) Increment(key string, value int) (int, bool) {
-	//TODO implement me
-	panic("implement me")
+// Stats 统计当前前缀下的 key 数量，过期的 key 由 redis 清理
+func (i *RedisStore) Stats() (Stats, error) {
+	stats := Stats{Bytes: -1}
+	err := i.scan(globPrefix(i.prefix), func(keys []string) error {
+		stats.Items += int64(len(keys))
+		return nil
+	})
+	return stats, err
 }
 
-func (r RedisStore) Decrement(key string, value int) (int, bool) {
-	//TODO implement me
-	panic("implement me")
+// globPrefix 匹配以 prefix 开头的 key，prefix 中的 * ? [ ] \ 按原字符匹配
+func globPrefix(prefix string) string {
+	var b strings.Builder
+	for _, r := range prefix {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	b.WriteString("*")
+	return b.String()
 }
 
-func (r RedisStore) Forever(key string, value interface{}) bool {
-	//TODO implement me
-	panic("implement me")
+// deleteMatch 分批扫描并删除匹配的 key
+func (i *RedisStore) deleteMatch(pattern string) error {
+	return i.scan(pattern, func(keys []string) error {
+		return i.client.Del(keys...).Err()
+	})
 }
 
-func (r RedisStore) Forget(key string) bool {
-	//TODO implement me
-	panic("implement me")
+// scan 分批扫描匹配的 key，每批调用一次 fn
+func (i *RedisStore) scan(pattern string, fn func(keys []string) error) error {
+	var cursor uint64
+	for {
+		keys, next, err := i.client.Scan(cursor, pattern, 1000).Result()
+		if err != nil {
+			return err
+		}
+		if len(keys) > 0 {
+			if err = fn(keys); err != nil {
+				return err
+			}
+		}
+		cursor = next
+		if cursor == 0 {
+			return nil
+		}
+	}
 }
 
-func (r RedisStore) Flush() bool {
-	//TODO implement me
-	panic("implement me")
+func (i *RedisStore) unserialize(data []byte) interface{} {
+	value, err := i.serializer.Unserialize(data)
+	if err != nil {
+		return nil
+	}
+	return value
 }
 
-func (r RedisStore) GetPrefix() string {
-	//TODO implement me
-	panic("implement me")
+// ttl 转为 redis 的过期时间，0 表示不过期
+func ttl(seconds int) time.Duration {
+	if seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
 }
@@ -0,0 +1,124 @@
+package cache
+
+import (
+	"encoding/gob"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis"
+	"testing"
+	"time"
+)
+
+type redisTestUser struct {
+	Name string
+	Age  int
+}
+
+func init() {
+	gob.Register(redisTestUser{})
+}
+
+func newTestRedisStore(t *testing.T, prefix string) (*RedisStore, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	return NewRedisStore(client, prefix), server
+}
+
+func TestRedisStorePutGet(t *testing.T) {
+	store, server := newTestRedisStore(t, "owl:")
+
+	user := redisTestUser{Name: "owl", Age: 3}
+	if !store.Put("user", user, 10) {
+		t.Fatal("Put() failed")
+	}
+	if got, ok := store.Get("user").(redisTestUser); !ok || got != user {
+		t.Fatalf("Get() = %#v, want %#v", store.Get("user"), user)
+	}
+	if ttl := server.TTL("owl:user"); ttl != 10*time.Second {
+		t.Fatalf("TTL = %v, want 10s", ttl)
+	}
+
+	server.FastForward(11 * time.Second)
+	if v := store.Get("user"); v != nil {
+		t.Fatalf("expired key returned %v", v)
+	}
+
+	store.Forever("name", "owl")
+	if server.TTL("owl:name") != 0 {
+		t.Fatal("Forever() should not set a ttl")
+	}
+	if !store.Forget("name") || store.Get("name") != nil {
+		t.Fatal("Forget() did not remove the key")
+	}
+}
+
+func TestRedisStoreManyAndIncrement(t *testing.T) {
+	store, _ := newTestRedisStore(t, "")
+
+	if !store.PutMany([]interface{}{"a", 1, "b", "two"}, 60) {
+		t.Fatal("PutMany() failed")
+	}
+	values := store.Many([]string{"a", "missing", "b"})
+	if values[0] != 1 || values[1] != nil || values[2] != "two" {
+		t.Fatalf("Many() = %v", values)
+	}
+
+	if n, ok := store.Increment("a", 4); !ok || n != 5 {
+		t.Fatalf("Increment() = %d, %v", n, ok)
+	}
+	if n, ok := store.Decrement("a", 2); !ok || n != 3 {
+		t.Fatalf("Decrement() = %d, %v", n, ok)
+	}
+	if _, ok := store.Increment("b", 1); ok {
+		t.Fatal("Increment() on non numeric value should fail")
+	}
+}
+
+func TestRedisStoreFlushOnlyPrefix(t *testing.T) {
+	store, server := newTestRedisStore(t, "owl:")
+
+	_ = server.Set("other", "keep")
+	store.Put("a", 1, 0)
+	store.Put("b", 2, 0)
+
+	if !store.Flush() {
+		t.Fatal("Flush() failed")
+	}
+	if server.Exists("owl:a") || server.Exists("owl:b") {
+		t.Fatal("Flush() left prefixed keys")
+	}
+	if !server.Exists("other") {
+		t.Fatal("Flush() removed a key outside the prefix")
+	}
+}
+
+func TestRedisStoreFlushEmptyPrefix(t *testing.T) {
+	store, server := newTestRedisStore(t, "")
+
+	_ = server.Set("other", "value")
+	store.Put("key", "value", 0)
+	if !store.Flush() {
+		t.Fatal("Flush() without prefix failed")
+	}
+	if server.Exists("other") || server.Exists("key") {
+		t.Fatal("Flush() without prefix should flush the whole db")
+	}
+}
+
+func TestRedisStoreFlushEscapesPrefix(t *testing.T) {
+	store, server := newTestRedisStore(t, "owl[1]*:")
+
+	_ = server.Set("owl1x:other", "keep")
+	store.Put("a", 1, 0)
+
+	if !store.Flush() {
+		t.Fatal("Flush() failed")
+	}
+	if server.Exists("owl[1]*:a") {
+		t.Fatal("Flush() left prefixed keys")
+	}
+	if !server.Exists("owl1x:other") {
+		t.Fatal("Flush() treated the prefix as a pattern")
+	}
+}
@@ -0,0 +1,277 @@
+package cache
+
+import (
+	"golang.org/x/sync/singleflight"
+	"owl/cache/event"
+	contractcache "owl/contract/cache"
+	"time"
+)
+
+// Repository 缓存仓库，实现 contract\cache\repository 接口，可以包装任意 Store
+//
+// ttl 参数支持：
+//   - nil 使用默认有效期，没有配置默认有效期时永久保存
+//   - int 等整数类型，单位秒
+//   - time.Duration 有效时长
+//   - time.Time 过期时间点
+//
+// ttl 计算结果 <= 0 时 Put 会删除缓存，Put、Add 都返回 false
+type Repository struct {
+	store      contractcache.Store
+	name       string // 在缓存配置中的名称
+	defaultTTL int    // ttl 传 nil 时使用的有效期，秒，<= 0 表示永久
+	group      singleflight.Group
+	events     *event.Dispatcher // 为 nil 时不触发事件
+	codec      Codec             // 泛型方法使用的编解码方式，为 nil 时使用 DefaultCodec
+}
+
+func NewRepository(store contractcache.Store) *Repository {
+	return &Repository{
+		store: store,
+	}
+}
+
+// Name 返回仓库在缓存配置中的名称
+func (i *Repository) Name() string {
+	return i.name
+}
+
+// SetEventDispatcher 设置事件分发器，读写缓存时触发 event 包中的事件
+func (i *Repository) SetEventDispatcher(events *event.Dispatcher) {
+	i.events = events
+}
+
+// SetCodec 设置泛型方法 Get[T]、Remember[T] 等使用的编解码方式
+func (i *Repository) SetCodec(codec Codec) {
+	i.codec = codec
+}
+
+// Codec 返回泛型方法使用的编解码方式
+func (i *Repository) Codec() Codec {
+	if i.codec == nil {
+		return DefaultCodec
+	}
+	return i.codec
+}
+
+// GetEventDispatcher 返回事件分发器
+func (i *Repository) GetEventDispatcher() *event.Dispatcher {
+	return i.events
+}
+
+func (i *Repository) Get(key string, defaultVal any) any {
+	value := i.get(key)
+	if value == nil {
+		return defaultVal
+	}
+	return value
+}
+
+// Many 一次读取多个 key，没有命中的位置为 nil，每个 key 都会触发命中或未命中事件
+func (i *Repository) Many(keys []string) []any {
+	start := time.Now()
+	values := i.store.Many(keys)
+	if i.events == nil {
+		return values
+	}
+
+	for n, key := range keys {
+		if values[n] == nil {
+			i.events.Dispatch(event.CacheMissed{CacheEvent: i.cacheEvent(key, start)})
+		} else {
+			i.events.Dispatch(event.CacheHit{CacheEvent: i.cacheEvent(key, start), Value: values[n]})
+		}
+	}
+	return values
+}
+
+func (i *Repository) Has(key string) bool {
+	return i.get(key) != nil
+}
+
+func (i *Repository) Pull(key string, defaultVal any) any {
+	value := i.Get(key, defaultVal)
+	i.Forget(key)
+	return value
+}
+
+// Put ttl 为 nil 时使用默认有效期，ttl <= 0 时删除缓存
+func (i *Repository) Put(key string, value any, ttl any) bool {
+	seconds, ok := i.seconds(ttl)
+	if !ok {
+		return i.Forever(key, value)
+	}
+	if seconds <= 0 {
+		i.Forget(key)
+		return false
+	}
+
+	start := time.Now()
+	result := i.store.Put(key, value, seconds)
+	if result {
+		i.fireWritten(key, value, seconds, start)
+	}
+	return result
+}
+
+// Add key 不存在时才写入，存储实现了 AddStore 时是原子操作
+func (i *Repository) Add(key string, value any, ttl any) bool {
+	seconds, ok := i.seconds(ttl)
+	if ok && seconds <= 0 {
+		return false
+	}
+	if !ok {
+		seconds = 0
+	}
+
+	start := time.Now()
+	var result bool
+	if store, ok := i.store.(AddStore); ok {
+		result = store.Add(key, value, seconds)
+	} else if i.store.Get(key) == nil {
+		result = i.store.Put(key, value, seconds)
+	}
+
+	if result {
+		i.fireWritten(key, value, seconds, start)
+	}
+	return result
+}
+
+func (i *Repository) Increment(key string, value int) (int, bool) {
+	return i.store.Increment(key, value)
+}
+
+func (i *Repository) Decrement(key string, value int) (int, bool) {
+	return i.store.Decrement(key, value)
+}
+
+func (i *Repository) Forever(key string, value any) bool {
+	start := time.Now()
+	result := i.store.Forever(key, value)
+	if result {
+		i.fireWritten(key, value, 0, start)
+	}
+	return result
+}
+
+// Remember 缓存不存在时执行 callback 并写入缓存，同一个 key 并发未命中时只会执行一次 callback
+func (i *Repository) Remember(key string, ttl any, callback func() any) any {
+	value, _ := i.remember(key, ttl, func() (any, error) {
+		return callback(), nil
+	})
+	return value
+}
+
+func (i *Repository) Sear(key string, callback func() any) any {
+	return i.RememberForever(key, callback)
+}
+
+func (i *Repository) RememberForever(key string, callback func() any) any {
+	if value := i.get(key); value != nil {
+		return value
+	}
+
+	value, _, _ := i.group.Do(key, func() (any, error) {
+		if value := i.store.Get(key); value != nil {
+			return value, nil
+		}
+		value := callback()
+		i.Forever(key, value)
+		return value, nil
+	})
+	return value
+}
+
+func (i *Repository) Forget(key string) bool {
+	start := time.Now()
+	result := i.store.Forget(key)
+	if result && i.events != nil {
+		i.events.Dispatch(event.KeyForgotten{CacheEvent: i.cacheEvent(key, start)})
+	}
+	return result
+}
+
+func (i *Repository) GetStore() contractcache.Store {
+	return i.store
+}
+
+// remember callback 返回错误时不写入缓存，并发未命中的调用共享同一个结果
+func (i *Repository) remember(key string, ttl any, callback func() (any, error)) (any, error) {
+	if value := i.get(key); value != nil {
+		return value, nil
+	}
+
+	value, err, _ := i.group.Do(key, func() (any, error) {
+		if value := i.store.Get(key); value != nil {
+			return value, nil
+		}
+		value, err := callback()
+		if err != nil {
+			return nil, err
+		}
+		i.Put(key, value, ttl)
+		return value, nil
+	})
+	return value, err
+}
+
+// get 读取缓存并触发命中或未命中事件
+func (i *Repository) get(key string) any {
+	start := time.Now()
+	value := i.store.Get(key)
+	if i.events == nil {
+		return value
+	}
+
+	if value == nil {
+		i.events.Dispatch(event.CacheMissed{CacheEvent: i.cacheEvent(key, start)})
+	} else {
+		i.events.Dispatch(event.CacheHit{CacheEvent: i.cacheEvent(key, start), Value: value})
+	}
+	return value
+}
+
+func (i *Repository) fireWritten(key string, value any, seconds int, start time.Time) {
+	if i.events != nil {
+		i.events.Dispatch(event.KeyWritten{CacheEvent: i.cacheEvent(key, start), Value: value, TTL: seconds})
+	}
+}
+
+func (i *Repository) cacheEvent(key string, start time.Time) event.CacheEvent {
+	return event.CacheEvent{
+		Key:       key,
+		StoreName: i.name,
+		Duration:  time.Since(start),
+	}
+}
+
+// seconds 把 ttl 转为秒，第二个返回值为 false 表示永久有效
+func (i *Repository) seconds(ttl any) (int, bool) {
+	switch t := ttl.(type) {
+	case nil:
+		return i.defaultTTL, i.defaultTTL > 0
+	case time.Duration:
+		return durationSeconds(t), true
+	case time.Time:
+		return durationSeconds(time.Until(t)), true
+	case *time.Time:
+		if t == nil {
+			return i.seconds(nil)
+		}
+		return durationSeconds(time.Until(*t)), true
+	default:
+		if n, ok := toInt(ttl); ok {
+			return n, true
+		}
+		return i.seconds(nil)
+	}
+}
+
+// durationSeconds 转为秒，不足一秒的部分向上取整，避免 1500ms 这样的时长变成 1 秒
+func durationSeconds(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	return int((d + time.Second - 1) / time.Second)
+}
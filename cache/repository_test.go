@@ -0,0 +1,151 @@
+package cache
+
+import (
+	"owl/cache/event"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRepositoryTTL(t *testing.T) {
+	repository := NewRepository(NewArrayStore("", 0))
+
+	cases := []struct {
+		ttl     any
+		seconds int
+		ok      bool
+	}{
+		{nil, 0, false},
+		{30, 30, true},
+		{int64(15), 15, true},
+		{1500 * time.Millisecond, 2, true},
+		{time.Now().Add(time.Minute), 60, true},
+		{time.Now().Add(-time.Minute), 0, true},
+	}
+	for _, c := range cases {
+		seconds, ok := repository.seconds(c.ttl)
+		if ok != c.ok || (seconds != c.seconds && seconds != c.seconds-1) {
+			t.Errorf("seconds(%v) = %d, %v, want %d, %v", c.ttl, seconds, ok, c.seconds, c.ok)
+		}
+	}
+
+	repository.defaultTTL = 90
+	if seconds, ok := repository.seconds(nil); !ok || seconds != 90 {
+		t.Fatalf("seconds(nil) with default ttl = %d, %v", seconds, ok)
+	}
+}
+
+func TestRepositoryPutAddPull(t *testing.T) {
+	repository := NewRepository(NewArrayStore("", 0))
+
+	if repository.Put("expired", 1, time.Now().Add(-time.Second)) {
+		t.Fatal("Put() with past time should not store")
+	}
+	if repository.Has("expired") {
+		t.Fatal("Put() with past time should forget the key")
+	}
+
+	if !repository.Add("name", "owl", time.Minute) {
+		t.Fatal("Add() on missing key should succeed")
+	}
+	if repository.Add("name", "other", time.Minute) {
+		t.Fatal("Add() on existing key should fail")
+	}
+
+	if v := repository.Pull("name", nil); v != "owl" {
+		t.Fatalf("Pull() = %v, want owl", v)
+	}
+	if v := repository.Get("name", "default"); v != "default" {
+		t.Fatalf("Get() after Pull = %v, want default", v)
+	}
+}
+
+func TestRepositoryRememberDeduplicates(t *testing.T) {
+	repository := NewRepository(NewArrayStore("", 0))
+
+	var calls int32
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for n := 0; n < 20; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			v := repository.Remember("slow", time.Minute, func() any {
+				atomic.AddInt32(&calls, 1)
+				time.Sleep(50 * time.Millisecond)
+				return "value"
+			})
+			if v != "value" {
+				t.Errorf("Remember() = %v", v)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Fatalf("callback called %d times, want 1", calls)
+	}
+	if v := repository.RememberForever("slow", func() any { return "other" }); v != "value" {
+		t.Fatalf("RememberForever() on cached key = %v", v)
+	}
+}
+
+func TestRepositoryEvents(t *testing.T) {
+	repository := NewRepository(NewArrayStore("", 0))
+	repository.name = "memory"
+	dispatcher := event.NewDispatcher()
+	repository.SetEventDispatcher(dispatcher)
+
+	var names []string
+	dispatcher.Listen(event.AllEvents, func(e event.Event) {
+		names = append(names, e.EventName())
+	})
+	var written event.KeyWritten
+	event.Listen(dispatcher, func(e event.KeyWritten) {
+		written = e
+	})
+
+	repository.Get("name", nil)
+	repository.Put("name", "owl", 30)
+	repository.Get("name", nil)
+	repository.Forget("name")
+
+	want := []string{event.CacheMissedName, event.KeyWrittenName, event.CacheHitName, event.KeyForgottenName}
+	if len(names) != len(want) {
+		t.Fatalf("events = %v, want %v", names, want)
+	}
+	for n := range want {
+		if names[n] != want[n] {
+			t.Fatalf("events = %v, want %v", names, want)
+		}
+	}
+	if written.Key != "name" || written.StoreName != "memory" || written.TTL != 30 || written.Value != "owl" {
+		t.Fatalf("KeyWritten = %+v", written)
+	}
+}
+
+func TestTypedManyFiresEvents(t *testing.T) {
+	repository := NewRepository(NewArrayStore("", 0))
+	dispatcher := event.NewDispatcher()
+	repository.SetEventDispatcher(dispatcher)
+
+	var names []string
+	dispatcher.Listen(event.AllEvents, func(e event.Event) {
+		names = append(names, e.EventName())
+	})
+
+	if err := Put(repository, "name", "owl", 30); err != nil {
+		t.Fatal(err)
+	}
+	names = nil
+	values, err := Many[string](repository, []string{"name", "missing"})
+	if err != nil || values["name"] != "owl" || len(values) != 1 {
+		t.Fatalf("Many() = %v, %v", values, err)
+	}
+	if len(names) != 2 || names[0] != event.CacheHitName || names[1] != event.CacheMissedName {
+		t.Fatalf("events = %v, want hit and miss", names)
+	}
+}
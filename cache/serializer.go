@@ -0,0 +1,58 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"strconv"
+)
+
+// Serializer 远程存储（redis、文件、数据库等）保存值时使用的序列化方式
+type Serializer interface {
+	Serialize(value interface{}) ([]byte, error)
+	Unserialize(data []byte) (interface{}, error)
+}
+
+func init() {
+	gob.Register(map[string]interface{}{})
+	gob.Register([]interface{}{})
+}
+
+// GobSerializer 默认的序列化方式
+// 整数按十进制文本保存，这样 redis INCRBY、数据库 SQL 自增等原生命令可以直接操作；
+// 其他值使用 gob 编码，保留具体类型，自定义结构体需要先 gob.Register 才能原样还原
+type GobSerializer struct {
+}
+
+// envelope gob 编码 interface{} 时需要包一层结构体才能保留具体类型
+type envelope struct {
+	V interface{}
+}
+
+func (i GobSerializer) Serialize(value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case int:
+		return []byte(strconv.Itoa(v)), nil
+	case int32:
+		return []byte(strconv.FormatInt(int64(v), 10)), nil
+	case int64:
+		return []byte(strconv.FormatInt(v, 10)), nil
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(envelope{V: value}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (i GobSerializer) Unserialize(data []byte) (interface{}, error) {
+	if n, err := strconv.Atoi(string(data)); err == nil {
+		return n, nil
+	}
+
+	var e envelope
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&e); err != nil {
+		return nil, err
+	}
+	return e.V, nil
+}
@@ -0,0 +1,36 @@
+package cache
+
+import (
+	"owl"
+	"owl/cache/event"
+	contractcache "owl/contract/cache"
+	"owl/log"
+)
+
+// ServiceProvider 缓存服务提供者，第一次从容器中获取缓存相关类型时才注册
+//
+//	stage.Register(cache.NewServiceProvider(stage))
+type ServiceProvider struct {
+	stage *owl.Stage
+}
+
+func NewServiceProvider(stage *owl.Stage) *ServiceProvider {
+	return &ServiceProvider{stage: stage}
+}
+
+func (i *ServiceProvider) Register() {
+	if err := Provide(i.stage); err != nil {
+		log.PrintLnRed("注册缓存服务失败: ", err)
+	}
+}
+
+func (i *ServiceProvider) Boot() {}
+
+func (i *ServiceProvider) Provides() []any {
+	return []any{
+		(*CacheManager)(nil),
+		(*contractcache.Factory)(nil),
+		(*contractcache.Repository)(nil),
+		(*event.Dispatcher)(nil),
+	}
+}
@@ -0,0 +1,48 @@
+package cache
+
+import "time"
+
+// AddStore 支持原子写入的存储，key 不存在时才写入
+// Repository.Add 优先使用这个接口，存储不支持时退化为先读后写
+type AddStore interface {
+	Add(key string, value interface{}, seconds int) bool
+}
+
+// TagStore 支持标签的存储，记录每个标签下写入过的 key，Repository.Tags 依赖这个接口
+type TagStore interface {
+	// AddTagEntries 记录 keys 属于 tag
+	AddTagEntries(tag string, keys ...string) bool
+
+	// TagEntries 返回 tag 下记录过的 key
+	TagEntries(tag string) []string
+
+	// ForgetTag 删除 tag 的记录，不会删除 key 本身
+	ForgetTag(tag string) bool
+
+	// ForgetTagEntries 从 tag 的记录中删除 keys，不会删除 key 本身
+	ForgetTagEntries(tag string, keys ...string) bool
+}
+
+// Stats 缓存存储的统计信息，无法统计的字段为 -1
+type Stats struct {
+	Items   int64 // 条目数
+	Expired int64 // 已过期但还未清理的条目数
+	Bytes   int64 // 占用的空间，字节
+}
+
+// StatsStore 可以统计缓存信息的存储，cache:stats 命令使用这个接口
+type StatsStore interface {
+	Stats() (Stats, error)
+}
+
+// LockInfo 存储中当前持有的锁
+type LockInfo struct {
+	Name      string    // 锁名称，与 Lock、RestoreLock 的 name 参数相同
+	Owner     string    // 持有者标识
+	ExpiresAt time.Time // 过期时间，零值表示不过期
+}
+
+// LockLister 可以列出当前持有的锁的存储，cache:locks 命令使用这个接口
+type LockLister interface {
+	Locks() ([]LockInfo, error)
+}
@@ -0,0 +1,200 @@
+package cache
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	contractcache "owl/contract/cache"
+	"sort"
+	"strings"
+)
+
+var TagsNotSupportedError = errors.New("this cache store does not support tagging")
+
+// TaggedCache 带标签的缓存仓库，通过 Repository.Tags 获取
+// 写入的 key 会记录到每个标签下，Flush 只删除带有这些标签的缓存
+//
+//	users, err := repository.Tags("users", "tenant:42")
+//	users.Put("user:1", user, time.Hour)
+type TaggedCache struct {
+	*Repository
+	names []string
+}
+
+// Tags 返回带标签的缓存仓库，存储没有实现 TagStore 时返回 TagsNotSupportedError
+func (i *Repository) Tags(names ...string) (*TaggedCache, error) {
+	store, ok := i.store.(TagStore)
+	if !ok {
+		return nil, TagsNotSupportedError
+	}
+
+	repository := NewRepository(newTaggedStore(i.store, store, names))
+	repository.name = i.name
+	repository.defaultTTL = i.defaultTTL
+	repository.events = i.events
+	repository.codec = i.codec
+
+	return &TaggedCache{
+		Repository: repository,
+		names:      names,
+	}, nil
+}
+
+// SupportsTags 存储是否支持标签
+func (i *Repository) SupportsTags() bool {
+	_, ok := i.store.(TagStore)
+	return ok
+}
+
+// GetTags 返回标签名称
+func (i *TaggedCache) GetTags() []string {
+	return i.names
+}
+
+// Flush 删除带有这些标签中任意一个的所有缓存
+func (i *TaggedCache) Flush() bool {
+	return i.store.Flush()
+}
+
+// Prune 从标签记录中删除已过期或已删除的 key，返回删除的记录数
+// 存储不会自动清理 redis 集合和标签文件中的记录，可以定时调用
+func (i *TaggedCache) Prune() int {
+	return i.store.(*taggedStore).prune()
+}
+
+// taggedStore 包装原有存储，给 key 加上标签组合的命名空间，并在写入时记录到标签下
+type taggedStore struct {
+	contractcache.Store
+	tags      TagStore
+	names     []string
+	namespace string
+}
+
+func newTaggedStore(store contractcache.Store, tags TagStore, names []string) *taggedStore {
+	sorted := append([]string(nil), names...)
+	sort.Strings(sorted)
+	sum := sha1.Sum([]byte(strings.Join(sorted, "|")))
+
+	return &taggedStore{
+		Store:     store,
+		tags:      tags,
+		names:     names,
+		namespace: hex.EncodeToString(sum[:]),
+	}
+}
+
+func (i *taggedStore) Get(key interface{}) interface{} {
+	if keys, ok := key.([]string); ok {
+		return i.Many(keys)
+	}
+	return i.Store.Get(i.itemKey(keyString(key)))
+}
+
+func (i *taggedStore) Many(keys []string) []interface{} {
+	itemKeys := make([]string, len(keys))
+	for n, key := range keys {
+		itemKeys[n] = i.itemKey(key)
+	}
+	return i.Store.Many(itemKeys)
+}
+
+func (i *taggedStore) Put(key string, value interface{}, seconds int) bool {
+	return i.record(i.Store.Put(i.itemKey(key), value, seconds), i.itemKey(key))
+}
+
+func (i *taggedStore) PutMany(values []interface{}, seconds int) bool {
+	items, ok := pairs(values)
+	if !ok {
+		return false
+	}
+
+	tagged := make([]interface{}, 0, len(values))
+	keys := make([]string, 0, len(items))
+	for key, value := range items {
+		keys = append(keys, i.itemKey(key))
+		tagged = append(tagged, i.itemKey(key), value)
+	}
+	return i.record(i.Store.PutMany(tagged, seconds), keys...)
+}
+
+func (i *taggedStore) Add(key string, value interface{}, seconds int) bool {
+	if store, ok := i.Store.(AddStore); ok {
+		return i.record(store.Add(i.itemKey(key), value, seconds), i.itemKey(key))
+	}
+	if i.Store.Get(i.itemKey(key)) != nil {
+		return false
+	}
+	return i.record(i.Store.Put(i.itemKey(key), value, seconds), i.itemKey(key))
+}
+
+func (i *taggedStore) Increment(key string, value int) (int, bool) {
+	n, ok := i.Store.Increment(i.itemKey(key), value)
+	return n, i.record(ok, i.itemKey(key))
+}
+
+func (i *taggedStore) Decrement(key string, value int) (int, bool) {
+	n, ok := i.Store.Decrement(i.itemKey(key), value)
+	return n, i.record(ok, i.itemKey(key))
+}
+
+func (i *taggedStore) Forever(key string, value interface{}) bool {
+	return i.record(i.Store.Forever(i.itemKey(key), value), i.itemKey(key))
+}
+
+func (i *taggedStore) Forget(key string) bool {
+	for _, name := range i.names {
+		i.tags.ForgetTagEntries(name, i.itemKey(key))
+	}
+	return i.Store.Forget(i.itemKey(key))
+}
+
+// Flush 删除每个标签下记录的 key，再删除标签记录
+func (i *taggedStore) Flush() bool {
+	for _, name := range i.names {
+		for _, key := range i.tags.TagEntries(name) {
+			i.Store.Forget(key)
+		}
+		if !i.tags.ForgetTag(name) {
+			return false
+		}
+	}
+	return true
+}
+
+// itemKey 同一个 key 在不同的标签组合下互不影响
+func (i *taggedStore) itemKey(key string) string {
+	return i.namespace + ":" + key
+}
+
+// tagEntriesKey 保存标签下 key 列表的缓存 key
+func tagEntriesKey(tag string) string {
+	return "tag:" + tag + ":entries"
+}
+
+// record 写入成功后把 keys 记录到每个标签下，返回 ok
+func (i *taggedStore) record(ok bool, keys ...string) bool {
+	if !ok {
+		return false
+	}
+	for _, name := range i.names {
+		i.tags.AddTagEntries(name, keys...)
+	}
+	return true
+}
+
+// prune 删除标签下已经不存在的 key 的记录
+func (i *taggedStore) prune() int {
+	pruned := 0
+	for _, name := range i.names {
+		var missing []string
+		for _, key := range i.tags.TagEntries(name) {
+			if i.Store.Get(key) == nil {
+				missing = append(missing, key)
+			}
+		}
+		if len(missing) > 0 && i.tags.ForgetTagEntries(name, missing...) {
+			pruned += len(missing)
+		}
+	}
+	return pruned
+}
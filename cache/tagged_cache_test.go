@@ -0,0 +1,116 @@
+package cache
+
+import (
+	"fmt"
+	contractcache "owl/contract/cache"
+	"testing"
+	"time"
+)
+
+func TestTaggedCacheFlush(t *testing.T) {
+	redisStore, _ := newTestRedisStore(t, "app:")
+	stores := map[string]contractcache.Store{
+		"array": NewArrayStore("", 0),
+		"file":  NewFileStore(t.TempDir(), ""),
+		"redis": redisStore,
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			repository := NewRepository(store)
+			repository.Put("plain", "kept", nil)
+			mustTags(t, repository, "users", "tenant:42").Put("user:1", "alice", 60)
+			mustTags(t, repository, "users").Put("user:2", "bob", 60)
+			mustTags(t, repository, "posts").Forever("post:1", "hello")
+
+			if got := mustTags(t, repository, "users", "tenant:42").Get("user:1", nil); got != "alice" {
+				t.Fatalf("tagged get = %v", got)
+			}
+			if repository.Has("user:1") {
+				t.Fatal("tagged key must not be visible without tags")
+			}
+
+			if !mustTags(t, repository, "tenant:42").Flush() {
+				t.Fatal("flush failed")
+			}
+			if mustTags(t, repository, "users", "tenant:42").Has("user:1") {
+				t.Fatal("user:1 should be flushed with tenant:42")
+			}
+			if got := mustTags(t, repository, "users").Get("user:2", nil); got != "bob" {
+				t.Fatalf("user:2 = %v, want bob", got)
+			}
+
+			mustTags(t, repository, "users").Flush()
+			if mustTags(t, repository, "users").Has("user:2") {
+				t.Fatal("user:2 should be flushed with users")
+			}
+			if got := mustTags(t, repository, "posts").Get("post:1", nil); got != "hello" {
+				t.Fatalf("post:1 = %v, want hello", got)
+			}
+			if got := repository.Get("plain", nil); got != "kept" {
+				t.Fatalf("plain = %v, want kept", got)
+			}
+		})
+	}
+}
+
+func TestTagsUnsupported(t *testing.T) {
+	repository := NewRepository(newTestDatabaseStore(t, ""))
+	if repository.SupportsTags() {
+		t.Fatal("database store does not support tags")
+	}
+	if tagged, err := repository.Tags("users"); tagged != nil || err != TagsNotSupportedError {
+		t.Fatalf("Tags() = %v, %v, want TagsNotSupportedError", tagged, err)
+	}
+}
+
+func TestArrayStoreEvictionForgetsTags(t *testing.T) {
+	store := NewArrayStore("", 2)
+	repository := NewRepository(store)
+	users := mustTags(t, repository, "users")
+
+	for n := 0; n < 100; n++ {
+		users.Put(fmt.Sprintf("user:%d", n), n, 60)
+	}
+	if entries := store.TagEntries("users"); len(entries) != 2 {
+		t.Fatalf("tag entries = %d, want 2", len(entries))
+	}
+	if len(store.keyTags) != 2 {
+		t.Fatalf("key tags = %d, want 2", len(store.keyTags))
+	}
+
+	users.Forget("user:99")
+	users.Forget("user:98")
+	if len(store.tags) != 0 || len(store.keyTags) != 0 {
+		t.Fatalf("tags should be empty after forgetting every key: %v %v", store.tags, store.keyTags)
+	}
+}
+
+func TestTaggedCachePrune(t *testing.T) {
+	store, server := newTestRedisStore(t, "app:")
+	users := mustTags(t, NewRepository(store), "users")
+
+	users.Put("user:1", "alice", 60)
+	users.Put("user:2", "bob", 60)
+	users.Forget("user:2")
+	if entries := store.TagEntries("users"); len(entries) != 1 {
+		t.Fatalf("Forget() should remove the tag entry: %v", entries)
+	}
+
+	server.FastForward(61 * time.Second)
+	if n := users.Prune(); n != 1 {
+		t.Fatalf("Prune() = %d, want 1", n)
+	}
+	if entries := store.TagEntries("users"); len(entries) != 0 {
+		t.Fatalf("tag entries after Prune() = %v", entries)
+	}
+}
+
+func mustTags(t *testing.T, repository *Repository, names ...string) *TaggedCache {
+	t.Helper()
+	tagged, err := repository.Tags(names...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tagged
+}
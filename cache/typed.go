@@ -0,0 +1,124 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	contractcache "owl/contract/cache"
+)
+
+var NotStoredError = errors.New("cache value not stored")
+
+// 泛型缓存方法，值经过仓库的 Codec 编码后保存，读取时解码为 T，不需要调用方做类型断言
+//
+//	user, err := cache.Remember[User](repository, "user:1", time.Minute, func() (User, error) {
+//		return findUser(1)
+//	})
+
+// Get 读取并解码为 T，第二个返回值表示是否命中
+func Get[T any](repository contractcache.Repository, key string) (T, bool, error) {
+	var zero T
+	value := repository.Get(key, nil)
+	if value == nil {
+		return zero, false, nil
+	}
+
+	result, err := decode[T](codecOf(repository), value)
+	if err != nil {
+		return zero, false, err
+	}
+	return result, true, nil
+}
+
+// Put 编码后写入，ttl 与 Repository.Put 相同
+func Put[T any](repository contractcache.Repository, key string, value T, ttl any) error {
+	data, err := codecOf(repository).Marshal(value)
+	if err != nil {
+		return err
+	}
+	if !repository.Put(key, data, ttl) {
+		return NotStoredError
+	}
+	return nil
+}
+
+// Pull 读取并删除
+func Pull[T any](repository contractcache.Repository, key string) (T, bool, error) {
+	result, ok, err := Get[T](repository, key)
+	if ok {
+		repository.Forget(key)
+	}
+	return result, ok, err
+}
+
+// Remember 缓存不存在时执行 callback 并写入，callback 返回错误时不写入缓存
+// repository 为 *Repository 时并发未命中只会执行一次 callback
+func Remember[T any](repository contractcache.Repository, key string, ttl any, callback func() (T, error)) (T, error) {
+	var zero T
+	codec := codecOf(repository)
+
+	encode := func() (any, error) {
+		value, err := callback()
+		if err != nil {
+			return nil, err
+		}
+		return codec.Marshal(value)
+	}
+
+	var value any
+	var err error
+	if r, ok := repository.(*Repository); ok {
+		value, err = r.remember(key, ttl, encode)
+	} else if value = repository.Get(key, nil); value == nil {
+		if value, err = encode(); err == nil {
+			repository.Put(key, value, ttl)
+		}
+	}
+	if err != nil {
+		return zero, err
+	}
+	return decode[T](codec, value)
+}
+
+// Many 读取多个 key，只返回命中的值
+func Many[T any](repository contractcache.Repository, keys []string) (map[string]T, error) {
+	codec := codecOf(repository)
+	values := repository.Many(keys)
+
+	result := make(map[string]T, len(keys))
+	for n, value := range values {
+		if value == nil {
+			continue
+		}
+		decoded, err := decode[T](codec, value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", keys[n], err)
+		}
+		result[keys[n]] = decoded
+	}
+	return result, nil
+}
+
+// codecOf 返回仓库使用的编解码方式
+func codecOf(repository contractcache.Repository) Codec {
+	if r, ok := repository.(interface{ Codec() Codec }); ok {
+		return r.Codec()
+	}
+	return DefaultCodec
+}
+
+func decode[T any](codec Codec, value any) (T, error) {
+	var result T
+
+	var data []byte
+	switch v := value.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return result, fmt.Errorf("缓存的值不是编码后的数据 %T", value)
+	}
+
+	err := codec.Unmarshal(data, &result)
+	return result, err
+}
@@ -0,0 +1,71 @@
+package cache
+
+import (
+	"errors"
+	contractcache "owl/contract/cache"
+	"testing"
+	"time"
+)
+
+type typedTestUser struct {
+	Name  string
+	Tags  []string
+	Score float64
+}
+
+func TestTypedRoundTrip(t *testing.T) {
+	redisStore, _ := newTestRedisStore(t, "")
+	stores := map[string]contractcache.Store{
+		"array": NewArrayStore("", 0),
+		"file":  NewFileStore(t.TempDir(), ""),
+		"redis": redisStore,
+	}
+	user := typedTestUser{Name: "owl", Tags: []string{"a", "b"}, Score: 1.5}
+
+	for storeName, store := range stores {
+		for _, codecName := range []string{CodecJSON, CodecGob, CodecMsgpack} {
+			repository := NewRepository(store)
+			codec, _ := NewCodec(codecName)
+			repository.SetCodec(codec)
+			key := "user:" + codecName
+
+			if err := Put(repository, key, user, time.Minute); err != nil {
+				t.Fatalf("%s/%s Put() error = %v", storeName, codecName, err)
+			}
+			got, ok, err := Get[typedTestUser](repository, key)
+			if err != nil || !ok || got.Name != user.Name || len(got.Tags) != 2 || got.Score != user.Score {
+				t.Fatalf("%s/%s Get() = %+v, %v, %v", storeName, codecName, got, ok, err)
+			}
+
+			values, err := Many[typedTestUser](repository, []string{key, "missing"})
+			if err != nil || len(values) != 1 || values[key].Name != user.Name {
+				t.Fatalf("%s/%s Many() = %v, %v", storeName, codecName, values, err)
+			}
+		}
+	}
+}
+
+func TestTypedRemember(t *testing.T) {
+	repository := NewRepository(NewArrayStore("", 0))
+
+	failed := errors.New("load failed")
+	if _, err := Remember(repository, "n", time.Minute, func() (int, error) { return 0, failed }); !errors.Is(err, failed) {
+		t.Fatalf("Remember() error = %v", err)
+	}
+	if repository.Has("n") {
+		t.Fatal("Remember() should not store when callback fails")
+	}
+
+	n, err := Remember(repository, "n", time.Minute, func() (int, error) { return 42, nil })
+	if err != nil || n != 42 {
+		t.Fatalf("Remember() = %d, %v", n, err)
+	}
+	n, err = Remember(repository, "n", time.Minute, func() (int, error) { return 7, nil })
+	if err != nil || n != 42 {
+		t.Fatalf("Remember() on cached key = %d, %v", n, err)
+	}
+
+	if _, ok, _ := Pull[int](repository, "n"); !ok || repository.Has("n") {
+		t.Fatal("Pull() should read and forget the key")
+	}
+}
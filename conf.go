@@ -11,25 +11,35 @@ import (
 	"owl/log"
 	"path/filepath"
 	"strings"
+	"sync"
 )
 
 type ConfManager struct {
-	confDir      string
-	changeNotify map[string]chan string
-	allCfg       map[string]map[string]any // 存储所有的配置
+	confDir    string
+	allCfg     map[string]map[string]any // 存储所有的配置
+	lock       sync.RWMutex              // 保护 allCfg，配置文件修改后会重新读取
+	done       <-chan struct{}           // 应用停止时关闭，之后不再处理配置修改
+	env        string                    // 当前环境，来自 APP_ENV 或 SetEnvironment，使用 lock 保护
+	marshalled []byte                    // allCfg 序列化后的缓存，配置重新读取后清空
+
+	reloadLock  sync.Mutex // 保证重新读取和通知按修改的顺序执行，同时保护 viper 的读写
+	watchLock   sync.Mutex
+	watchers    map[int]*configWatcher
+	nextWatcher int
 }
 
-var (
-	CfgChangeNotify = make(map[string]chan string, 10) // 配置修改时通知
-)
-
 func NewConfigManager(stage *Stage) *ConfManager {
-	confDir := stage.ConfigPath()
+	return NewConfigManagerFromDir(stage.ConfigPath(), stage.Lifecycle().Done())
+}
 
+// NewConfigManagerFromDir 读取 confDir 中的所有配置文件，done 关闭后不再处理配置修改，可以为 nil
+func NewConfigManagerFromDir(confDir string, done <-chan struct{}) *ConfManager {
 	manager := ConfManager{
-		changeNotify: nil,
-		allCfg:       make(map[string]map[string]any),
-		confDir:      confDir,
+		allCfg:   make(map[string]map[string]any),
+		confDir:  confDir,
+		done:     done,
+		env:      os.Getenv(AppEnvKey),
+		watchers: make(map[int]*configWatcher),
 	}
 
 	err := filepath.Walk(confDir, func(path string, info fs.FileInfo, err error) error {
@@ -39,7 +49,17 @@ func NewConfigManager(stage *Stage) *ConfManager {
 		cfgMap := make(map[string]any)
 		ext := strings.Replace(filepath.Ext(info.Name()), ".", "", -1)
 		name := strings.Replace(info.Name(), "."+ext, "", -1)
-		absPath, v := manager.LoadConfig(name, ext, &cfgMap)
+		if manager.isOverlayFile(name) {
+			return nil
+		}
+		absPath, v, err := manager.LoadConfig(name, ext, &cfgMap)
+		if err != nil {
+			return err
+		}
+		if err := manager.applyLayers(name, cfgMap); err != nil {
+			panic(err)
+		}
+		manager.watchOverlay(name)
 		cfgMap["abs-path"] = absPath
 		cfgMap["vip"] = v
 		manager.allCfg[name] = cfgMap
@@ -51,10 +71,15 @@ func NewConfigManager(stage *Stage) *ConfManager {
 	return &manager
 }
 
+// Done 应用停止时关闭
+func (i *ConfManager) Done() <-chan struct{} {
+	return i.done
+}
+
 func (i *ConfManager) GetConfig(key string, v any) error {
 	var getter jsoniter.Any
 
-	marshal, err := jsoniter.Marshal(i.allCfg)
+	marshal, err := i.marshalAll()
 	if err != nil {
 		return err
 	}
@@ -75,8 +100,39 @@ func (i *ConfManager) GetConfig(key string, v any) error {
 	return err
 }
 
+// marshalAll 序列化所有配置，结果缓存到下一次重新读取配置
+func (i *ConfManager) marshalAll() ([]byte, error) {
+	i.lock.RLock()
+	marshal := i.marshalled
+	i.lock.RUnlock()
+	if marshal != nil {
+		return marshal, nil
+	}
+
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	if i.marshalled == nil {
+		all := make(map[string]map[string]any, len(i.allCfg))
+		for name, cfg := range i.allCfg {
+			all[name] = withoutInternalKeys(cfg)
+		}
+		marshal, err := jsoniter.Marshal(all)
+		if err != nil {
+			return nil, err
+		}
+		i.marshalled = marshal
+	}
+	return i.marshalled, nil
+}
+
+// SaveConfig 修改配置并写入文件，写入后文件监听会重新读取配置
 func (i *ConfManager) SaveConfig(fileName string, key string, value any) {
+	i.reloadLock.Lock()
+	defer i.reloadLock.Unlock()
+
+	i.lock.RLock()
 	cfg, ok := i.allCfg[fileName]
+	i.lock.RUnlock()
 	if ok {
 		v := cfg["vip"].(*viper.Viper)
 		v.Set(key, value)
@@ -87,8 +143,46 @@ func (i *ConfManager) SaveConfig(fileName string, key string, value any) {
 	}
 }
 
-// LoadConfig 读取文件中的配置
-func (i *ConfManager) LoadConfig(fileName, cfgType string, c any) (string, *viper.Viper) {
+// ReloadConfig 重新读取配置文件，并重新合并环境配置文件和环境变量，fileName 为不带扩展名的文件名
+// 配置文件修改后自动调用，之后 GetConfig 返回新的配置，值有变化的 Watch 会收到通知
+// 读取文件时不持有 lock，不会阻塞 GetConfig
+func (i *ConfManager) ReloadConfig(fileName string) error {
+	i.reloadLock.Lock()
+	defer i.reloadLock.Unlock()
+
+	i.lock.RLock()
+	cfg, ok := i.allCfg[fileName]
+	i.lock.RUnlock()
+	if !ok {
+		return fmt.Errorf("配置文件 [%s] 不存在", fileName)
+	}
+	v := cfg["vip"].(*viper.Viper)
+
+	cfgMap := make(map[string]any)
+	if err := v.ReadInConfig(); err != nil {
+		return err
+	}
+	if err := v.Unmarshal(&cfgMap); err != nil {
+		return err
+	}
+	if err := i.applyLayers(fileName, cfgMap); err != nil {
+		return err
+	}
+	cfgMap["abs-path"] = cfg["abs-path"]
+	cfgMap["vip"] = v
+
+	// reloadLock 保证读取之后 allCfg[fileName] 没有被其他重新读取修改
+	i.lock.Lock()
+	i.allCfg[fileName] = cfgMap
+	i.marshalled = nil
+	i.lock.Unlock()
+
+	i.notify(fileName, cfg, cfgMap)
+	return nil
+}
+
+// LoadConfig 读取文件中的配置，文件无法读取或格式错误时返回错误
+func (i *ConfManager) LoadConfig(fileName, cfgType string, c any) (string, *viper.Viper, error) {
 
 	confFilePath := fmt.Sprintf("%s/%s.%s", i.confDir, fileName, cfgType)
 
@@ -98,26 +192,34 @@ func (i *ConfManager) LoadConfig(fileName, cfgType string, c any) (string, *vipe
 	log.PrintLnBlue("配置文件: ", confFilePath)
 	cfg, err := os.ReadFile(confFilePath)
 	if err != nil {
-		log.PrintRed("")
-		os.Exit(200)
+		return "", nil, fmt.Errorf("读取配置文件失败 %s: %w", confFilePath, err)
 	}
 	v.AddConfigPath(confFilePath)
 	if err = v.ReadConfig(bytes.NewReader(cfg)); err != nil {
-		panic(fmt.Sprint("读取配置文件失败", err))
+		return "", nil, fmt.Errorf("读取配置文件失败 %s: %w", confFilePath, err)
 	}
 
 	// 转换为结构体
 	if err := v.Unmarshal(&c); err != nil {
-		panic("转为配置结构体失败")
+		return "", nil, fmt.Errorf("转为配置结构体失败 %s: %w", confFilePath, err)
 	}
 
-	// Watch for changes in the config file
+	// 配置文件修改后 viper 重新读取，然后更新 allCfg 并通知 Watch
 	v.WatchConfig()
-	ch := make(chan string, 10)
-	CfgChangeNotify[confFilePath] = ch
-	// Register a callback function to handle the changes
 	v.OnConfigChange(func(e fsnotify.Event) {
-		CfgChangeNotify[confFilePath] <- confFilePath
+		i.onConfigChange(fileName, confFilePath)
 	})
-	return confFilePath, v
+	return confFilePath, v, nil
+}
+
+// onConfigChange 配置文件或环境配置文件修改后重新读取 fileName，应用停止后不再处理
+func (i *ConfManager) onConfigChange(fileName, changedFile string) {
+	select {
+	case <-i.done:
+		return
+	default:
+	}
+	if err := i.ReloadConfig(fileName); err != nil {
+		log.PrintLnRed("重新读取配置文件失败 ", changedFile, err)
+	}
 }
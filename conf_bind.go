@@ -0,0 +1,448 @@
+package owl
+
+import (
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// InvalidConfig 一个不合法的配置项
+type InvalidConfig struct {
+	File    string // 配置文件路径
+	Key     string // 完整的配置 key，例如 db.port
+	Message string
+}
+
+// ConfigErrors 绑定配置时所有不合法的配置项
+type ConfigErrors []InvalidConfig
+
+func (i ConfigErrors) Error() string {
+	lines := make([]string, 0, len(i)+1)
+	lines = append(lines, fmt.Sprintf("%d 个配置项不合法:", len(i)))
+	for _, e := range i {
+		lines = append(lines, fmt.Sprintf("  %s: %s %s", e.File, e.Key, e.Message))
+	}
+	return strings.Join(lines, "\n")
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// BindConfig 返回读取 key 对应配置的构造函数，可以直接放入容器
+//
+//	stage.Provide(owl.BindConfig[database.Options]("db"))
+func BindConfig[T any](key string) func(cfgManager *ConfManager) (*T, error) {
+	return func(cfgManager *ConfManager) (*T, error) {
+		return DecodeConfig[T](cfgManager, key)
+	}
+}
+
+// DecodeConfig 把 key 对应的配置解析到 T，配置中没有的字段使用 default 标签的值，
+// 然后按 validate 标签校验，返回的 ConfigErrors 包含所有不合法的配置项
+//
+// time.Duration 字段的配置可以写成 5s 1m30s 这样的字符串，类型不匹配的字段也会汇总到 ConfigErrors 中
+//
+// validate 支持 required min=n max=n oneof=a b c url duration，多个规则用逗号分隔，
+// min max 对数字比较大小，对字符串、数组比较长度，oneof url duration 允许空值
+func DecodeConfig[T any](cfgManager *ConfManager, key string) (*T, error) {
+	return decodeConfig[T](cfgManager, key)
+}
+
+// decodeConfig 按顺序把 keys 对应的配置解析到同一个 T，后面的配置覆盖前面的，
+// 校验错误使用第一个 key 作为前缀
+func decodeConfig[T any](cfgManager *ConfManager, keys ...string) (*T, error) {
+	v := new(T)
+	rv := reflect.ValueOf(v).Elem()
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("BindConfig 只支持结构体，%s 是 %s", keys[0], rv.Type())
+	}
+
+	if err := applyDefaults(rv); err != nil {
+		return nil, err
+	}
+
+	var errs ConfigErrors
+	_, file := cfgManager.lookup(keys[0])
+	for _, key := range keys {
+		if raw, rawFile := cfgManager.lookup(key); raw != nil {
+			decodeValue(rv, raw, key, rawFile, &errs)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	validateConfig(rv, keys[0], file, &errs)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return v, nil
+}
+
+// lookup 获取 key 对应的原始配置和所在的配置文件，不存在时返回 nil
+func (i *ConfManager) lookup(key string) (any, string) {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+
+	parts := strings.Split(key, ".")
+	cfg, ok := i.allCfg[parts[0]]
+	if !ok {
+		return nil, filepath.Join(i.confDir, parts[0])
+	}
+	file, _ := cfg["abs-path"].(string)
+
+	value, _ := configValue(withoutInternalKeys(cfg), parts[1:])
+	return value, file
+}
+
+// withoutInternalKeys 去掉 ConfManager 保存在配置中的 vip
+func withoutInternalKeys(cfg map[string]any) map[string]any {
+	m := make(map[string]any, len(cfg))
+	for k, v := range cfg {
+		if k != "vip" {
+			m[k] = v
+		}
+	}
+	return m
+}
+
+// decodeStruct 按 json 标签把 raw 中的配置解析到结构体字段，raw 中没有的字段保留默认值
+func decodeStruct(v reflect.Value, raw map[string]any, key, file string, errs *ConfigErrors) {
+	t := v.Type()
+	for n := 0; n < t.NumField(); n++ {
+		field := t.Field(n)
+		if !field.IsExported() || field.Tag.Get("json") == "-" {
+			continue
+		}
+		fv := v.Field(n)
+
+		// 嵌入的结构体和 json 一样，字段和外层在同一级
+		if field.Anonymous && field.Tag.Get("json") == "" {
+			if fv.Kind() == reflect.Pointer && fv.Type().Elem().Kind() == reflect.Struct {
+				if fv.IsNil() {
+					fv.Set(reflect.New(fv.Type().Elem()))
+				}
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct {
+				decodeStruct(fv, raw, key, file, errs)
+				continue
+			}
+		}
+
+		name := configFieldName(field)
+		value, ok := raw[name]
+		if !ok {
+			// viper 读取后 key 为小写，和 jsoniter 一样不区分大小写
+			for k, item := range raw {
+				if strings.EqualFold(k, name) {
+					value, ok = item, true
+					break
+				}
+			}
+		}
+		if ok && value != nil {
+			decodeValue(fv, value, key+"."+name, file, errs)
+		}
+	}
+}
+
+// decodeValue 解析一个配置值，时长字符串使用 time.ParseDuration，结构体和数组逐项解析，
+// 其他类型使用 jsoniter，失败时记录到 errs 并继续解析其他字段
+func decodeValue(v reflect.Value, raw any, key, file string, errs *ConfigErrors) {
+	if v.Type() == durationType {
+		if s, ok := raw.(string); ok {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				*errs = append(*errs, InvalidConfig{File: file, Key: key, Message: fmt.Sprintf("不是合法的时长，例如 1s 5m 1h: %s", s)})
+				return
+			}
+			v.SetInt(int64(d))
+			return
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		decodeValue(v.Elem(), raw, key, file, errs)
+		return
+	case reflect.Struct:
+		if m, ok := raw.(map[string]any); ok && v.Type() != durationType {
+			decodeStruct(v, m, key, file, errs)
+			return
+		}
+	case reflect.Slice:
+		if items, ok := raw.([]any); ok {
+			s := reflect.MakeSlice(v.Type(), len(items), len(items))
+			for n, item := range items {
+				if item != nil {
+					decodeValue(s.Index(n), item, fmt.Sprintf("%s.%d", key, n), file, errs)
+				}
+			}
+			v.Set(s)
+			return
+		}
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		// 环境变量覆盖的值是字符串，按字段类型转换
+		if s, ok := raw.(string); ok {
+			if err := setDefault(v, s); err != nil {
+				*errs = append(*errs, InvalidConfig{File: file, Key: key, Message: fmt.Sprintf("类型错误，需要 %s，当前为 %v", v.Type(), raw)})
+			}
+			return
+		}
+	}
+
+	content, err := jsoniter.Marshal(raw)
+	if err == nil {
+		err = jsoniter.Unmarshal(content, v.Addr().Interface())
+	}
+	if err != nil {
+		*errs = append(*errs, InvalidConfig{File: file, Key: key, Message: fmt.Sprintf("类型错误，需要 %s，当前为 %v", v.Type(), raw)})
+	}
+}
+
+// applyDefaults 按 default 标签设置字段，嵌入的结构体指针会被创建
+func applyDefaults(v reflect.Value) error {
+	t := v.Type()
+	for n := 0; n < t.NumField(); n++ {
+		field := t.Field(n)
+		if !field.IsExported() {
+			continue
+		}
+		fv := v.Field(n)
+
+		if field.Anonymous && fv.Kind() == reflect.Pointer && fv.Type().Elem().Kind() == reflect.Struct {
+			if fv.IsNil() {
+				fv.Set(reflect.New(fv.Type().Elem()))
+			}
+			fv = fv.Elem()
+		}
+		if fv.Kind() == reflect.Struct && fv.Type() != durationType {
+			if err := applyDefaults(fv); err != nil {
+				return err
+			}
+			continue
+		}
+
+		def, ok := field.Tag.Lookup("default")
+		if !ok {
+			continue
+		}
+		if err := setDefault(fv, def); err != nil {
+			return fmt.Errorf("字段 %s 的默认值 %q 不合法: %w", field.Name, def, err)
+		}
+	}
+	return nil
+}
+
+func setDefault(v reflect.Value, def string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(def)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(def)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(def)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(def, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(def, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(def, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("不支持的类型 %s", v.Type())
+		}
+		parts := strings.Split(def, ",")
+		s := reflect.MakeSlice(v.Type(), len(parts), len(parts))
+		for n, part := range parts {
+			s.Index(n).SetString(strings.TrimSpace(part))
+		}
+		v.Set(s)
+	default:
+		return fmt.Errorf("不支持的类型 %s", v.Type())
+	}
+	return nil
+}
+
+// validateConfig 按 validate 标签校验，key 为结构体对应的配置 key
+func validateConfig(v reflect.Value, key, file string, errs *ConfigErrors) {
+	t := v.Type()
+	for n := 0; n < t.NumField(); n++ {
+		field := t.Field(n)
+		if !field.IsExported() {
+			continue
+		}
+		fv := v.Field(n)
+
+		fieldKey := key
+		if !field.Anonymous {
+			fieldKey = key + "." + configFieldName(field)
+		}
+
+		for _, rule := range splitRules(field.Tag.Get("validate")) {
+			if msg := checkRule(fv, rule); msg != "" {
+				*errs = append(*errs, InvalidConfig{File: file, Key: fieldKey, Message: msg})
+			}
+		}
+
+		validateNested(fv, fieldKey, file, errs)
+	}
+}
+
+func validateNested(v reflect.Value, key, file string, errs *ConfigErrors) {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		if v.Type() != durationType {
+			validateConfig(v, key, file, errs)
+		}
+	case reflect.Slice, reflect.Array:
+		for n := 0; n < v.Len(); n++ {
+			validateNested(v.Index(n), fmt.Sprintf("%s.%d", key, n), file, errs)
+		}
+	}
+}
+
+// configFieldName 使用 json 标签中的名称
+func configFieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
+
+func splitRules(tag string) []string {
+	var rules []string
+	for _, rule := range strings.Split(tag, ",") {
+		if rule = strings.TrimSpace(rule); rule != "" {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// checkRule 返回不满足规则时的错误信息，满足时返回空
+func checkRule(v reflect.Value, rule string) string {
+	name, arg, _ := strings.Cut(rule, "=")
+
+	if name == "required" {
+		if v.IsZero() {
+			return "不能为空"
+		}
+		return ""
+	}
+
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+
+	// oneof url duration 不校验空值，需要时和 required 一起使用
+	if name != "min" && name != "max" && v.IsZero() {
+		return ""
+	}
+
+	switch name {
+	case "min", "max":
+		limit, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return fmt.Sprintf("规则 %s 不合法", rule)
+		}
+		size, unit, ok := configSize(v)
+		if !ok {
+			return fmt.Sprintf("规则 %s 不支持类型 %s", rule, v.Type())
+		}
+		if name == "min" && size < limit {
+			return fmt.Sprintf("不能小于 %s%s，当前为 %v", arg, unit, size)
+		}
+		if name == "max" && size > limit {
+			return fmt.Sprintf("不能大于 %s%s，当前为 %v", arg, unit, size)
+		}
+	case "oneof":
+		value := fmt.Sprint(v.Interface())
+		options := strings.Fields(arg)
+		for _, option := range options {
+			if option == value {
+				return ""
+			}
+		}
+		return fmt.Sprintf("必须是 %s 之一，当前为 %s", strings.Join(options, " "), value)
+	case "url":
+		u, err := url.Parse(fmt.Sprint(v.Interface()))
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Sprintf("不是合法的 URL: %v", v.Interface())
+		}
+	case "duration":
+		// time.Duration 字段在解析时已经校验过格式，这里只检查不能为负数
+		if v.Type() == durationType {
+			if v.Int() < 0 {
+				return fmt.Sprintf("不能为负数，当前为 %s", time.Duration(v.Int()))
+			}
+			return ""
+		}
+		if _, err := time.ParseDuration(fmt.Sprint(v.Interface())); err != nil {
+			return fmt.Sprintf("不是合法的时长，例如 1s 5m 1h: %v", v.Interface())
+		}
+	default:
+		return fmt.Sprintf("未知的校验规则 %s", name)
+	}
+	return ""
+}
+
+// configSize 数字返回数值，字符串返回字符数，数组和 map 返回长度
+func configSize(v reflect.Value) (float64, string, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), "", true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), "", true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), "", true
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), " 个字符", true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), " 项", true
+	}
+	return 0, "", false
+}
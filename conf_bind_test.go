@@ -0,0 +1,131 @@
+package owl
+
+import (
+	"errors"
+	"owl/contract"
+	"strings"
+	"testing"
+	"time"
+)
+
+type bindOptions struct {
+	*contract.ServerConfig
+	Driver   string        `json:"driver" default:"mysql" validate:"oneof=mysql pgsql sqlite"`
+	Endpoint string        `json:"endpoint" validate:"url"`
+	Window   string        `json:"window" default:"1m" validate:"duration"`
+	Timeout  time.Duration `json:"timeout" default:"3s"`
+	Tags     []string      `json:"tags" default:"a, b" validate:"max=3"`
+	Pool     struct {
+		Size int `json:"size" default:"4" validate:"min=1"`
+	} `json:"pool"`
+}
+
+func TestDecodeConfigDefaults(t *testing.T) {
+	cfg := newTestConfManager(t, map[string]string{
+		"db.yml": "host: 127.0.0.1\nport: 3306\nendpoint: https://example.com/api\npool:\n  size: 8\n",
+	})
+
+	opt, err := BindConfig[bindOptions]("db")(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opt.Host != "127.0.0.1" || opt.Port != 3306 || opt.Driver != "mysql" || opt.Window != "1m" {
+		t.Fatalf("unexpected options %+v %+v", opt, opt.ServerConfig)
+	}
+	if opt.Timeout != 3*time.Second || len(opt.Tags) != 2 || opt.Tags[1] != "b" || opt.Pool.Size != 8 {
+		t.Fatalf("unexpected options %+v", opt)
+	}
+	if !strings.HasSuffix(opt.AbsPath, "db.yml") {
+		t.Fatalf("abs-path = %s", opt.AbsPath)
+	}
+
+	pool, err := DecodeConfig[struct {
+		Size int `json:"size"`
+	}](cfg, "db.pool")
+	if err != nil || pool.Size != 8 {
+		t.Fatalf("nested key: %+v %v", pool, err)
+	}
+}
+
+func TestDecodeConfigAggregatesErrors(t *testing.T) {
+	cfg := newTestConfManager(t, map[string]string{
+		"db.yml": "port: 70000\ndriver: oracle\nendpoint: not-a-url\nwindow: soon\ntags: [a, b, c, d]\npool:\n  size: 0\n",
+	})
+
+	_, err := DecodeConfig[bindOptions](cfg, "db")
+	var errs ConfigErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("error = %v", err)
+	}
+
+	keys := make(map[string]bool)
+	for _, e := range errs {
+		keys[e.Key] = true
+		if !strings.HasSuffix(e.File, "db.yml") {
+			t.Fatalf("file = %s", e.File)
+		}
+	}
+	for _, key := range []string{"db.host", "db.port", "db.driver", "db.endpoint", "db.window", "db.tags"} {
+		if !keys[key] {
+			t.Fatalf("missing %s in %v", key, err)
+		}
+	}
+	if !keys["db.pool.size"] {
+		t.Fatalf("explicit zero should fail min: %v", err)
+	}
+	if !strings.Contains(err.Error(), "db.port") {
+		t.Fatalf("message should list keys: %s", err)
+	}
+}
+
+func TestDecodeConfigMissingFile(t *testing.T) {
+	cfg := newTestConfManager(t, nil)
+
+	_, err := DecodeConfig[bindOptions](cfg, "db")
+	var errs ConfigErrors
+	if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Key != "db.host" {
+		t.Fatalf("error = %v", err)
+	}
+	if _, err = DecodeConfig[string](cfg, "db"); err == nil {
+		t.Fatal("non struct types should be rejected")
+	}
+}
+
+func TestDecodeConfigDurationFromYAML(t *testing.T) {
+	cfg := newTestConfManager(t, map[string]string{
+		"db.yml":    "host: 127.0.0.1\ntimeout: 1m30s\npool:\n  size: 2\n",
+		"queue.yml": "timeout: soon\nretry: -1s\nport: abc\npool:\n  size: many\n",
+	})
+
+	opt, err := DecodeConfig[bindOptions](cfg, "db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opt.Timeout != 90*time.Second {
+		t.Fatalf("timeout = %s, want 1m30s", opt.Timeout)
+	}
+
+	type queueOptions struct {
+		*contract.ServerConfig
+		Timeout time.Duration `json:"timeout"`
+		Retry   time.Duration `json:"retry" validate:"duration"`
+		Pool    struct {
+			Size int `json:"size"`
+		} `json:"pool"`
+	}
+	_, err = DecodeConfig[queueOptions](cfg, "queue")
+	var errs ConfigErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("error = %v", err)
+	}
+	keys := make([]string, 0, len(errs))
+	for _, e := range errs {
+		keys = append(keys, e.Key)
+	}
+	// 类型错误不会在第一个字段就停止
+	for _, key := range []string{"queue.timeout", "queue.port", "queue.pool.size"} {
+		if !strings.Contains(strings.Join(keys, " "), key) {
+			t.Fatalf("errors %v should contain %s", keys, key)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package owl
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/fsnotify/fsnotify"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/spf13/viper"
+	"os"
+	"owl/log"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	AppEnvKey = "APP_ENV" // 选择环境配置，例如 production 时 conf/app.production.yml 覆盖 conf/app.yml
+	EnvPrefix = "OWL_"    // 环境变量覆盖配置，OWL_DB__HOST 对应 db.host，OWL_DB__MAX_IDLE_CONNS 对应 db.max-idle-conns
+)
+
+// Environment 当前环境，为空时不加载环境配置文件
+func (i *ConfManager) Environment() string {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	return i.env
+}
+
+// SetEnvironment 切换环境，重新读取所有配置文件，值有变化的 Watch 会收到通知
+// 命令行的 --env 参数通过它作用于已经创建的 ConfManager
+func (i *ConfManager) SetEnvironment(env string) error {
+	i.lock.Lock()
+	changed := i.env != env
+	i.env = env
+	i.lock.Unlock()
+	if !changed {
+		return nil
+	}
+
+	var errs []error
+	for _, file := range i.Files() {
+		if err := i.ReloadConfig(file[0]); err != nil {
+			errs = append(errs, err)
+		}
+		i.watchOverlay(file[0])
+	}
+	return errors.Join(errs...)
+}
+
+// applyLayers 依次把环境配置文件和 OWL_ 环境变量合并到 cfgMap
+func (i *ConfManager) applyLayers(name string, cfgMap map[string]any) error {
+	if overlay := i.overlayFile(name); overlay != "" {
+		content, err := os.ReadFile(overlay)
+		if err != nil {
+			return err
+		}
+		v := viper.New()
+		v.SetConfigType(strings.TrimPrefix(filepath.Ext(overlay), "."))
+		if err = v.ReadConfig(bytes.NewReader(content)); err != nil {
+			return fmt.Errorf("读取环境配置文件 %s 失败: %w", overlay, err)
+		}
+		overlayMap := make(map[string]any)
+		if err = v.Unmarshal(&overlayMap); err != nil {
+			return err
+		}
+		log.PrintLnBlue("环境配置文件: ", overlay)
+		mergeConfig(cfgMap, overlayMap)
+	}
+
+	for _, override := range envOverrides(name, os.Environ()) {
+		setConfigPath(cfgMap, override.path, override.value)
+	}
+	return nil
+}
+
+// overlayFile 查找 conf/{name}.{env}.* 文件，没有设置环境或文件不存在时返回空
+func (i *ConfManager) overlayFile(name string) string {
+	env := i.Environment()
+	if env == "" {
+		return ""
+	}
+	matches, _ := filepath.Glob(filepath.Join(i.confDir, name+"."+env+".*"))
+	sort.Strings(matches)
+	for _, match := range matches {
+		ext := filepath.Ext(match)
+		if filepath.Base(match) == name+"."+env+ext {
+			return match
+		}
+	}
+	return ""
+}
+
+// isOverlayFile 存在同名的基础配置文件时，{name}.{env} 只作为覆盖配置，不单独加载，
+// 例如有 app.yml 时 app.production.yml 是覆盖配置，没有 foo.yml 时 foo.bar.yml 按普通配置加载
+func (i *ConfManager) isOverlayFile(name string) bool {
+	index := strings.LastIndex(name, ".")
+	if index <= 0 {
+		return false
+	}
+	base := name[:index]
+	matches, _ := filepath.Glob(filepath.Join(i.confDir, base+".*"))
+	for _, match := range matches {
+		file := filepath.Base(match)
+		if strings.TrimSuffix(file, filepath.Ext(file)) == base {
+			return true
+		}
+	}
+	return false
+}
+
+// watchOverlay 环境配置文件修改后重新读取 name 对应的配置
+func (i *ConfManager) watchOverlay(name string) {
+	overlay := i.overlayFile(name)
+	if overlay == "" {
+		return
+	}
+	v := viper.New()
+	v.SetConfigFile(overlay)
+	v.WatchConfig()
+	v.OnConfigChange(func(e fsnotify.Event) {
+		i.onConfigChange(name, overlay)
+	})
+}
+
+// mergeConfig 把 src 深度合并到 dst，同名的非 map 值以 src 为准
+func mergeConfig(dst, src map[string]any) {
+	for k, v := range src {
+		srcMap, srcOk := v.(map[string]any)
+		dstMap, dstOk := dst[k].(map[string]any)
+		if srcOk && dstOk {
+			mergeConfig(dstMap, srcMap)
+			continue
+		}
+		dst[k] = v
+	}
+}
+
+type envOverride struct {
+	path  []string
+	value string
+}
+
+// envOverrides 解析配置文件 name 对应的 OWL_ 环境变量，按变量名排序，保证浅层的 key 先设置
+func envOverrides(name string, environ []string) []envOverride {
+	var overrides []envOverride
+	prefix := EnvPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "__"
+
+	sort.Strings(environ)
+	for _, kv := range environ {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok || !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		var path []string
+		for _, part := range strings.Split(strings.TrimPrefix(key, prefix), "__") {
+			if part == "" {
+				path = nil
+				break
+			}
+			path = append(path, strings.ReplaceAll(strings.ToLower(part), "_", "-"))
+		}
+		if len(path) == 0 {
+			continue
+		}
+		overrides = append(overrides, envOverride{path: path, value: value})
+	}
+	return overrides
+}
+
+// parseEnvValue 原配置是数字、布尔、数组、对象时，合法的 JSON 按 JSON 解析，
+// 原配置是字符串、空值或不存在时保持字符串，例如纯数字的密码，由 DecodeConfig 按结构体字段的类型转换
+func parseEnvValue(current any, value string) any {
+	switch current.(type) {
+	case nil, string:
+		return value
+	}
+	var v any
+	if err := jsoniter.UnmarshalFromString(value, &v); err == nil {
+		return v
+	}
+	return value
+}
+
+func setConfigPath(cfg map[string]any, path []string, value string) {
+	for _, key := range path[:len(path)-1] {
+		next, ok := cfg[key].(map[string]any)
+		if !ok {
+			next = make(map[string]any)
+			cfg[key] = next
+		}
+		cfg = next
+	}
+	key := path[len(path)-1]
+	cfg[key] = parseEnvValue(cfg[key], value)
+}
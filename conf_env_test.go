@@ -0,0 +1,189 @@
+package owl
+
+import (
+	"go.uber.org/dig"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newTestConfManager(t *testing.T, files map[string]string) *ConfManager {
+	dir := t.TempDir()
+	confDir := filepath.Join(dir, ConfPath)
+	if err := os.MkdirAll(confDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(confDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return NewConfigManager(&Stage{Container: dig.New(), runDir: dir, binDir: dir})
+}
+
+func TestConfigEnvironmentOverlayAndOverrides(t *testing.T) {
+	t.Setenv(AppEnvKey, "production")
+	t.Setenv("OWL_DB__PASSWORD", "123456")
+	t.Setenv("OWL_DB__MAX_IDLE_CONNS", "5")
+	t.Setenv("OWL_DB__POOL__SIZE", "8")
+	t.Setenv("OWL_MISSING__HOST", "ignored")
+
+	cfg := newTestConfManager(t, map[string]string{
+		"db.yml":            "host: 127.0.0.1\nport: 3306\npassword: secret\nmax-idle-conns: 1\npool:\n  size: 1\n  name: base\n",
+		"db.production.yml": "host: db.internal\npool:\n  name: prod\n",
+		"db.local.yml":      "host: localhost\n",
+	})
+
+	if cfg.Environment() != "production" {
+		t.Fatalf("Environment = %s", cfg.Environment())
+	}
+
+	var opt struct {
+		Host         string `json:"host"`
+		Port         int    `json:"port"`
+		Password     string `json:"password"`
+		MaxIdleConns int    `json:"max-idle-conns"`
+		Pool         struct {
+			Size int    `json:"size"`
+			Name string `json:"name"`
+		} `json:"pool"`
+	}
+	if err := cfg.GetConfig("db", &opt); err != nil {
+		t.Fatal(err)
+	}
+	if opt.Host != "db.internal" || opt.Port != 3306 || opt.Password != "123456" || opt.MaxIdleConns != 5 {
+		t.Fatalf("unexpected config %+v", opt)
+	}
+	if opt.Pool.Size != 8 || opt.Pool.Name != "prod" {
+		t.Fatalf("nested keys should merge: %+v", opt.Pool)
+	}
+
+	var files []string
+	for name := range cfg.allCfg {
+		files = append(files, name)
+	}
+	if !reflect.DeepEqual(files, []string{"db"}) {
+		t.Fatalf("overlay files should not be loaded on their own: %v", files)
+	}
+
+	if err := cfg.ReloadConfig("db"); err != nil {
+		t.Fatal(err)
+	}
+	opt.Host = ""
+	if err := cfg.GetConfig("db", &opt); err != nil || opt.Host != "db.internal" {
+		t.Fatalf("reload should keep the overlay: %s %v", opt.Host, err)
+	}
+}
+
+func TestEnvOverrides(t *testing.T) {
+	overrides := envOverrides("rate-limit", []string{
+		"OWL_RATE_LIMIT__API_KEY_HEADER=X-Token",
+		"OWL_RATE_LIMIT____BROKEN=1",
+		"OWL_RATE_LIMITX__KEY=ip",
+		"PATH=/usr/bin",
+	})
+	if len(overrides) != 1 || !reflect.DeepEqual(overrides[0].path, []string{"api-key-header"}) || overrides[0].value != "X-Token" {
+		t.Fatalf("overrides = %+v", overrides)
+	}
+}
+
+func TestConfigDottedFileWithoutBaseIsLoaded(t *testing.T) {
+	cfg := newTestConfManager(t, map[string]string{
+		"app.yml":            "name: owl\n",
+		"app.production.yml": "name: prod\n",
+		"foo.bar.yml":        "enabled: true\n",
+	})
+
+	if _, ok := cfg.allCfg["foo.bar"]; !ok {
+		t.Fatal("foo.bar.yml has no foo.yml and should be loaded")
+	}
+	if _, ok := cfg.allCfg["app.production"]; ok {
+		t.Fatal("app.production.yml is an overlay of app.yml and should not be loaded")
+	}
+	if name, _ := cfg.Value("app.name"); name != "owl" {
+		t.Fatalf("app.name without APP_ENV = %v", name)
+	}
+}
+
+func TestConfigReloadsWhenOverlayChanges(t *testing.T) {
+	t.Setenv(AppEnvKey, "production")
+	cfg := newTestConfManager(t, map[string]string{
+		"app.yml":            "name: owl\n",
+		"app.production.yml": "name: prod\n",
+	})
+
+	changed := make(chan any, 1)
+	cfg.Watch("app.name", func(old, new any) {
+		select {
+		case changed <- new:
+		default:
+		}
+	})
+
+	overlay := filepath.Join(cfg.confDir, "app.production.yml")
+	if err := os.WriteFile(overlay, []byte("name: prod2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case name := <-changed:
+		if name != "prod2" {
+			t.Fatalf("app.name = %v, want prod2", name)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("editing the overlay file should reload the config")
+	}
+}
+
+func TestEnvOverrideNumericSecretForEmptyKey(t *testing.T) {
+	t.Setenv("OWL_REDIS__PASSWORD", "123456")
+	t.Setenv("OWL_REDIS__DB", "2")
+
+	cfg := newTestConfManager(t, map[string]string{
+		"redis.yml": "host: localhost\nport: 6379\npassword:\n",
+	})
+
+	type redisOptions struct {
+		Host     string `json:"host"`
+		Port     int    `json:"port"`
+		Password string `json:"password"`
+		DB       int    `json:"db"`
+	}
+
+	var opt struct {
+		Password string `json:"password"`
+	}
+	if err := cfg.GetConfig("redis", &opt); err != nil || opt.Password != "123456" {
+		t.Fatalf("GetConfig password = %q, %v", opt.Password, err)
+	}
+
+	decoded, err := DecodeConfig[redisOptions](cfg, "redis")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Password != "123456" || decoded.Port != 6379 || decoded.DB != 2 {
+		t.Fatalf("DecodeConfig = %+v", decoded)
+	}
+}
+
+func TestConfigSetEnvironment(t *testing.T) {
+	t.Setenv(AppEnvKey, "")
+	cfg := newTestConfManager(t, map[string]string{
+		"app.yml":            "name: owl\n",
+		"app.production.yml": "name: prod\n",
+	})
+	if name, _ := cfg.Value("app.name"); name != "owl" {
+		t.Fatalf("app.name without environment = %v", name)
+	}
+
+	if err := cfg.SetEnvironment("production"); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Environment() != "production" {
+		t.Fatalf("Environment = %s", cfg.Environment())
+	}
+	if name, _ := cfg.Value("app.name"); name != "prod" {
+		t.Fatalf("app.name after SetEnvironment = %v", name)
+	}
+}
@@ -0,0 +1,174 @@
+package owl
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// RedactedValue config:dump --redact 时敏感配置显示的值
+const RedactedValue = "******"
+
+type configSchema struct {
+	key    string
+	keys   []string // 依次解析的配置，最后一个是 key
+	decode func(cfgManager *ConfManager) error
+}
+
+var (
+	configSchemas     = make(map[string]configSchema)
+	configSchemasLock sync.Mutex
+)
+
+// RegisterConfigSchema 登记配置 key 对应的结构体，config:check 时按结构体的 default validate 标签校验，
+// 同一个 key 重复登记时使用最后一次
+//
+// 运行时先读取上层配置再用 key 覆盖时，bases 按相同顺序传入上层配置，校验合并后的结果
+//
+//	owl.RegisterConfigSchema[database.Options]("db")
+//	owl.RegisterConfigSchema[web_server.HttpOptions]("app.http", "app")
+func RegisterConfigSchema[T any](key string, bases ...string) {
+	configSchemasLock.Lock()
+	defer configSchemasLock.Unlock()
+
+	keys := append(append([]string(nil), bases...), key)
+	configSchemas[key] = configSchema{
+		key:  key,
+		keys: keys,
+		decode: func(cfgManager *ConfManager) error {
+			_, err := decodeConfig[T](cfgManager, keys...)
+			return err
+		},
+	}
+}
+
+// ConfigCheck 一个配置 key 的校验结果
+type ConfigCheck struct {
+	Key     string
+	File    string
+	Skipped bool // 配置中没有这个 key
+	Err     error
+}
+
+// CheckConfig 按 key 排序校验所有登记的结构体，配置中没有 key 和 bases 时跳过
+func CheckConfig(cfgManager *ConfManager) []ConfigCheck {
+	configSchemasLock.Lock()
+	schemas := make([]configSchema, 0, len(configSchemas))
+	for _, schema := range configSchemas {
+		schemas = append(schemas, schema)
+	}
+	configSchemasLock.Unlock()
+
+	sort.Slice(schemas, func(a, b int) bool {
+		return schemas[a].key < schemas[b].key
+	})
+
+	checks := make([]ConfigCheck, 0, len(schemas))
+	for _, schema := range schemas {
+		check := ConfigCheck{Key: schema.key, Skipped: true}
+		for _, key := range schema.keys {
+			raw, file := cfgManager.lookup(key)
+			if check.File == "" {
+				check.File = file
+			}
+			if raw != nil {
+				check.Skipped = false
+			}
+		}
+		if !check.Skipped {
+			check.Err = schema.decode(cfgManager)
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// LoadConfigDir 读取 confDir 中的所有配置文件，文件格式错误时返回错误而不是 panic，用于命令行检查配置
+func LoadConfigDir(confDir string) (cfgManager *ConfManager, err error) {
+	info, err := os.Stat(confDir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s 不是目录", confDir)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	return NewConfigManagerFromDir(confDir, nil), nil
+}
+
+// Value 获取 key 对应的配置，例如 db 或 db.host，不存在时返回 false
+func (i *ConfManager) Value(key string) (any, bool) {
+	value, _ := i.lookup(key)
+	if m, ok := value.(map[string]any); ok && !strings.Contains(key, ".") {
+		delete(m, "abs-path") // lookup 返回的是副本
+	}
+	return value, value != nil
+}
+
+// All 所有配置文件的配置，key 为文件名
+func (i *ConfManager) All() map[string]any {
+	all := make(map[string]any)
+	for _, file := range i.Files() {
+		all[file[0]], _ = i.Value(file[0])
+	}
+	return all
+}
+
+// Files 所有配置文件名和路径，按文件名排序
+func (i *ConfManager) Files() [][2]string {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+
+	files := make([][2]string, 0, len(i.allCfg))
+	for name, cfg := range i.allCfg {
+		path, _ := cfg["abs-path"].(string)
+		files = append(files, [2]string{name, path})
+	}
+	sort.Slice(files, func(a, b int) bool {
+		return files[a][0] < files[b][0]
+	})
+	return files
+}
+
+// RedactConfig 复制配置并把密码、密钥、token 等敏感配置替换为 RedactedValue
+func RedactConfig(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		m := make(map[string]any, len(v))
+		for k, item := range v {
+			if isSecretConfigKey(k) {
+				if _, nested := item.(map[string]any); !nested && item != nil && item != "" {
+					m[k] = RedactedValue
+					continue
+				}
+			}
+			m[k] = RedactConfig(item)
+		}
+		return m
+	case []any:
+		s := make([]any, len(v))
+		for n, item := range v {
+			s[n] = RedactConfig(item)
+		}
+		return s
+	}
+	return value
+}
+
+// isSecretConfigKey password secret token credential 相关的 key 以及 key、*-key
+func isSecretConfigKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, word := range []string{"password", "passwd", "secret", "token", "credential"} {
+		if strings.Contains(key, word) {
+			return true
+		}
+	}
+	return key == "key" || strings.HasSuffix(key, "-key") || strings.HasSuffix(key, "_key")
+}
@@ -0,0 +1,99 @@
+package owl
+
+import (
+	"owl/log"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type configWatcher struct {
+	key      string
+	listener func(old, new any)
+}
+
+// Watch 监听 key 对应的配置，例如 db 或 db.host，配置文件修改后值有变化时调用 listener，
+// old new 为修改前后的值，key 不存在时为 nil，返回的函数用于取消监听
+func (i *ConfManager) Watch(key string, listener func(old, new any)) func() {
+	i.watchLock.Lock()
+	defer i.watchLock.Unlock()
+
+	i.nextWatcher++
+	id := i.nextWatcher
+	i.watchers[id] = &configWatcher{key: key, listener: listener}
+
+	return func() {
+		i.watchLock.Lock()
+		defer i.watchLock.Unlock()
+		delete(i.watchers, id)
+	}
+}
+
+// WatchConfig 监听 key 对应的配置并解析为 T，解析或校验失败时记录日志并保留之前的配置，
+// old 为上一次成功解析的配置，监听前解析失败时为 nil
+//
+//	owl.WatchConfig(cfgManager, "db", func(old, new *database.Options) { ... })
+func WatchConfig[T any](cfgManager *ConfManager, key string, listener func(old, new *T)) func() {
+	var lock sync.Mutex
+	last, _ := DecodeConfig[T](cfgManager, key)
+
+	return cfgManager.Watch(key, func(_, _ any) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		current, err := DecodeConfig[T](cfgManager, key)
+		if err != nil {
+			log.PrintLnRed("配置 ", key, " 修改后不合法，继续使用之前的配置\n", err)
+			return
+		}
+		old := last
+		last = current
+		listener(old, current)
+	})
+}
+
+// notify 比较文件 fileName 修改前后的配置，通知值有变化的监听
+func (i *ConfManager) notify(fileName string, oldCfg, newCfg map[string]any) {
+	i.watchLock.Lock()
+	ids := make([]int, 0, len(i.watchers))
+	for id, w := range i.watchers {
+		if file, _, _ := strings.Cut(w.key, "."); file == fileName {
+			ids = append(ids, id)
+		}
+	}
+	// 按添加顺序通知
+	sort.Ints(ids)
+	watchers := make([]*configWatcher, 0, len(ids))
+	for _, id := range ids {
+		watchers = append(watchers, i.watchers[id])
+	}
+	i.watchLock.Unlock()
+
+	oldCfg = withoutInternalKeys(oldCfg)
+	newCfg = withoutInternalKeys(newCfg)
+	for _, w := range watchers {
+		parts := strings.Split(w.key, ".")[1:]
+		oldValue, _ := configValue(oldCfg, parts)
+		newValue, _ := configValue(newCfg, parts)
+		if reflect.DeepEqual(oldValue, newValue) {
+			continue
+		}
+		w.listener(oldValue, newValue)
+	}
+}
+
+// configValue 按路径获取配置中的值
+func configValue(cfg map[string]any, path []string) (any, bool) {
+	var current any = cfg
+	for _, part := range path {
+		m, ok := current.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if current, ok = m[part]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
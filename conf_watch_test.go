@@ -0,0 +1,76 @@
+package owl
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func rewriteConfig(t *testing.T, cfg *ConfManager, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(cfg.confDir, name+".yml"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.ReloadConfig(name); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConfManagerWatchFansOutChanges(t *testing.T) {
+	cfg := newTestConfManager(t, map[string]string{
+		"db.yml":    "host: 127.0.0.1\nport: 3306\n",
+		"cache.yml": "default: file\n",
+	})
+
+	var hosts, ports, caches []any
+	cancelHost := cfg.Watch("db.host", func(old, new any) { hosts = append(hosts, old, new) })
+	cfg.Watch("db.host", func(old, new any) { hosts = append(hosts, "second") })
+	cfg.Watch("db.port", func(old, new any) { ports = append(ports, new) })
+	cfg.Watch("cache", func(old, new any) { caches = append(caches, new) })
+
+	rewriteConfig(t, cfg, "db", "host: db.internal\nport: 3306\n")
+	if !reflect.DeepEqual(hosts, []any{"127.0.0.1", "db.internal", "second"}) {
+		t.Fatalf("hosts = %v", hosts)
+	}
+	if len(ports) != 0 || len(caches) != 0 {
+		t.Fatalf("unchanged keys should not fire: ports=%v caches=%v", ports, caches)
+	}
+
+	cancelHost()
+	rewriteConfig(t, cfg, "db", "port: 3307\n")
+	if !reflect.DeepEqual(hosts, []any{"127.0.0.1", "db.internal", "second", "second"}) {
+		t.Fatalf("cancelled watcher should not fire: %v", hosts)
+	}
+	if len(ports) != 1 {
+		t.Fatalf("ports = %v", ports)
+	}
+}
+
+func TestWatchConfigKeepsLastValidConfig(t *testing.T) {
+	cfg := newTestConfManager(t, map[string]string{
+		"db.yml": "host: 127.0.0.1\nport: 3306\n",
+	})
+
+	type options struct {
+		Host string `json:"host" validate:"required"`
+		Port int    `json:"port" validate:"max=65535"`
+	}
+	var changes [][2]*options
+	WatchConfig(cfg, "db", func(old, new *options) {
+		changes = append(changes, [2]*options{old, new})
+	})
+
+	rewriteConfig(t, cfg, "db", "host: 127.0.0.1\nport: 70000\n")
+	if len(changes) != 0 {
+		t.Fatal("invalid config should not be delivered")
+	}
+
+	rewriteConfig(t, cfg, "db", "host: db.internal\nport: 3307\n")
+	if len(changes) != 1 {
+		t.Fatalf("changes = %d", len(changes))
+	}
+	if changes[0][0].Port != 3306 || changes[0][1].Host != "db.internal" || changes[0][1].Port != 3307 {
+		t.Fatalf("old = %+v new = %+v", changes[0][0], changes[0][1])
+	}
+}
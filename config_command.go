@@ -0,0 +1,140 @@
+package owl
+
+import (
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/spf13/cobra"
+	"io"
+	"strings"
+)
+
+// configCmd 不启动服务，检查和查看 conf 目录中的配置
+type configCmd struct {
+	app     *App
+	confDir string // --conf 参数，为空时使用运行目录下的 conf
+	redact  bool   // --redact 参数，隐藏密码等敏感配置
+}
+
+// regConfigCmd 注册 config:check config:get config:dump 命令
+func (i *SystemCtl) regConfigCmd(app *App) {
+	c := &configCmd{app: app}
+
+	check := &cobra.Command{
+		Use:   "config:check",
+		Short: "检查配置文件，校验已登记的配置结构体",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return c.check(cmd.OutOrStdout())
+		},
+	}
+	get := &cobra.Command{
+		Use:   "config:get <key>",
+		Short: "查看配置，例如 db 或 db.host",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return c.get(cmd.OutOrStdout(), args[0])
+		},
+	}
+	dump := &cobra.Command{
+		Use:   "config:dump",
+		Short: "查看合并环境配置和环境变量后的所有配置",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return c.dump(cmd.OutOrStdout())
+		},
+	}
+
+	for _, command := range []*cobra.Command{check, get, dump} {
+		command.Flags().StringVar(&c.confDir, "conf", "", "配置目录，默认为运行目录下的 conf")
+		command.SilenceUsage = true
+	}
+	get.Flags().BoolVar(&c.redact, "redact", false, "隐藏密码、密钥、token 等敏感配置")
+	dump.Flags().BoolVar(&c.redact, "redact", false, "隐藏密码、密钥、token 等敏感配置")
+
+	i.AddCommand(check, get, dump)
+}
+
+func (i *configCmd) load() (*ConfManager, error) {
+	confDir := i.confDir
+	if confDir == "" && i.app.stage != nil {
+		confDir = i.app.stage.ConfigPath()
+	}
+	if confDir == "" {
+		confDir = newStage().ConfigPath()
+	}
+	cfgManager, err := LoadConfigDir(confDir)
+	if err != nil {
+		return nil, fmt.Errorf("读取配置目录 %s 失败: %w", confDir, err)
+	}
+	return cfgManager, nil
+}
+
+func (i *configCmd) check(w io.Writer) error {
+	cfgManager, err := i.load()
+	if err != nil {
+		return err
+	}
+
+	for _, file := range cfgManager.Files() {
+		_, _ = fmt.Fprintf(w, "读取 %s\n", file[1])
+	}
+
+	var failed []string
+	for _, check := range CheckConfig(cfgManager) {
+		switch {
+		case check.Skipped:
+			_, _ = fmt.Fprintf(w, "跳过 %s: 没有配置\n", check.Key)
+		case check.Err != nil:
+			failed = append(failed, check.Key)
+			_, _ = fmt.Fprintf(w, "错误 %s: %s\n", check.Key, check.Err)
+		default:
+			_, _ = fmt.Fprintf(w, "通过 %s\n", check.Key)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%d 个配置不合法: %v", len(failed), failed)
+	}
+	return nil
+}
+
+func (i *configCmd) get(w io.Writer, key string) error {
+	cfgManager, err := i.load()
+	if err != nil {
+		return err
+	}
+	value, ok := cfgManager.Value(key)
+	if !ok {
+		return fmt.Errorf("配置 %s 不存在", key)
+	}
+	if i.redact {
+		if isSecretConfigKey(key[strings.LastIndex(key, ".")+1:]) {
+			value = RedactedValue
+		} else {
+			value = RedactConfig(value)
+		}
+	}
+	return writeConfig(w, value)
+}
+
+func (i *configCmd) dump(w io.Writer) error {
+	cfgManager, err := i.load()
+	if err != nil {
+		return err
+	}
+	var all any = cfgManager.All()
+	if i.redact {
+		all = RedactConfig(all)
+	}
+	return writeConfig(w, all)
+}
+
+// writeConfig 按 key 排序输出缩进的 JSON，方便比较不同机器的配置
+func writeConfig(w io.Writer, value any) error {
+	content, err := jsoniter.Config{SortMapKeys: true, EscapeHTML: false}.Froze().MarshalIndent(value, "", "  ")
+	if err != nil {
+		return fmt.Errorf("配置无法转换为 JSON: %w", err)
+	}
+	_, err = fmt.Fprintln(w, string(content))
+	return err
+}
@@ -0,0 +1,133 @@
+package owl
+
+import (
+	"bytes"
+	"go.uber.org/dig"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type checkedOptions struct {
+	Host string `json:"host" validate:"required"`
+	Port int    `json:"port" default:"80" validate:"min=1,max=65535"`
+}
+
+func runConfigCmd(t *testing.T, args ...string) (string, error) {
+	app := NewApp("owl", "owl", "", KillMain, nil, nil)
+	out := &bytes.Buffer{}
+	app.Console.SetOut(out)
+	app.Console.SetErr(out)
+	app.Console.SetArgs(args)
+	err := app.Console.Execute()
+	return out.String(), err
+}
+
+func TestConfigCheckCommand(t *testing.T) {
+	RegisterConfigSchema[checkedOptions]("server")
+	RegisterConfigSchema[checkedOptions]("missing")
+
+	valid := newTestConfManager(t, map[string]string{"server.yml": "host: 127.0.0.1\n"})
+	out, err := runConfigCmd(t, "config:check", "--conf", valid.confDir)
+	if err != nil {
+		t.Fatalf("config:check: %v\n%s", err, out)
+	}
+	if !strings.Contains(out, "通过 server") || !strings.Contains(out, "跳过 missing") {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+
+	invalid := newTestConfManager(t, map[string]string{"server.yml": "port: 70000\n"})
+	out, err = runConfigCmd(t, "config:check", "--conf", invalid.confDir)
+	if err == nil {
+		t.Fatalf("config:check should fail:\n%s", out)
+	}
+	if !strings.Contains(out, "server.host") || !strings.Contains(out, "server.port") {
+		t.Fatalf("every invalid key should be listed:\n%s", out)
+	}
+
+	if _, err = runConfigCmd(t, "config:check", "--conf", invalid.confDir+"-not-exists"); err == nil {
+		t.Fatal("missing conf dir should fail")
+	}
+}
+
+func TestConfigCheckMergesBaseKeys(t *testing.T) {
+	RegisterConfigSchema[checkedOptions]("site.http", "site")
+
+	// 只有上层配置时也校验，http 中的配置覆盖上层配置
+	cfg := newTestConfManager(t, map[string]string{"site.yml": "host: 127.0.0.1\nport: 70000\n"})
+	out, err := runConfigCmd(t, "config:check", "--conf", cfg.confDir)
+	if err == nil || !strings.Contains(out, "site.port") {
+		t.Fatalf("base keys should be checked:\n%s", out)
+	}
+
+	cfg = newTestConfManager(t, map[string]string{"site.yml": "host: 127.0.0.1\nport: 70000\nhttp:\n  port: 8080\n"})
+	out, err = runConfigCmd(t, "config:check", "--conf", cfg.confDir)
+	if err != nil || !strings.Contains(out, "通过 site.http") {
+		t.Fatalf("merged config should pass: %v\n%s", err, out)
+	}
+}
+
+func TestLoadConfigDirUnreadableFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Symlink(filepath.Join(dir, "missing.yml"), filepath.Join(dir, "broken.yml")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfigDir(dir); err == nil || !strings.Contains(err.Error(), "broken.yml") {
+		t.Fatalf("unreadable config file should return an error, got %v", err)
+	}
+}
+
+func TestConfigGetAndDumpCommands(t *testing.T) {
+	cfg := newTestConfManager(t, map[string]string{
+		"db.yml":  "host: 127.0.0.1\npassword: secret\nnested:\n  api-key: abc\n  port: 3306\n",
+		"app.yml": "name: owl\n",
+	})
+
+	out, err := runConfigCmd(t, "config:get", "db.nested.port", "--conf", cfg.confDir)
+	if err != nil || strings.TrimSpace(out) != "3306" {
+		t.Fatalf("config:get = %q, %v", out, err)
+	}
+	out, err = runConfigCmd(t, "config:get", "db.password", "--conf", cfg.confDir, "--redact")
+	if err != nil || strings.TrimSpace(out) != `"`+RedactedValue+`"` {
+		t.Fatalf("config:get --redact = %q, %v", out, err)
+	}
+	if _, err = runConfigCmd(t, "config:get", "db.missing", "--conf", cfg.confDir); err == nil {
+		t.Fatal("missing key should fail")
+	}
+
+	out, err = runConfigCmd(t, "config:dump", "--conf", cfg.confDir, "--redact")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out, "secret") || strings.Contains(out, "abc") || strings.Contains(out, "abs-path") {
+		t.Fatalf("config:dump --redact leaked:\n%s", out)
+	}
+	if !strings.Contains(out, `"host": "127.0.0.1"`) || strings.Index(out, `"app"`) > strings.Index(out, `"db"`) {
+		t.Fatalf("config:dump should be sorted JSON:\n%s", out)
+	}
+}
+
+func TestEnvFlagAppliesToConfManager(t *testing.T) {
+	t.Setenv(AppEnvKey, "")
+	cfg := newTestConfManager(t, map[string]string{
+		"app.yml":            "name: owl\n",
+		"app.production.yml": "name: prod\n",
+	})
+	stage := &Stage{Container: dig.New()}
+	if err := stage.Container.Provide(func() *ConfManager { return cfg }); err != nil {
+		t.Fatal(err)
+	}
+
+	app := NewApp("owl", "owl", "", KillMain, stage, nil)
+	out := &bytes.Buffer{}
+	app.Console.SetOut(out)
+	app.Console.SetErr(out)
+	app.Console.SetArgs([]string{"config:check", "--conf", cfg.confDir, "--env", "production"})
+	if err := app.Console.Execute(); err != nil {
+		t.Fatalf("config:check: %v\n%s", err, out)
+	}
+	if name, _ := cfg.Value("app.name"); name != "prod" {
+		t.Fatalf("app.name after --env = %v", name)
+	}
+}
@@ -2,5 +2,6 @@ package cache
 
 // Factory 存储工厂，通过名称获取到具体的缓存者
 type Factory interface {
-	Store(name string)
+	// Store Get a cache store instance by name, an empty name returns the default store.
+	Store(name string) Repository
 }
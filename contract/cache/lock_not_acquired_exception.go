@@ -0,0 +1,5 @@
+package cache
+
+import "errors"
+
+var LockNotAcquiredError = errors.New("lock not acquired")
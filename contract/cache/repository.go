@@ -2,6 +2,27 @@ package cache
 
 // Repository 定义缓存仓库接口
 type Repository interface {
+	// Retrieve an item from the cache by key.
+	//
+	// @param  string  $key
+	// @param  mixed  $default
+	// @return mixed
+	Get(key string, defaultVal any) any
+
+	// Retrieve multiple items from the cache by key.
+	//
+	// Items not found in the cache will have a null value.
+	//
+	// @param  array  $keys
+	// @return array
+	Many(keys []string) []any
+
+	// Determine if an item exists in the cache.
+	//
+	// @param  string  $key
+	// @return bool
+	Has(key string) bool
+
 	// Retrieve an item from the cache and delete it.
 	//
 	// @param  string  $key
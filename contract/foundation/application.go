@@ -1,5 +1,7 @@
 package foundation
 
+import "owl/contract"
+
 // Application 定义应用接口
 type Application interface {
 	// Version 获取应用版本号
@@ -41,14 +43,11 @@ type Application interface {
 	// RegisterConfiguredProviders 注册所有已配置的提供者
 	RegisterConfiguredProviders()
 
-	// Register 注册服务提供者
-	Register(provider string, force bool) ServiceProvider
-
-	// RegisterDeferredProvider 注册延迟的提供者和服务
-	RegisterDeferredProvider(provider, service string)
+	// RegisterProvider 按名称注册服务提供者
+	RegisterProvider(provider string, force bool) contract.ServiceProvider
 
 	// ResolveProvider 根据名称解析服务提供者实例
-	ResolveProvider(provider string) ServiceProvider
+	ResolveProvider(provider string) contract.ServiceProvider
 
 	// Boot 启动应用的服务提供者
 	Boot()
@@ -69,7 +68,7 @@ type Application interface {
 	GetNamespace() (string, error)
 
 	// GetProviders 获取已注册的服务提供者实例
-	GetProviders(provider string) []ServiceProvider
+	GetProviders(provider string) []contract.ServiceProvider
 
 	// HasBeenBootstrapped 判断应用是否已引导
 	HasBeenBootstrapped() bool
@@ -92,10 +91,15 @@ type Application interface {
 
 // MaintenanceMode 维护模式接口
 type MaintenanceMode interface {
-	// 维护模式相关的方法
-}
+	// Activate 进入维护模式，payload 为维护信息，例如 retry message
+	Activate(payload map[string]any) error
+
+	// Deactivate 退出维护模式
+	Deactivate() error
+
+	// Active 判断是否处于维护模式
+	Active() bool
 
-// ServiceProvider 服务提供者接口
-type ServiceProvider interface {
-	// 服务提供者相关的方法
+	// Data 获取进入维护模式时保存的信息
+	Data() (map[string]any, error)
 }
@@ -1,8 +1,8 @@
 package contract
 
 type ServerConfig struct {
-	Host     string `json:"host"`
-	Port     int    `json:"port"`
+	Host     string `json:"host" validate:"required"`
+	Port     int    `json:"port" validate:"min=0,max=65535"`
 	Username string `json:"username"`
 	Password string `json:"password"`
 	AbsPath  string `json:"abs-path"`
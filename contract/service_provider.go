@@ -4,3 +4,10 @@ type ServiceProvider interface {
 	Register() // 构建容器需要的对象
 	Boot()
 }
+
+// DeferrableProvider 延迟的服务提供者，Provides 返回的类型第一次从容器中获取时才注册和启动
+// 例如 return []any{(*cache.CacheManager)(nil)}，接口类型使用 (*Interface)(nil)
+type DeferrableProvider interface {
+	ServiceProvider
+	Provides() []any
+}
@@ -6,3 +6,18 @@ type Connector interface {
 	Open(dsn string, cfg *gorm.Config) (*gorm.DB, error)
 	Options() *Options
 }
+
+// NewConnector 根据配置中的驱动创建连接器，opt 为 nil 时使用 mysql 默认配置
+func NewConnector(opt *Options) Connector {
+	if opt == nil {
+		return NewMysqlGetter(nil)
+	}
+	switch opt.Driver {
+	case "postgres", "pgsql":
+		return NewPgSqlGetter(opt)
+	case "sqlite":
+		return NewSqliteGetter(opt)
+	default:
+		return NewMysqlGetter(opt)
+	}
+}
@@ -1,6 +1,8 @@
 package database
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -10,16 +12,29 @@ import (
 	"os"
 	"owl"
 	"owl/contract"
+	owllog "owl/log"
 	"strconv"
 	"sync"
 	"time"
 )
 
 var (
-	connections = make(map[string]*gorm.DB, 10)
+	connections = make(map[string]*pool, 10) // dsn => 新建的服务使用的连接池
+	pools       = make(map[*pool]struct{})   // 所有未关闭的连接池，包括 Reconnect 后还有服务在使用的旧连接池
 	lock        sync.Mutex
 )
 
+// pool 多个 DatabaseService 共享的连接池，没有服务使用时关闭
+type pool struct {
+	dsn  string
+	db   *gorm.DB
+	refs int
+}
+
+func init() {
+	owl.RegisterConfigSchema[Options]("db") // config:check 校验 conf/db
+}
+
 // 能力
 // 支持连接多个不同的数据库
 // 内部维护数据库连接池
@@ -27,20 +42,27 @@ var (
 
 type Options struct {
 	contract.ServerConfig
-	Driver       string `json:"driver"` // 数据库类型
+	Driver       string `json:"driver" default:"mysql" validate:"oneof=mysql postgres pgsql sqlite"` // 数据库类型
 	Database     string `json:"database"`
 	Schema       string `json:"schema"`
-	Charset      string `json:"charset"`
+	Charset      string `json:"charset" default:"utf8mb4"`
 	Query        string `json:"query"`
-	MaxIdleConns int    `json:"max-idle-conns"`
-	MaxConns     int    `json:"max-conns"`
+	MaxIdleConns int    `json:"max-idle-conns" default:"10" validate:"min=0"`
+	MaxConns     int    `json:"max-conns" default:"100" validate:"min=1"`
+
+	cfgManager *owl.ConfManager // 配置来源，NewOption 设置，NewDatabaseService 据此监听配置
+	key        string
 }
 
-func NewOption(cfgManager *owl.ConfManager, dbFile string) (opt *Options) {
-	err := cfgManager.GetConfig(dbFile, &opt)
+// NewOption 读取 dbFile 对应的配置，没有的字段使用 default 标签的值，与配置修改后 Watch 读到的配置一致
+// 使用返回的配置创建的服务会监听配置，修改后自动重新连接
+func NewOption(cfgManager *owl.ConfManager, dbFile string) *Options {
+	opt, err := owl.DecodeConfig[Options](cfgManager, dbFile)
 	if err != nil {
+		owllog.PrintLnRed("数据库配置不合法 ", dbFile, err)
 		return nil
 	}
+	opt.cfgManager, opt.key = cfgManager, dbFile
 	return opt
 }
 
@@ -49,9 +71,27 @@ type DatabaseService struct {
 	dsn      string
 	opt      *Options
 	db       *gorm.DB
+	pool     *pool
 	dbGetter Connector
+	unwatch  func()       // 取消监听配置，Close 时调用
+	mu       sync.RWMutex // 保护 opt db dsn pool dbGetter，Reconnect 时替换
 }
 
+// NewDatabaseServiceFromConfig 读取 conf 目录下 key 对应的配置并连接，配置修改后自动重新连接
+func NewDatabaseServiceFromConfig(cfgManager *owl.ConfManager, key string) *DatabaseService {
+	return NewDatabaseService(NewConnector(NewOption(cfgManager, key)))
+}
+
+// NewDatabaseServiceFromStage 同 NewDatabaseServiceFromConfig，应用停止时调用 Close 释放连接池
+func NewDatabaseServiceFromStage(stage *owl.Stage, cfgManager *owl.ConfManager, key string) *DatabaseService {
+	i := NewDatabaseServiceFromConfig(cfgManager, key)
+	_ = stage.Lifecycle().OnStop("database:"+key, func(ctx context.Context) error {
+		return i.Close()
+	})
+	return i
+}
+
+// NewDatabaseService 配置来自 NewOption 时监听配置，修改后自动重新连接，其他配置需要时调用 Watch
 func NewDatabaseService(dbGetter Connector) *DatabaseService {
 	opt := dbGetter.Options()
 
@@ -61,75 +101,170 @@ func NewDatabaseService(dbGetter Connector) *DatabaseService {
 	}
 
 	i.BlockRun()
+	if opt.cfgManager != nil {
+		i.unwatch = i.Watch(opt.cfgManager, opt.key)
+	}
 	return i
 }
 
-// Boot 打开连接
+// Boot 打开连接，相同 dsn 的服务共享连接池，已经打开时不重复打开
 func (i *DatabaseService) BlockRun() {
 	lock.Lock()
 	defer lock.Unlock()
 
-	cacheDb, ok := connections[i.dsn] // 申请连接过
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.pool != nil {
+		return
+	}
 
-	if !ok || cacheDb == nil {
-		i.dsn = i.getDsnFromCfg(i.opt)
+	dsn := i.getDsnFromCfg(i.opt)
+	p, ok := connections[dsn] // 申请连接过
+	if !ok {
+		openDb, err := open(i.dbGetter, dsn, i.opt)
+		if err != nil {
+			panic("数据库连接失败，请检查数据库是否启动，配置是否错误" + err.Error())
+		}
+		p = addPool(dsn, openDb)
+	}
+	p.refs++
+	i.dsn, i.pool, i.db = dsn, p, p.db
+}
 
-		i.watchCfgFileChange()
+// addPool 记录新打开的连接池，调用前需持有 lock
+func addPool(dsn string, db *gorm.DB) *pool {
+	p := &pool{dsn: dsn, db: db}
+	connections[dsn] = p
+	pools[p] = struct{}{}
+	return p
+}
 
-		newLogger := logger.New(
-			log.New(os.Stdout, "\r\n", log.LstdFlags), // io writer
-			logger.Config{
-				SlowThreshold: time.Second,   // 慢 SQL 阈值
-				LogLevel:      logger.Silent, // Log level
-				Colorful:      false,         // 禁用彩色打印
-			},
-		)
+// release 服务不再使用连接池，没有服务使用时关闭，调用前需持有 lock
+func (i *pool) release() error {
+	i.refs--
+	if i.refs > 0 {
+		return nil
+	}
+	if connections[i.dsn] == i {
+		delete(connections, i.dsn)
+	}
+	delete(pools, i)
+	sqlDB, err := i.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
 
-		gormCfg := &gorm.Config{
-			PrepareStmt:                              false,
-			DisableForeignKeyConstraintWhenMigrating: true,
-			NamingStrategy: schema.NamingStrategy{
-				//单数表名
-				SingularTable: true,
-			},
-			Logger:                 newLogger,
-			SkipDefaultTransaction: true,
-		}
+// open 打开连接并设置连接池
+func open(connector Connector, dsn string, opt *Options) (*gorm.DB, error) {
+	newLogger := logger.New(
+		log.New(os.Stdout, "\r\n", log.LstdFlags), // io writer
+		logger.Config{
+			SlowThreshold: time.Second,   // 慢 SQL 阈值
+			LogLevel:      logger.Silent, // Log level
+			Colorful:      false,         // 禁用彩色打印
+		},
+	)
 
-		var openDb *gorm.DB
-		var err error
-		openDb, err = i.dbGetter.Open(i.dsn, gormCfg)
+	gormCfg := &gorm.Config{
+		PrepareStmt:                              false,
+		DisableForeignKeyConstraintWhenMigrating: true,
+		NamingStrategy: schema.NamingStrategy{
+			//单数表名
+			SingularTable: true,
+		},
+		Logger:                 newLogger,
+		SkipDefaultTransaction: true,
+	}
 
-		if err != nil {
+	openDb, err := connector.Open(dsn, gormCfg)
+	if err != nil {
+		return nil, err
+	}
+	sqlDB, err := openDb.DB()
+	if err != nil {
+		return nil, err
+	}
 
-			panic("数据库连接失败，请检查数据库是否启动，配置是否错误" + err.Error())
-		}
-		sqlDB, err := openDb.DB()
+	// SetMaxIdleConns 设置空闲连接池中连接的最大数量
+	sqlDB.SetMaxIdleConns(opt.MaxIdleConns)
 
-		// SetMaxIdleConns 设置空闲连接池中连接的最大数量
-		sqlDB.SetMaxIdleConns(i.opt.MaxIdleConns)
+	// SetMaxOpenConns 设置打开数据库连接的最大数量。
+	// <= 数据库配置的连接数量
+	sqlDB.SetMaxOpenConns(opt.MaxConns)
 
-		// SetMaxOpenConns 设置打开数据库连接的最大数量。
-		// <= 数据库配置的连接数量
-		sqlDB.SetMaxOpenConns(i.opt.MaxConns)
+	// SetConnMaxLifetime 设置了连接可复用的最大时间。
+	sqlDB.SetConnMaxLifetime(time.Hour)
+	return openDb, nil
+}
 
-		// SetConnMaxLifetime 设置了连接可复用的最大时间。
-		sqlDB.SetConnMaxLifetime(time.Hour)
-		if err != nil {
+// Watch 监听配置 key，例如 db，配置修改后使用新的配置重新连接，返回的函数用于取消监听
+func (i *DatabaseService) Watch(cfgManager *owl.ConfManager, key string) func() {
+	return owl.WatchConfig(cfgManager, key, func(old, new *Options) {
+		if err := i.Reconnect(new); err != nil {
+			owllog.PrintLnRed("数据库重连失败，继续使用之前的连接 ", key, err)
 			return
 		}
+		owllog.PrintLnBlue("数据库配置修改，已重新连接 ", key)
+	})
+}
 
-		i.db = openDb
-		connections[i.dsn] = openDb
+// Reconnect 使用新的配置打开连接池，成功后只替换当前服务的连接，失败时保留当前连接
+// 旧连接池在没有其他服务使用时关闭，关闭时会等待正在执行的查询完成；
+// 使用相同 dsn 新建的服务之后会使用新的连接池
+func (i *DatabaseService) Reconnect(opt *Options) error {
+	connector := NewConnector(opt)
+	dsn := i.getDsnFromCfg(opt)
+	openDb, err := open(connector, dsn, opt)
+	if err != nil {
+		return err
 	}
 
-	return
+	lock.Lock()
+	defer lock.Unlock()
+
+	p := addPool(dsn, openDb)
+	p.refs++
+
+	i.mu.Lock()
+	old := i.pool
+	i.opt, i.dbGetter, i.dsn, i.db, i.pool = opt, connector, dsn, openDb, p
+	i.mu.Unlock()
+
+	if old != nil {
+		return old.release()
+	}
+	return nil
+}
+
+// Close 停止监听配置，当前服务不再使用连接池，没有其他服务使用时关闭连接池
+func (i *DatabaseService) Close() error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	i.mu.Lock()
+	p, unwatch := i.pool, i.unwatch
+	i.pool, i.unwatch = nil, nil
+	i.mu.Unlock()
+
+	if unwatch != nil {
+		unwatch()
+	}
+	if p == nil {
+		return nil
+	}
+	return p.release()
 }
 
 func (i *DatabaseService) Get() *gorm.DB {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
 	return i.db
 }
 func (i *DatabaseService) GetOptions() *Options {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
 	return i.opt
 }
 func (i *DatabaseService) getDsnFromCfg(opt *Options) string {
@@ -146,25 +281,48 @@ func (i *DatabaseService) getDsnFromCfg(opt *Options) string {
 			opt.Query,
 		)
 
+	} else if opt.Driver == "postgres" || opt.Driver == "pgsql" {
+		dsn = fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s",
+			opt.Host,
+			opt.Port,
+			opt.Username,
+			opt.Password,
+			opt.Database,
+		)
+		if opt.Schema != "" {
+			dsn += " search_path=" + opt.Schema
+		}
+		if opt.Query != "" {
+			dsn += " " + opt.Query // 例如 sslmode=disable TimeZone=Asia/Shanghai
+		}
 	} else if opt.Driver == "sqlite" {
 		dsn = opt.Host
 	}
 	return dsn
 }
 
-func (i *DatabaseService) watchCfgFileChange() {
-	go func() {
-		for {
-			select {
-			case file := <-owl.CfgChangeNotify[i.opt.AbsPath]:
-				lock.Lock()
-				i.l.Info("数据库重连" + file)
-				delete(connections, i.dsn)
-				lock.Unlock()
-				return
-			}
+// CloseAll 关闭所有连接池，正在执行的查询完成后连接才会关闭
+// NewDatabaseServiceFromStage 创建的服务在应用停止时自动释放连接池，不需要再调用
+func CloseAll(ctx context.Context) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	var errs []error
+	for p := range pools {
+		delete(pools, p)
+		delete(connections, p.dsn)
+		if err := ctx.Err(); err != nil {
+			return errors.Join(append(errs, err)...)
 		}
-	}()
+		sqlDB, err := p.db.DB()
+		if err == nil {
+			err = sqlDB.Close()
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
 }
 
 func Paginate(c *gin.Context) func(db *gorm.DB) *gorm.DB {
@@ -0,0 +1,83 @@
+package database
+
+import (
+	"os"
+	"owl"
+	"owl/contract"
+	"path/filepath"
+	"testing"
+)
+
+func TestDatabaseServiceReconnectKeepsSharedPool(t *testing.T) {
+	opt := &Options{
+		ServerConfig: contract.ServerConfig{Host: filepath.Join(t.TempDir(), "test.db")},
+		Driver:       "sqlite",
+		MaxIdleConns: 1,
+		MaxConns:     1,
+	}
+	first := NewDatabaseService(NewSqliteGetter(opt))
+	second := NewDatabaseService(NewSqliteGetter(opt))
+	if first.Get() != second.Get() {
+		t.Fatal("services with the same dsn should share a pool")
+	}
+
+	changed := *opt
+	changed.MaxConns = 2
+	if err := first.Reconnect(&changed); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.Get().Exec("SELECT 1").Error; err != nil {
+		t.Fatalf("reconnecting one service should not close the pool of others: %v", err)
+	}
+
+	if err := second.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.Get().Exec("SELECT 1").Error; err == nil {
+		t.Fatal("a pool without services should be closed")
+	}
+	if err := first.Get().Exec("SELECT 1").Error; err != nil {
+		t.Fatal(err)
+	}
+	_ = first.Close()
+}
+
+func TestDatabaseServiceBlockRunTakesOneReference(t *testing.T) {
+	service := NewDatabaseService(NewSqliteGetter(&Options{
+		ServerConfig: contract.ServerConfig{Host: filepath.Join(t.TempDir(), "test.db")},
+		Driver:       "sqlite",
+		MaxIdleConns: 1,
+		MaxConns:     1,
+	}))
+	service.BlockRun()
+	if refs := service.pool.refs; refs != 1 {
+		t.Fatalf("refs = %d, want 1", refs)
+	}
+
+	if err := service.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.Get().Exec("SELECT 1").Error; err == nil {
+		t.Fatal("the pool should be closed after the only service is closed")
+	}
+}
+
+func TestNewOptionAppliesDefaultsAndWatches(t *testing.T) {
+	dir := t.TempDir()
+	content := "driver: sqlite\nhost: " + filepath.Join(dir, "test.db") + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "db.yml"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfgManager := owl.NewConfigManagerFromDir(dir, nil)
+
+	opt := NewOption(cfgManager, "db")
+	if opt == nil || opt.MaxIdleConns != 10 || opt.MaxConns != 100 {
+		t.Fatalf("defaults should be applied: %+v", opt)
+	}
+
+	service := NewDatabaseService(NewConnector(opt))
+	defer service.Close()
+	if service.unwatch == nil {
+		t.Fatal("services created from NewOption should watch the config")
+	}
+}
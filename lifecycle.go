@@ -0,0 +1,169 @@
+package owl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"owl/log"
+	"sync"
+	"time"
+)
+
+// DefaultShutdownTimeout 停止所有服务的最长时间，超时后剩余的 OnStop 钩子收到已取消的 ctx
+const DefaultShutdownTimeout = 30 * time.Second
+
+// Hook 服务的启动和停止钩子，OnStart 按添加顺序执行，OnStop 按相反顺序执行
+type Hook struct {
+	Name    string
+	OnStart func(ctx context.Context) error
+	OnStop  func(ctx context.Context) error
+}
+
+// Lifecycle 管理服务的启动和停止，后启动的服务先停止
+type Lifecycle struct {
+	lock     sync.Mutex
+	pending  []Hook // 等待 Start 执行 OnStart
+	started  []Hook // 已启动，按启动顺序
+	running  bool   // Start 之后、Stop 之前
+	stopping bool
+	done     chan struct{}
+}
+
+func NewLifecycle() *Lifecycle {
+	return &Lifecycle{done: make(chan struct{})}
+}
+
+// Append 添加钩子，没有 OnStart 的钩子视为已启动，Start 之后添加时立即执行 OnStart，失败时不会添加
+func (i *Lifecycle) Append(hook Hook) error {
+	i.lock.Lock()
+	if i.stopping {
+		i.lock.Unlock()
+		return fmt.Errorf("服务 [%s] 添加失败，应用正在停止", hook.Name)
+	}
+	if hook.OnStart == nil {
+		i.started = append(i.started, hook)
+		i.lock.Unlock()
+		return nil
+	}
+	if !i.running {
+		i.pending = append(i.pending, hook)
+		i.lock.Unlock()
+		return nil
+	}
+	i.lock.Unlock()
+
+	if err := hook.OnStart(context.Background()); err != nil {
+		return fmt.Errorf("服务 [%s] 启动失败: %w", hook.Name, err)
+	}
+	return i.markStarted(context.Background(), hook)
+}
+
+// OnStart 添加只有启动钩子的服务
+func (i *Lifecycle) OnStart(name string, fn func(ctx context.Context) error) error {
+	return i.Append(Hook{Name: name, OnStart: fn})
+}
+
+// OnStop 添加只有停止钩子的服务，例如构造时已经启动的 http 服务
+func (i *Lifecycle) OnStop(name string, fn func(ctx context.Context) error) error {
+	return i.Append(Hook{Name: name, OnStop: fn})
+}
+
+// Start 按顺序执行 OnStart，钩子中可以继续 Append，某个钩子失败时停止已经启动的服务并返回错误
+func (i *Lifecycle) Start(ctx context.Context) error {
+	for {
+		i.lock.Lock()
+		if i.running || i.stopping {
+			i.lock.Unlock()
+			return nil
+		}
+		if len(i.pending) == 0 {
+			i.running = true
+			i.lock.Unlock()
+			return nil
+		}
+		hook := i.pending[0]
+		i.pending = i.pending[1:]
+		i.lock.Unlock()
+
+		if err := hook.OnStart(ctx); err != nil {
+			startErr := fmt.Errorf("服务 [%s] 启动失败: %w", hook.Name, err)
+			return errors.Join(startErr, i.Stop(ctx))
+		}
+		if err := i.markStarted(ctx, hook); err != nil {
+			return err
+		}
+	}
+}
+
+// markStarted 记录已启动的服务，启动期间应用开始停止时直接停止这个服务
+func (i *Lifecycle) markStarted(ctx context.Context, hook Hook) error {
+	i.lock.Lock()
+	if !i.stopping {
+		i.started = append(i.started, hook)
+		i.lock.Unlock()
+		return nil
+	}
+	i.lock.Unlock()
+
+	if hook.OnStop != nil {
+		_ = hook.OnStop(ctx)
+	}
+	return fmt.Errorf("服务 [%s] 添加失败，应用正在停止", hook.Name)
+}
+
+// Stop 按相反顺序执行已启动服务的 OnStop，只执行一次，返回所有失败的钩子的错误
+func (i *Lifecycle) Stop(ctx context.Context) error {
+	i.lock.Lock()
+	if i.stopping {
+		i.lock.Unlock()
+		return nil
+	}
+	i.stopping = true
+	i.running = false
+	close(i.done)
+	started := i.started
+	i.started = nil
+	i.pending = nil
+	i.lock.Unlock()
+
+	var errs []error
+	for n := len(started) - 1; n >= 0; n-- {
+		hook := started[n]
+		if hook.OnStop == nil {
+			continue
+		}
+		begin := time.Now()
+		if err := hook.OnStop(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("服务 [%s] 停止失败: %w", hook.Name, err))
+			continue
+		}
+		log.PrintLnBlue(fmt.Sprintf("服务 [%s] 已停止 %s", hook.Name, time.Since(begin)))
+	}
+	return errors.Join(errs...)
+}
+
+// Done 开始停止时关闭，后台协程可以监听后退出
+func (i *Lifecycle) Done() <-chan struct{} {
+	return i.done
+}
+
+// Shutdown 在 timeout 内停止所有服务，timeout <= 0 时使用 DefaultShutdownTimeout
+func (i *Lifecycle) Shutdown(timeout time.Duration) error {
+	if timeout <= 0 {
+		timeout = DefaultShutdownTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	result := make(chan error, 1)
+	go func() {
+		result <- i.Stop(ctx)
+	}()
+
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("停止服务超时 %s: %w", timeout, ctx.Err())
+	}
+}
@@ -0,0 +1,153 @@
+package owl
+
+import (
+	"context"
+	"errors"
+	"go.uber.org/dig"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func recordHook(name string, events *[]string) Hook {
+	return Hook{
+		Name: name,
+		OnStart: func(ctx context.Context) error {
+			*events = append(*events, "start:"+name)
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			*events = append(*events, "stop:"+name)
+			return nil
+		},
+	}
+}
+
+func TestLifecycleStartStopOrder(t *testing.T) {
+	lc := NewLifecycle()
+	var events []string
+
+	_ = lc.Append(recordHook("db", &events))
+	_ = lc.OnStop("http", func(ctx context.Context) error {
+		events = append(events, "stop:http")
+		return nil
+	})
+	_ = lc.Append(recordHook("queue", &events))
+
+	if err := lc.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// 启动之后添加的钩子立即启动
+	_ = lc.Append(recordHook("late", &events))
+
+	if err := lc.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := lc.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-lc.Done():
+	default:
+		t.Fatal("Done should be closed after Stop")
+	}
+
+	want := []string{"start:db", "start:queue", "start:late", "stop:late", "stop:queue", "stop:db", "stop:http"}
+	if !reflect.DeepEqual(events, want) {
+		t.Fatalf("events = %v, want %v", events, want)
+	}
+	if err := lc.Append(recordHook("after", &events)); err == nil {
+		t.Fatal("Append after Stop should fail")
+	}
+}
+
+func TestLifecycleStartFailureStopsStartedHooks(t *testing.T) {
+	lc := NewLifecycle()
+	var events []string
+
+	_ = lc.Append(recordHook("db", &events))
+	_ = lc.OnStart("broken", func(ctx context.Context) error { return errors.New("boom") })
+	_ = lc.Append(recordHook("never", &events))
+
+	err := lc.Start(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Fatalf("Start error = %v", err)
+	}
+	want := []string{"start:db", "stop:db"}
+	if !reflect.DeepEqual(events, want) {
+		t.Fatalf("events = %v, want %v", events, want)
+	}
+}
+
+func TestLifecycleShutdownTimeout(t *testing.T) {
+	lc := NewLifecycle()
+	_ = lc.OnStop("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	start := time.Now()
+	err := lc.Shutdown(20 * time.Millisecond)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Shutdown error = %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Fatal("Shutdown should respect the timeout")
+	}
+}
+
+func TestApplicationTerminatesOnStop(t *testing.T) {
+	app := newTestApplication(t)
+	var terminated bool
+	app.Terminating(func() { terminated = true })
+
+	if err := app.Lifecycle().Shutdown(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if !terminated {
+		t.Fatal("terminating callbacks should run when the lifecycle stops")
+	}
+}
+
+func TestAppStartRunsHooksBeforeBlockingStartFunc(t *testing.T) {
+	stage := &Stage{Container: dig.New()}
+	var events []string
+	_ = stage.Lifecycle().Append(recordHook("db", &events))
+
+	block := make(chan struct{})
+	defer close(block)
+	app := (&App{name: "test", startFunc: func() { <-block }}).WithStage(stage, time.Second)
+
+	if err := app.Start(nil); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"start:db"}; !reflect.DeepEqual(events, want) {
+		t.Fatalf("events = %v, want %v", events, want)
+	}
+	if err := app.Stop(nil); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"start:db", "stop:db"}; !reflect.DeepEqual(events, want) {
+		t.Fatalf("events = %v, want %v", events, want)
+	}
+}
+
+func TestNewAppStopsStageByDefault(t *testing.T) {
+	stage := &Stage{Container: dig.New()}
+	var events []string
+	_ = stage.Lifecycle().Append(recordHook("rabbit", &events))
+
+	block := make(chan struct{})
+	defer close(block)
+	app := NewApp("owl-test", "owl-test", "", KillMain, stage, func() { <-block })
+	if err := app.Start(nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Stop(nil); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"start:rabbit", "stop:rabbit"}; !reflect.DeepEqual(events, want) {
+		t.Fatalf("events = %v, want %v", events, want)
+	}
+}
@@ -0,0 +1,66 @@
+package owl
+
+import (
+	jsoniter "github.com/json-iterator/go"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// MaintenanceFile 维护模式标记文件，相对于 storage 目录
+const MaintenanceFile = "framework/down"
+
+// FileMaintenanceMode 使用 storage/framework/down 文件记录维护状态，多个进程共享同一个目录时状态一致
+type FileMaintenanceMode struct {
+	path string
+}
+
+func NewFileMaintenanceMode(path string) *FileMaintenanceMode {
+	return &FileMaintenanceMode{path: path}
+}
+
+// Activate 写入维护文件，payload 中没有 time 时记录当前时间
+func (i *FileMaintenanceMode) Activate(payload map[string]any) error {
+	data := make(map[string]any, len(payload)+1)
+	for k, v := range payload {
+		data[k] = v
+	}
+	if _, ok := data["time"]; !ok {
+		data["time"] = time.Now().Unix()
+	}
+
+	content, err := jsoniter.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(i.path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(i.path, content, 0644)
+}
+
+func (i *FileMaintenanceMode) Deactivate() error {
+	err := os.Remove(i.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (i *FileMaintenanceMode) Active() bool {
+	_, err := os.Stat(i.path)
+	return err == nil
+}
+
+func (i *FileMaintenanceMode) Data() (map[string]any, error) {
+	content, err := os.ReadFile(i.path)
+	if err != nil {
+		return nil, err
+	}
+	data := make(map[string]any)
+	if len(content) == 0 {
+		return data, nil
+	}
+	err = jsoniter.Unmarshal(content, &data)
+	return data, err
+}
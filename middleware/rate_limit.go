@@ -0,0 +1,294 @@
+package middleware
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"math"
+	"net"
+	"net/http"
+	"owl/cache"
+	contractcache "owl/contract/cache"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const rateLimitPrefix = "rate_limit:"
+
+var InvalidLimitError = errors.New("限流规则的 Max 和 Window 必须大于 0")
+
+// Limit 限流规则，Window 时间内最多 Max 次请求
+type Limit struct {
+	Max    int
+	Window time.Duration
+}
+
+// Valid Max 和 Window 都大于 0 时有效
+func (i Limit) Valid() bool {
+	return i.Max > 0 && i.Window > 0
+}
+
+func PerSecond(max int) Limit {
+	return Limit{Max: max, Window: time.Second}
+}
+
+func PerMinute(max int) Limit {
+	return Limit{Max: max, Window: time.Minute}
+}
+
+func PerHour(max int) Limit {
+	return Limit{Max: max, Window: time.Hour}
+}
+
+// LimitResult 一次限流判断的结果
+type LimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	ResetAt    time.Time     // 剩余次数恢复的时间
+	RetryAfter time.Duration // 被限流时需要等待的时间
+}
+
+// CacheLimiter 使用缓存存储计数的滑动窗口限流器
+// 计数保存在 contract\cache\store 中，多个节点使用同一个 redis、数据库等存储时共享限额。
+// 滑动窗口按上一个窗口的计数乘以剩余比例加上当前窗口的计数估算最近 Window 时间内的请求数
+type CacheLimiter struct {
+	store contractcache.Store
+	now   func() time.Time
+}
+
+func NewCacheLimiter(store contractcache.Store) *CacheLimiter {
+	return &CacheLimiter{
+		store: store,
+		now:   time.Now,
+	}
+}
+
+// Allow 记录一次 key 的请求并判断是否超出限制，超出限制的请求不计数
+// limit 无效时视为不限流，直接放行
+func (i *CacheLimiter) Allow(key string, limit Limit) LimitResult {
+	now := i.now()
+	if !limit.Valid() {
+		return LimitResult{Allowed: true, Limit: limit.Max, ResetAt: now}
+	}
+
+	window := limit.Window
+	index := now.UnixNano() / int64(window)
+	windowStart := time.Unix(0, index*int64(window))
+	elapsed := now.Sub(windowStart)
+	weight := 1 - float64(elapsed)/float64(window)
+
+	hashed := hashLimitKey(key)
+	currentKey := rateLimitPrefix + hashed + ":" + strconv.FormatInt(index, 10)
+	previousKey := rateLimitPrefix + hashed + ":" + strconv.FormatInt(index-1, 10)
+
+	previous, _ := toCount(i.store.Get(previousKey))
+	i.ensureCounter(currentKey, window)
+	current, ok := i.store.Increment(currentKey, 1)
+	if !ok {
+		// 存储不可用时放行，避免缓存故障导致所有请求被拒绝
+		return LimitResult{Allowed: true, Limit: limit.Max, Remaining: limit.Max, ResetAt: windowStart.Add(window)}
+	}
+
+	estimate := float64(previous)*weight + float64(current)
+	result := LimitResult{
+		Allowed: estimate <= float64(limit.Max),
+		Limit:   limit.Max,
+		ResetAt: windowStart.Add(window),
+	}
+	if result.Allowed {
+		result.Remaining = limit.Max - int(math.Ceil(estimate))
+		if result.Remaining < 0 {
+			result.Remaining = 0
+		}
+		return result
+	}
+
+	i.store.Decrement(currentKey, 1)
+	result.RetryAfter = retryAfter(limit, previous, current-1, elapsed)
+	result.ResetAt = now.Add(result.RetryAfter)
+	return result
+}
+
+// ensureCounter 计数器不存在时先写入带有效期的 0，保留两个窗口供下一个窗口估算使用
+func (i *CacheLimiter) ensureCounter(key string, window time.Duration) {
+	seconds := int(math.Ceil((2 * window).Seconds()))
+	if store, ok := i.store.(cache.AddStore); ok {
+		store.Add(key, 0, seconds)
+		return
+	}
+	if i.store.Get(key) == nil {
+		i.store.Put(key, 0, seconds)
+	}
+}
+
+// retryAfter 计算估算的请求数降到可以再放行一次请求需要等待的时间
+func retryAfter(limit Limit, previous, current int, elapsed time.Duration) time.Duration {
+	window := float64(limit.Window)
+	available := float64(limit.Max - current - 1)
+
+	if current < limit.Max && previous > 0 {
+		// 当前窗口内上一个窗口的权重降低到足够小
+		wait := window*(1-available/float64(previous)) - float64(elapsed)
+		if wait > 0 {
+			return time.Duration(wait)
+		}
+		return 0
+	}
+
+	// 当前窗口已满，需要等到下一个窗口中当前窗口的权重足够小
+	next := window - float64(elapsed)
+	if current > 0 {
+		next += window * (1 - float64(limit.Max-1)/float64(current))
+	}
+	return time.Duration(next)
+}
+
+func hashLimitKey(key string) string {
+	sum := sha1.Sum([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func toCount(value interface{}) (int, bool) {
+	switch v := value.(type) {
+	case int:
+		return v, true
+	case int64:
+		return int(v), true
+	case string:
+		n, err := strconv.Atoi(v)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// KeyFunc 返回请求的限流 key，返回空字符串时不限流
+type KeyFunc func(c *gin.Context) string
+
+// KeyByIP 按连接的客户端 IP 限流，不读取 X-Forwarded-For，客户端无法伪造
+// 服务在反向代理后面时使用 PolicyLimiter 并配置 trusted-proxies
+func KeyByIP() KeyFunc {
+	return keyByIP(nil)
+}
+
+// KeyByAPIKey 按请求头中的 API key 限流，没有 API key 的请求按连接的客户端 IP 限流
+func KeyByAPIKey(header string) KeyFunc {
+	return keyByAPIKey(header, nil)
+}
+
+func keyByIP(trusted []*net.IPNet) KeyFunc {
+	return func(c *gin.Context) string {
+		return "ip:" + clientIP(c, trusted)
+	}
+}
+
+func keyByAPIKey(header string, trusted []*net.IPNet) KeyFunc {
+	return func(c *gin.Context) string {
+		if key := c.GetHeader(header); key != "" {
+			return "key:" + key
+		}
+		return "ip:" + clientIP(c, trusted)
+	}
+}
+
+// clientIP 请求来自 trusted 中的代理时，从右往左取 X-Forwarded-For 中第一个不是可信代理的地址，
+// 否则使用连接的地址。不使用 c.ClientIP()，gin 默认信任所有代理，客户端可以伪造 X-Forwarded-For
+func clientIP(c *gin.Context, trusted []*net.IPNet) string {
+	remote := c.RemoteIP()
+	if !containsIP(trusted, net.ParseIP(remote)) {
+		return remote
+	}
+
+	forwarded := strings.Split(strings.Join(c.Request.Header.Values("X-Forwarded-For"), ","), ",")
+	for n := len(forwarded) - 1; n >= 0; n-- {
+		ip := net.ParseIP(strings.TrimSpace(forwarded[n]))
+		if ip == nil {
+			break
+		}
+		remote = ip.String()
+		if !containsIP(trusted, ip) {
+			break
+		}
+	}
+	return remote
+}
+
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// KeyByRoute 按路由限流，同一个路由的所有客户端共享限额
+func KeyByRoute() KeyFunc {
+	return func(c *gin.Context) string {
+		route := c.FullPath()
+		if route == "" {
+			route = c.Request.URL.Path
+		}
+		return "route:" + c.Request.Method + " " + route
+	}
+}
+
+// KeyBy 组合多个 KeyFunc，例如 KeyBy(KeyByRoute(), KeyByIP()) 表示每个客户端在每个路由上单独限流
+// 任意一个返回空字符串时不限流
+func KeyBy(keys ...KeyFunc) KeyFunc {
+	return func(c *gin.Context) string {
+		parts := make([]string, len(keys))
+		for n, key := range keys {
+			if parts[n] = key(c); parts[n] == "" {
+				return ""
+			}
+		}
+		return strings.Join(parts, "|")
+	}
+}
+
+// RateLimit 按 key 限流的 Gin 中间件，限额保存在 limiter 的缓存存储中
+// 响应中会写入 X-RateLimit-Limit、X-RateLimit-Remaining、X-RateLimit-Reset，被限流时写入 Retry-After
+//
+//	engine.Use(middleware.RateLimit(middleware.NewCacheLimiter(store), middleware.PerMinute(60), middleware.KeyByIP()))
+//
+// limit 无效时 panic，在注册路由时暴露错误
+func RateLimit(limiter *CacheLimiter, limit Limit, key KeyFunc) gin.HandlerFunc {
+	if !limit.Valid() {
+		panic(fmt.Errorf("%w: %+v", InvalidLimitError, limit))
+	}
+	return func(c *gin.Context) {
+		k := key(c)
+		if k == "" {
+			c.Next()
+			return
+		}
+
+		result := limiter.Allow(k, limit)
+		writeRateLimitHeaders(c, result)
+		if !result.Allowed {
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Too Many Requests"})
+			return
+		}
+		c.Next()
+	}
+}
+
+func writeRateLimitHeaders(c *gin.Context, result LimitResult) {
+	c.Header("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+	c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	c.Header("X-RateLimit-Reset", strconv.FormatInt(result.ResetAt.Unix(), 10))
+	if !result.Allowed {
+		seconds := int(math.Ceil(result.RetryAfter.Seconds()))
+		if seconds < 1 {
+			seconds = 1
+		}
+		c.Header("Retry-After", strconv.Itoa(seconds))
+	}
+}
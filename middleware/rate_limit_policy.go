@@ -0,0 +1,284 @@
+package middleware
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net"
+	"net/http"
+	"owl"
+	"owl/log"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 每条规则单独计数，key 决定同一条规则内如何区分客户端
+const (
+	LimitKeyIP     = "ip"      // 每个客户端 IP 单独计数
+	LimitKeyAPIKey = "api-key" // 按请求头中的 API key 计数，没有时按 IP
+	LimitKeyRoute  = "route"   // 所有客户端共享限额
+)
+
+// RateLimitPolicy 一条限流规则
+type RateLimitPolicy struct {
+	Route   string   `json:"route"`   // 路由，支持 path.Match 通配符，以 /* 结尾时匹配所有子路径
+	Methods []string `json:"methods"` // 请求方法，为空时匹配所有方法
+	Max     int      `json:"max"`     // 窗口内最多请求数
+	Window  string   `json:"window"`  // 窗口长度，例如 1s 1m 1h
+	Key     string   `json:"key"`     // ip api-key route，为空时使用全局配置
+}
+
+// RateLimitOptions conf/rate-limit.yml
+type RateLimitOptions struct {
+	Key          string   `json:"key"`            // 默认的限流 key，ip api-key route
+	APIKeyHeader string   `json:"api-key-header"` // key 为 api-key 时读取的请求头，默认 X-Api-Key
+	Exempt       []string `json:"exempt"`         // 不限流的客户端 IP 或 CIDR
+	// 可信的反向代理 IP 或 CIDR，只有来自这些地址的请求才读取 X-Forwarded-For，为空时使用连接的地址
+	TrustedProxies []string           `json:"trusted-proxies"`
+	Policies       []*RateLimitPolicy `json:"policies"` // 按顺序匹配，使用第一条匹配的规则
+}
+
+// NewRateLimitOption 读取限流配置，cfgFile 为 conf 目录下的文件名，例如 rate-limit 对应 conf/rate-limit.yml
+func NewRateLimitOption(cfgManager *owl.ConfManager, cfgFile string) (opt *RateLimitOptions) {
+	err := cfgManager.GetConfig(cfgFile, &opt)
+	if err != nil {
+		return nil
+	}
+	return opt
+}
+
+type compiledPolicy struct {
+	id      string
+	route   string
+	prefix  bool
+	methods map[string]bool
+	limit   Limit
+	key     KeyFunc
+}
+
+type compiledPolicies struct {
+	exempt   []*net.IPNet
+	trusted  []*net.IPNet
+	policies []*compiledPolicy
+}
+
+// PolicyLimiter 按配置文件中的规则限流，配置文件修改后自动生效
+type PolicyLimiter struct {
+	limiter     *CacheLimiter
+	lock        sync.RWMutex
+	current     *compiledPolicies
+	unsubscribe func()
+}
+
+func NewPolicyLimiter(limiter *CacheLimiter, opt *RateLimitOptions) (*PolicyLimiter, error) {
+	i := &PolicyLimiter{limiter: limiter}
+	if err := i.Update(opt); err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+// NewPolicyLimiterFromConfig 读取 conf/{cfgFile}.yml 中的规则，配置文件修改后使用新的规则，调用 Close 后不再监听
+func NewPolicyLimiterFromConfig(limiter *CacheLimiter, cfgManager *owl.ConfManager, cfgFile string) (*PolicyLimiter, error) {
+	opt := NewRateLimitOption(cfgManager, cfgFile)
+	if opt == nil {
+		return nil, fmt.Errorf("读取限流配置 [%s] 失败", cfgFile)
+	}
+
+	i, err := NewPolicyLimiter(limiter, opt)
+	if err != nil {
+		return nil, err
+	}
+	i.unsubscribe = owl.WatchConfig(cfgManager, cfgFile, func(old, new *RateLimitOptions) {
+		if err := i.Update(new); err != nil {
+			log.PrintLnRed("重载限流配置失败 ", cfgFile, err)
+		}
+	})
+	return i, nil
+}
+
+// Close 停止监听配置文件，之后继续使用当前的规则
+func (i *PolicyLimiter) Close() error {
+	if i.unsubscribe != nil {
+		i.unsubscribe()
+	}
+	return nil
+}
+
+// Update 替换规则，规则有错误时保留原来的规则
+func (i *PolicyLimiter) Update(opt *RateLimitOptions) error {
+	compiled, err := compilePolicies(opt)
+	if err != nil {
+		return err
+	}
+
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	i.current = compiled
+	return nil
+}
+
+// Handler 返回 Gin 中间件，没有匹配规则或客户端在白名单中时不限流
+func (i *PolicyLimiter) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		i.lock.RLock()
+		current := i.current
+		i.lock.RUnlock()
+
+		policy := current.match(c)
+		if policy == nil {
+			c.Next()
+			return
+		}
+		k := policy.key(c)
+		if k == "" {
+			c.Next()
+			return
+		}
+
+		result := i.limiter.Allow(policy.id+"|"+k, policy.limit)
+		writeRateLimitHeaders(c, result)
+		if !result.Allowed {
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Too Many Requests"})
+			return
+		}
+		c.Next()
+	}
+}
+
+func compilePolicies(opt *RateLimitOptions) (*compiledPolicies, error) {
+	compiled := &compiledPolicies{}
+	if opt == nil {
+		return compiled, nil
+	}
+
+	for _, exempt := range opt.Exempt {
+		network, err := parseCIDR(exempt)
+		if err != nil {
+			return nil, err
+		}
+		compiled.exempt = append(compiled.exempt, network)
+	}
+	for _, proxy := range opt.TrustedProxies {
+		network, err := parseCIDR(proxy)
+		if err != nil {
+			return nil, err
+		}
+		compiled.trusted = append(compiled.trusted, network)
+	}
+
+	header := opt.APIKeyHeader
+	if header == "" {
+		header = "X-Api-Key"
+	}
+
+	for n, policy := range opt.Policies {
+		if policy == nil || policy.Route == "" {
+			return nil, fmt.Errorf("第 %d 条限流规则没有配置 route", n+1)
+		}
+		if policy.Max <= 0 {
+			return nil, fmt.Errorf("限流规则 [%s] 的 max 必须大于 0", policy.Route)
+		}
+		window, err := time.ParseDuration(policy.Window)
+		if err != nil || window <= 0 {
+			return nil, fmt.Errorf("限流规则 [%s] 的 window [%s] 不是有效的时长", policy.Route, policy.Window)
+		}
+
+		keyName := policy.Key
+		if keyName == "" {
+			keyName = opt.Key
+		}
+		key, err := limitKeyFunc(keyName, header, compiled.trusted)
+		if err != nil {
+			return nil, fmt.Errorf("限流规则 [%s]: %w", policy.Route, err)
+		}
+		if _, err = path.Match(strings.TrimSuffix(policy.Route, "/*"), "/"); err != nil {
+			return nil, fmt.Errorf("限流规则 [%s] 的 route 格式错误: %w", policy.Route, err)
+		}
+
+		item := &compiledPolicy{
+			id:     fmt.Sprintf("%s %s", strings.Join(policy.Methods, ","), policy.Route),
+			route:  policy.Route,
+			limit:  Limit{Max: policy.Max, Window: window},
+			key:    key,
+			prefix: strings.HasSuffix(policy.Route, "/*"),
+		}
+		if item.prefix {
+			item.route = strings.TrimSuffix(policy.Route, "/*")
+		}
+		if len(policy.Methods) > 0 {
+			item.methods = make(map[string]bool, len(policy.Methods))
+			for _, method := range policy.Methods {
+				item.methods[strings.ToUpper(method)] = true
+			}
+		}
+		compiled.policies = append(compiled.policies, item)
+	}
+	return compiled, nil
+}
+
+func limitKeyFunc(name, header string, trusted []*net.IPNet) (KeyFunc, error) {
+	switch name {
+	case "", LimitKeyIP:
+		return keyByIP(trusted), nil
+	case LimitKeyAPIKey:
+		return keyByAPIKey(header, trusted), nil
+	case LimitKeyRoute:
+		return func(c *gin.Context) string {
+			return "route"
+		}, nil
+	}
+	return nil, fmt.Errorf("不支持的限流 key [%s]", name)
+}
+
+// parseCIDR 支持单个 IP，例如 127.0.0.1
+func parseCIDR(s string) (*net.IPNet, error) {
+	if !strings.Contains(s, "/") {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, fmt.Errorf("白名单 [%s] 不是有效的 IP", s)
+		}
+		bits := 128
+		if ip.To4() != nil {
+			ip = ip.To4()
+			bits = 32
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, network, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil, fmt.Errorf("白名单 [%s] 不是有效的 CIDR", s)
+	}
+	return network, nil
+}
+
+// match 返回第一条匹配的规则，客户端在白名单中时返回 nil
+func (i *compiledPolicies) match(c *gin.Context) *compiledPolicy {
+	if len(i.policies) == 0 {
+		return nil
+	}
+	if containsIP(i.exempt, net.ParseIP(clientIP(c, i.trusted))) {
+		return nil
+	}
+
+	requestPath := c.Request.URL.Path
+	fullPath := c.FullPath()
+	for _, policy := range i.policies {
+		if policy.methods != nil && !policy.methods[c.Request.Method] {
+			continue
+		}
+		if policy.matchPath(requestPath) || (fullPath != "" && policy.matchPath(fullPath)) {
+			return policy
+		}
+	}
+	return nil
+}
+
+func (i *compiledPolicy) matchPath(p string) bool {
+	if i.prefix {
+		return p == i.route || strings.HasPrefix(p, i.route+"/")
+	}
+	ok, _ := path.Match(i.route, p)
+	return ok
+}
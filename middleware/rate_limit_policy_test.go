@@ -0,0 +1,185 @@
+package middleware
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"owl"
+	"owl/cache"
+	"path/filepath"
+	"testing"
+)
+
+func newPolicyEngine(t *testing.T, opt *RateLimitOptions) (*gin.Engine, *PolicyLimiter) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	limiter, err := NewPolicyLimiter(NewCacheLimiter(cache.NewArrayStore("", 0)), opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	engine := gin.New()
+	engine.Use(limiter.Handler())
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	engine.POST("/api/login", ok)
+	engine.GET("/api/users/:id", ok)
+	engine.GET("/health", ok)
+	return engine, limiter
+}
+
+func serve(engine *gin.Engine, method, target, ip string) int {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(method, target, nil)
+	req.RemoteAddr = ip + ":1234"
+	engine.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestPolicyLimiterMatchesRoutes(t *testing.T) {
+	engine, _ := newPolicyEngine(t, &RateLimitOptions{
+		Exempt: []string{"10.0.0.0/8", "127.0.0.1"},
+		Policies: []*RateLimitPolicy{
+			{Route: "/api/login", Methods: []string{"post"}, Max: 1, Window: "1m"},
+			{Route: "/api/users/:id", Max: 2, Window: "1m", Key: LimitKeyRoute},
+		},
+	})
+
+	if serve(engine, http.MethodPost, "/api/login", "1.1.1.1") != http.StatusOK ||
+		serve(engine, http.MethodPost, "/api/login", "1.1.1.1") != http.StatusTooManyRequests {
+		t.Fatal("login should allow one request per client")
+	}
+	if serve(engine, http.MethodPost, "/api/login", "2.2.2.2") != http.StatusOK {
+		t.Fatal("login quota should be per client ip")
+	}
+	if serve(engine, http.MethodPost, "/api/login", "10.1.2.3") != http.StatusOK ||
+		serve(engine, http.MethodPost, "/api/login", "10.1.2.3") != http.StatusOK {
+		t.Fatal("exempt cidr should not be limited")
+	}
+
+	// gin 路由写法的规则匹配所有用户，key 为 route 时所有客户端共享限额
+	serve(engine, http.MethodGet, "/api/users/1", "1.1.1.1")
+	serve(engine, http.MethodGet, "/api/users/2", "2.2.2.2")
+	if serve(engine, http.MethodGet, "/api/users/3", "3.3.3.3") != http.StatusTooManyRequests {
+		t.Fatal("route key should share the quota between clients")
+	}
+
+	for n := 0; n < 5; n++ {
+		if serve(engine, http.MethodGet, "/health", "1.1.1.1") != http.StatusOK {
+			t.Fatal("unmatched routes should not be limited")
+		}
+	}
+}
+
+func TestPolicyLimiterIgnoresSpoofedForwardedFor(t *testing.T) {
+	opt := &RateLimitOptions{
+		Exempt:   []string{"127.0.0.1", "10.0.0.0/8"},
+		Policies: []*RateLimitPolicy{{Route: "/api/login", Max: 1, Window: "1m"}},
+	}
+	// gin.Default() 默认信任所有代理，c.ClientIP() 会使用伪造的 X-Forwarded-For
+	engine, _ := newPolicyEngine(t, opt)
+	spoofed := func(remote, forwarded string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/login", nil)
+		req.RemoteAddr = remote + ":1234"
+		req.Header.Set("X-Forwarded-For", forwarded)
+		engine.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if spoofed("1.1.1.1", "127.0.0.1") != http.StatusOK ||
+		spoofed("1.1.1.1", "127.0.0.1") != http.StatusTooManyRequests {
+		t.Fatal("spoofed exempt ip should not skip the limit")
+	}
+	if spoofed("2.2.2.2", "3.3.3.1") != http.StatusOK ||
+		spoofed("2.2.2.2", "3.3.3.2") != http.StatusTooManyRequests {
+		t.Fatal("rotating X-Forwarded-For should not get a new quota")
+	}
+
+	// 来自可信代理的请求使用 X-Forwarded-For 中最右边的非代理地址
+	opt.TrustedProxies = []string{"192.168.0.0/16"}
+	engine, _ = newPolicyEngine(t, opt)
+	if spoofed("192.168.1.1", "4.4.4.4, 10.0.0.1") != http.StatusOK ||
+		spoofed("192.168.1.1", "4.4.4.4, 10.0.0.1") != http.StatusOK {
+		t.Fatal("client behind a trusted proxy should use the forwarded ip")
+	}
+	if spoofed("192.168.1.1", "5.5.5.5") != http.StatusOK ||
+		spoofed("192.168.1.1", "6.6.6.6, 5.5.5.5") != http.StatusTooManyRequests {
+		t.Fatal("only the hop appended by the trusted proxy should be used")
+	}
+}
+
+func TestPolicyLimiterUpdate(t *testing.T) {
+	engine, limiter := newPolicyEngine(t, &RateLimitOptions{
+		Policies: []*RateLimitPolicy{{Route: "/api/*", Max: 1, Window: "1m"}},
+	})
+	serve(engine, http.MethodGet, "/api/users/1", "1.1.1.1")
+	if serve(engine, http.MethodGet, "/api/users/1", "1.1.1.1") != http.StatusTooManyRequests {
+		t.Fatal("prefix rule should limit nested paths")
+	}
+
+	if err := limiter.Update(&RateLimitOptions{
+		Policies: []*RateLimitPolicy{{Route: "/api/*", Max: 100, Window: "1m"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if serve(engine, http.MethodGet, "/api/users/1", "1.1.1.1") != http.StatusOK {
+		t.Fatal("updated policy should apply")
+	}
+
+	err := limiter.Update(&RateLimitOptions{Policies: []*RateLimitPolicy{{Route: "/api/*", Max: 1, Window: "soon"}}})
+	if err == nil {
+		t.Fatal("invalid window should be rejected")
+	}
+	if serve(engine, http.MethodGet, "/api/users/1", "1.1.1.1") != http.StatusOK {
+		t.Fatal("invalid update should keep the previous policies")
+	}
+	if _, err = NewPolicyLimiter(nil, &RateLimitOptions{Exempt: []string{"not-an-ip"}}); err == nil {
+		t.Fatal("invalid exempt entry should be rejected")
+	}
+}
+
+func TestPolicyLimiterReloadsConfig(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dir := t.TempDir()
+	file := filepath.Join(dir, "rate-limit.yml")
+	write := func(max int) {
+		content := fmt.Sprintf("policies:\n  - route: /api/*\n    max: %d\n    window: 1m\n", max)
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(1)
+
+	cfgManager := owl.NewConfigManagerFromDir(dir, nil)
+	limiter, err := NewPolicyLimiterFromConfig(NewCacheLimiter(cache.NewArrayStore("", 0)), cfgManager, "rate-limit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine := gin.New()
+	engine.Use(limiter.Handler())
+	engine.GET("/api/users/:id", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	serve(engine, http.MethodGet, "/api/users/1", "1.1.1.1")
+	if serve(engine, http.MethodGet, "/api/users/1", "1.1.1.1") != http.StatusTooManyRequests {
+		t.Fatal("initial policy should apply")
+	}
+
+	write(100)
+	if err = cfgManager.ReloadConfig("rate-limit"); err != nil {
+		t.Fatal(err)
+	}
+	if serve(engine, http.MethodGet, "/api/users/1", "1.1.1.1") != http.StatusOK {
+		t.Fatal("reloaded policy should apply")
+	}
+
+	_ = limiter.Close()
+	write(1)
+	if err = cfgManager.ReloadConfig("rate-limit"); err != nil {
+		t.Fatal(err)
+	}
+	if serve(engine, http.MethodGet, "/api/users/1", "1.1.1.1") != http.StatusOK {
+		t.Fatal("closed limiter should keep its policy")
+	}
+}
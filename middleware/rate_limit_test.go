@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"owl/cache"
+	"testing"
+	"time"
+)
+
+func TestCacheLimiterSlidingWindow(t *testing.T) {
+	limiter := NewCacheLimiter(cache.NewArrayStore("", 0))
+	now := time.Unix(1700000040, 0) // 窗口的起点
+	limiter.now = func() time.Time { return now }
+	limit := PerMinute(3)
+
+	for n := 3; n > 0; n-- {
+		result := limiter.Allow("client", limit)
+		if !result.Allowed || result.Remaining != n-1 {
+			t.Fatalf("Allow() = %+v, want allowed with %d remaining", result, n-1)
+		}
+	}
+	denied := limiter.Allow("client", limit)
+	if denied.Allowed || denied.RetryAfter <= 0 {
+		t.Fatalf("4th request = %+v, want denied", denied)
+	}
+	if !limiter.Allow("other", limit).Allowed {
+		t.Fatal("other keys should have their own quota")
+	}
+
+	// 下一个窗口刚开始时，上一个窗口的 3 次请求几乎全部计入
+	now = now.Add(time.Minute)
+	if limiter.Allow("client", limit).Allowed {
+		t.Fatal("previous window should still count at the start of the next window")
+	}
+
+	// 过了大半个窗口后上一个窗口的权重降低，可以再请求
+	now = now.Add(45 * time.Second)
+	if result := limiter.Allow("client", limit); !result.Allowed {
+		t.Fatalf("Allow() after the window slides = %+v", result)
+	}
+}
+
+func TestCacheLimiterInvalidLimit(t *testing.T) {
+	limiter := NewCacheLimiter(cache.NewArrayStore("", 0))
+	for _, limit := range []Limit{{Max: 1}, {Window: time.Second}, {Max: -1, Window: -time.Second}} {
+		if result := limiter.Allow("client", limit); !result.Allowed {
+			t.Fatalf("Allow(%+v) = %+v, want no limit", limit, result)
+		}
+	}
+
+	defer func() {
+		if err, _ := recover().(error); !errors.Is(err, InvalidLimitError) {
+			t.Fatalf("RateLimit() with zero window panic = %v", err)
+		}
+	}()
+	RateLimit(limiter, Limit{Max: 1}, KeyByIP())
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(RateLimit(NewCacheLimiter(cache.NewArrayStore("", 0)), PerMinute(2), KeyByAPIKey("X-Api-Key")))
+	engine.GET("/ping", func(c *gin.Context) {
+		c.String(http.StatusOK, "pong")
+	})
+
+	request := func(apiKey string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+		req.Header.Set("X-Api-Key", apiKey)
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := request("a"); w.Code != http.StatusOK || w.Header().Get("X-RateLimit-Limit") != "2" || w.Header().Get("X-RateLimit-Remaining") != "1" {
+		t.Fatalf("first response = %d %v", w.Code, w.Header())
+	}
+	request("a")
+	w := request("a")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" || w.Header().Get("X-RateLimit-Remaining") != "0" {
+		t.Fatalf("limited response = %d %v", w.Code, w.Header())
+	}
+	if w = request("b"); w.Code != http.StatusOK {
+		t.Fatalf("other api key = %d, want 200", w.Code)
+	}
+}
+
+func TestKeyBy(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/users", nil)
+	c.Request.RemoteAddr = "10.0.0.1:1234"
+
+	if key := KeyBy(KeyByRoute(), KeyByIP())(c); key != "route:POST /users|ip:10.0.0.1" {
+		t.Fatalf("KeyBy() = %q", key)
+	}
+	skip := func(c *gin.Context) string { return "" }
+	if key := KeyBy(KeyByIP(), skip)(c); key != "" {
+		t.Fatalf("KeyBy() with an empty part = %q, want empty", key)
+	}
+}
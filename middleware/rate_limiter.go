@@ -51,6 +51,7 @@ func (tb *TokenBucket) Take() bool {
 }
 
 // RateLimiter 是一个 Gin 中间件，用于实现限流
+// 所有客户端共享一个进程内的令牌桶，需要按客户端限流或多个节点共享限额时使用 RateLimit
 func RateLimiter(capacity int, refillRate time.Duration) gin.HandlerFunc {
 	tb := NewTokenBucket(capacity, refillRate)
 
@@ -1,9 +1,11 @@
 package queue
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/golang-module/carbon"
+	"github.com/google/uuid"
 	"owl"
 	"owl/contract"
 	"sync"
@@ -16,12 +18,17 @@ var (
 	connections = make(map[string]*amqp.Connection, 10)
 	linkMap     = make(map[string]*link, 10)
 	lock        sync.Mutex
+	closeHooks  sync.Map // 已添加 CloseAll 停止钩子的 stage
 )
 
 type link struct {
 	dsn string
 }
 
+func init() {
+	owl.RegisterConfigSchema[Options]("rabbit") // config:check 校验 conf/rabbit
+}
+
 type Options struct {
 	contract.ServerConfig
 	Vhost   string `json:"vhost"`
@@ -46,12 +53,37 @@ type RabbitMQ struct {
 	queue        string
 	routingKey   string
 	closeNotify  chan *amqp.Error
+
+	stop      chan struct{} // Shutdown 时关闭
+	stopOnce  sync.Once
+	consumers sync.WaitGroup // 正在运行的消费者
+	handlers  sync.WaitGroup // 正在处理的消息
+}
+
+// NewRabbitFromConfig 使用 conf/rabbit 中的配置，配置修改后自动重连
+// 使用 NewRabbit 创建的实例不会监听配置，需要时调用 Watch
+func NewRabbitFromConfig(cfgManager *owl.ConfManager, l *owl.LoggerFactory) *RabbitMQ {
+	i := NewRabbit(NewOption(cfgManager), l)
+	i.Watch(cfgManager, i.opt.CfgFile)
+	return i
+}
+
+// NewRabbitFromStage 同 NewRabbitFromConfig，应用停止时先停止消费者，所有实例停止之后再关闭连接
+func NewRabbitFromStage(stage *owl.Stage, cfgManager *owl.ConfManager, l *owl.LoggerFactory) *RabbitMQ {
+	// 停止钩子按相反顺序执行，CloseAll 最先添加，最后执行
+	if _, loaded := closeHooks.LoadOrStore(stage, struct{}{}); !loaded {
+		_ = stage.Lifecycle().OnStop("rabbit:connections", CloseAll)
+	}
+	i := NewRabbitFromConfig(cfgManager, l)
+	_ = stage.Lifecycle().OnStop("rabbit", i.Shutdown)
+	return i
 }
 
 func NewRabbit(opt *Options, l *owl.LoggerFactory) *RabbitMQ {
 	r := &RabbitMQ{
-		opt: opt,
-		l:   l.RuntimeLogger(),
+		opt:  opt,
+		l:    l.RuntimeLogger(),
+		stop: make(chan struct{}),
 	}
 	return r
 }
@@ -64,29 +96,8 @@ func (i *RabbitMQ) Connect() *amqp.Connection {
 
 	dbLinkInfo, ok := linkMap[i.opt.CfgFile] // 申请连接过
 	if !ok {
-
-		dsn := fmt.Sprintf("amqp://%s:%s@%s:%d/%s",
-			i.opt.Username,
-			i.opt.Password,
-			i.opt.Host,
-			i.opt.Port,
-			i.opt.Vhost,
-		)
-		linkMap[i.opt.CfgFile] = &link{dsn: dsn}
+		linkMap[i.opt.CfgFile] = &link{dsn: dsnOf(i.opt)}
 		dbLinkInfo = linkMap[i.opt.CfgFile]
-		go func() {
-			for {
-				select {
-				case file := <-owl.CfgChangeNotify[i.opt.AbsPath]:
-					lock.Lock()
-					i.l.Info("重载配置" + file)
-					delete(linkMap, i.opt.CfgFile)
-					delete(connections, dsn)
-					lock.Unlock()
-					return
-				}
-			}
-		}()
 	}
 
 	dsn := dbLinkInfo.dsn
@@ -107,6 +118,45 @@ func (i *RabbitMQ) Connect() *amqp.Connection {
 	}
 }
 
+func dsnOf(opt *Options) string {
+	return fmt.Sprintf("amqp://%s:%s@%s:%d/%s",
+		opt.Username,
+		opt.Password,
+		opt.Host,
+		opt.Port,
+		opt.Vhost,
+	)
+}
+
+// Watch 监听配置 key，例如 rabbit，配置修改后使用新的配置重连，返回的函数用于取消监听
+func (i *RabbitMQ) Watch(cfgManager *owl.ConfManager, key string) func() {
+	return owl.WatchConfig(cfgManager, key, func(old, new *Options) {
+		i.Reload(new)
+	})
+}
+
+// Reload 使用新的配置并关闭旧的连接，消费者收到连接关闭后使用新的配置重连，
+// 使用同一个配置文件的其他实例也会连接到新的地址
+func (i *RabbitMQ) Reload(opt *Options) {
+	lock.Lock()
+	if opt.CfgFile == "" {
+		opt.CfgFile = i.opt.CfgFile
+	}
+	var old *amqp.Connection
+	if dbLinkInfo, ok := linkMap[opt.CfgFile]; ok {
+		old = connections[dbLinkInfo.dsn]
+		delete(connections, dbLinkInfo.dsn)
+	}
+	linkMap[opt.CfgFile] = &link{dsn: dsnOf(opt)}
+	i.opt = opt
+	lock.Unlock()
+
+	if old != nil && !old.IsClosed() {
+		_ = old.Close()
+	}
+	i.l.Info("重载配置" + opt.CfgFile)
+}
+
 func (i *RabbitMQ) Queue(queue string) *RabbitMQ {
 	i.queue = queue
 	return i
@@ -165,22 +215,27 @@ func (i *RabbitMQ) Publish(message []byte) error {
 	return err
 }
 
-// Consume 使用消费者处理消息
+// Consume 使用消费者处理消息，Shutdown 后不再接收新的消息
 func (i *RabbitMQ) Consume(prefetchCount int, handler func(data string, msg amqp.Delivery, i *int32)) {
 
+	i.consumers.Add(1)
 	go func() {
+		defer i.consumers.Done()
 	ReConsume:
+		if i.stopped() {
+			return
+		}
 		con := i.Connect()
 
 		if con == nil {
-			time.Sleep(time.Second * 3)
+			i.sleep(time.Second * 3)
 			fmt.Println("消费者重连中")
 			goto ReConsume
 		}
 
 		err := i.newQueue()
 		if err != nil {
-			time.Sleep(time.Second * 3)
+			i.sleep(time.Second * 3)
 			goto ReConsume
 		}
 
@@ -192,7 +247,7 @@ func (i *RabbitMQ) Consume(prefetchCount int, handler func(data string, msg amqp
 		i.l.Debug("获取 channle end")
 		if err != nil {
 			i.l.Debug("获取通道失败")
-			time.Sleep(time.Second * 3)
+			i.sleep(time.Second * 3)
 			goto ReConsume
 		}
 
@@ -200,9 +255,10 @@ func (i *RabbitMQ) Consume(prefetchCount int, handler func(data string, msg amqp
 		if err != nil {
 			goto ReConsume
 		}
+		tag := "owl-" + uuid.NewString() // 停止时按标签取消消费者
 		msgs, err := ch.Consume(
 			i.queue, // 队列名称
-			"",      // 消费者标签
+			tag,     // 消费者标签
 			false,   // 自动应答
 			false,   // 排他性
 			false,   // 不等待
@@ -217,6 +273,13 @@ func (i *RabbitMQ) Consume(prefetchCount int, handler func(data string, msg amqp
 		var exitFor bool
 		for {
 			select {
+			case <-i.stop:
+				// 先取消消费者不再接收消息，等处理中的消息应答后再关闭通道，未应答的消息回到队列
+				_ = ch.Cancel(tag, false)
+				i.handlers.Wait()
+				ch.Close()
+				i.l.Debug("消费者已停止")
+				return
 			case _, ok := <-conClose:
 				if !ok {
 					i.l.Debug("通道关闭")
@@ -231,7 +294,11 @@ func (i *RabbitMQ) Consume(prefetchCount int, handler func(data string, msg amqp
 					break
 				}
 
-				go handler(string(msg.Body), msg, &running)
+				i.handlers.Add(1)
+				go func() {
+					defer i.handlers.Done()
+					handler(string(msg.Body), msg, &running)
+				}()
 
 			default:
 				i.l.Debug("消费者获取数据中", carbon.Now().ToDateTimeString())
@@ -243,3 +310,63 @@ func (i *RabbitMQ) Consume(prefetchCount int, handler func(data string, msg amqp
 		}
 	}()
 }
+
+// Shutdown 停止所有消费者，等待处理中的消息处理完成，ctx 超时时返回错误
+// 使用 NewRabbitFromStage 创建时应用停止时自动调用
+func (i *RabbitMQ) Shutdown(ctx context.Context) error {
+	i.stopOnce.Do(func() {
+		close(i.stop)
+	})
+
+	done := make(chan struct{})
+	go func() {
+		i.consumers.Wait()
+		i.handlers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// CloseAll 关闭所有 rabbit 连接，在所有消费者 Shutdown 之后调用
+func CloseAll(ctx context.Context) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	var errs []error
+	for dsn, con := range connections {
+		delete(connections, dsn)
+		if con == nil || con.IsClosed() {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return errors.Join(append(errs, err)...)
+		}
+		if err := con.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (i *RabbitMQ) stopped() bool {
+	select {
+	case <-i.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// sleep 等待 d，Shutdown 时提前返回
+func (i *RabbitMQ) sleep(d time.Duration) {
+	select {
+	case <-i.stop:
+	case <-time.After(d):
+	}
+}
@@ -0,0 +1,229 @@
+package owl
+
+import (
+	"fmt"
+	"go.uber.org/dig"
+	"owl/contract"
+	"owl/log"
+	"reflect"
+	"time"
+)
+
+// ProviderTiming 服务提供者各阶段耗时
+type ProviderTiming struct {
+	Name     string
+	Register time.Duration
+	Boot     time.Duration
+	Deferred bool // 是否为延迟加载
+}
+
+// Register 注册服务提供者，先执行所有提供者的 Register，再按顺序执行 Boot
+// 实现了 contract.DeferrableProvider 的提供者在 Provides 中的类型第一次被 Invoke 时才注册和启动
+func (i *Stage) Register(providers ...contract.ServiceProvider) {
+	var eager []contract.ServiceProvider
+	var durations []time.Duration
+
+	for _, provider := range providers {
+		if deferrable, ok := provider.(contract.DeferrableProvider); ok {
+			i.deferProvider(deferrable)
+			continue
+		}
+		eager = append(eager, provider)
+		durations = append(durations, measure(provider.Register))
+	}
+
+	for n, provider := range eager {
+		i.bootProvider(providerName(provider), provider, durations[n], false)
+	}
+}
+
+// ProviderTimings 获取已启动的服务提供者的耗时，按启动顺序
+func (i *Stage) ProviderTimings() []ProviderTiming {
+	i.providerLock.Lock()
+	defer i.providerLock.Unlock()
+	return append([]ProviderTiming(nil), i.timings...)
+}
+
+// Provide 注册构造函数，并记录它返回的类型依赖哪些类型，Invoke 时据此加载间接依赖的延迟服务提供者
+// 直接调用 stage.Container.Provide 或使用 dig.As 提供的接口类型不会被记录
+func (i *Stage) Provide(constructor any, opts ...dig.ProvideOption) error {
+	if err := i.Container.Provide(constructor, opts...); err != nil {
+		return err
+	}
+
+	t := reflect.TypeOf(constructor)
+	var in []reflect.Type
+	for n := 0; n < t.NumIn(); n++ {
+		in = append(in, expandIn(t.In(n))...)
+	}
+
+	i.providerLock.Lock()
+	defer i.providerLock.Unlock()
+	if i.dependencies == nil {
+		i.dependencies = make(map[reflect.Type][]reflect.Type)
+	}
+	for n := 0; n < t.NumOut(); n++ {
+		for _, out := range expandOut(t.Out(n)) {
+			i.dependencies[out] = append(i.dependencies[out], in...)
+		}
+	}
+	return nil
+}
+
+// Invoke 调用 function 前按参数类型遍历 Provide 记录的依赖，加载直接和间接依赖的延迟服务提供者
+// 直接调用 stage.Container.Invoke 不会加载延迟服务提供者
+func (i *Stage) Invoke(function any, opts ...dig.InvokeOption) error {
+	if t := reflect.TypeOf(function); t != nil && t.Kind() == reflect.Func {
+		visited := make(map[reflect.Type]bool)
+		for n := 0; n < t.NumIn(); n++ {
+			for _, in := range expandIn(t.In(n)) {
+				i.loadDependencies(in, visited)
+			}
+		}
+	}
+	return i.Container.Invoke(function, opts...)
+}
+
+// loadDependencies 加载 t 和它的构造函数依赖的类型对应的延迟服务提供者，
+// 先加载 t 的提供者，它在 Register 中提供的构造函数的依赖也会被遍历
+func (i *Stage) loadDependencies(t reflect.Type, visited map[reflect.Type]bool) {
+	if visited[t] {
+		return
+	}
+	visited[t] = true
+	i.loadDeferred(t)
+
+	i.providerLock.Lock()
+	dependencies := i.dependencies[t]
+	i.providerLock.Unlock()
+
+	for _, dependency := range dependencies {
+		i.loadDependencies(dependency, visited)
+	}
+}
+
+// expandIn 参数为 dig.In 结构体时返回其字段的类型
+func expandIn(t reflect.Type) []reflect.Type {
+	if !dig.IsIn(t) {
+		return []reflect.Type{t}
+	}
+	var types []reflect.Type
+	for n := 0; n < t.NumField(); n++ {
+		if field := t.Field(n); field.Type != inType && field.IsExported() {
+			types = append(types, expandIn(field.Type)...)
+		}
+	}
+	return types
+}
+
+// expandOut 返回值为 dig.Out 结构体时返回其字段的类型，忽略 error
+func expandOut(t reflect.Type) []reflect.Type {
+	if t == errorType {
+		return nil
+	}
+	if !dig.IsOut(t) {
+		return []reflect.Type{t}
+	}
+	var types []reflect.Type
+	for n := 0; n < t.NumField(); n++ {
+		if field := t.Field(n); field.Type != outType && field.IsExported() {
+			types = append(types, expandOut(field.Type)...)
+		}
+	}
+	return types
+}
+
+func (i *Stage) deferProvider(provider contract.DeferrableProvider) {
+	i.providerLock.Lock()
+	defer i.providerLock.Unlock()
+
+	if i.deferred == nil {
+		i.deferred = make(map[reflect.Type]contract.DeferrableProvider)
+	}
+	for _, service := range provider.Provides() {
+		t := reflect.TypeOf(service)
+		// 接口类型通过 (*Interface)(nil) 声明
+		if t.Kind() == reflect.Pointer && t.Elem().Kind() == reflect.Interface {
+			t = t.Elem()
+		}
+		i.deferred[t] = provider
+	}
+}
+
+// loadAllDeferred 加载所有还没有加载的延迟服务提供者
+func (i *Stage) loadAllDeferred() {
+	i.providerLock.Lock()
+	types := make([]reflect.Type, 0, len(i.deferred))
+	for t := range i.deferred {
+		types = append(types, t)
+	}
+	i.providerLock.Unlock()
+
+	for _, t := range types {
+		i.loadDeferred(t)
+	}
+}
+
+// isDeferred provider 是否还在等待加载
+func (i *Stage) isDeferred(provider contract.ServiceProvider) bool {
+	i.providerLock.Lock()
+	defer i.providerLock.Unlock()
+
+	for _, p := range i.deferred {
+		if contract.ServiceProvider(p) == provider {
+			return true
+		}
+	}
+	return false
+}
+
+// loadDeferred 注册并启动提供 t 的延迟服务提供者，每个提供者只加载一次
+func (i *Stage) loadDeferred(t reflect.Type) {
+	i.providerLock.Lock()
+	provider, ok := i.deferred[t]
+	if ok {
+		// 加载前移除，避免提供者在 Register Boot 中获取自己提供的类型时重复加载
+		for service, p := range i.deferred {
+			if p == provider {
+				delete(i.deferred, service)
+			}
+		}
+	}
+	i.providerLock.Unlock()
+
+	if !ok {
+		return
+	}
+	i.bootProvider(providerName(provider), provider, measure(provider.Register), true)
+}
+
+// bootProvider 启动服务提供者并记录耗时
+func (i *Stage) bootProvider(name string, provider contract.ServiceProvider, register time.Duration, deferred bool) {
+	timing := ProviderTiming{
+		Name:     name,
+		Register: register,
+		Boot:     measure(provider.Boot),
+		Deferred: deferred,
+	}
+
+	i.providerLock.Lock()
+	i.timings = append(i.timings, timing)
+	i.providerLock.Unlock()
+
+	log.PrintLnBlue(fmt.Sprintf("服务提供者 [%s] 注册 %s 启动 %s", timing.Name, timing.Register, timing.Boot))
+}
+
+var (
+	inType  = reflect.TypeOf(dig.In{})
+	outType = reflect.TypeOf(dig.Out{})
+)
+
+func providerName(provider contract.ServiceProvider) string {
+	return reflect.TypeOf(provider).String()
+}
+
+func measure(fn func()) time.Duration {
+	start := time.Now()
+	fn()
+	return time.Since(start)
+}
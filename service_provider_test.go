@@ -0,0 +1,117 @@
+package owl
+
+import (
+	"go.uber.org/dig"
+	"reflect"
+	"testing"
+)
+
+type lazyService struct{ name string }
+
+type lazyProvider struct {
+	stage  *Stage
+	events *[]string
+}
+
+func (i *lazyProvider) Register() {
+	*i.events = append(*i.events, "register:lazy")
+	_ = i.stage.Provide(func() *lazyService { return &lazyService{name: "lazy"} })
+}
+
+func (i *lazyProvider) Boot() {
+	// 启动时获取自己提供的类型不会重复加载
+	_ = i.stage.Invoke(func(*lazyService) {})
+	*i.events = append(*i.events, "boot:lazy")
+}
+
+func (i *lazyProvider) Provides() []any {
+	return []any{(*lazyService)(nil)}
+}
+
+type lazyConsumer struct{ service *lazyService }
+
+func TestStageLoadsTransitiveDeferredProvider(t *testing.T) {
+	stage := &Stage{Container: dig.New()}
+	var events []string
+
+	stage.Register(&lazyProvider{stage: stage, events: &events})
+	_ = stage.Provide(func(service *lazyService) *lazyConsumer {
+		return &lazyConsumer{service: service}
+	})
+
+	// *lazyConsumer 不是延迟类型，*lazyService 只在它的构造函数中间接依赖
+	var got *lazyConsumer
+	if err := stage.Invoke(func(consumer *lazyConsumer) { got = consumer }); err != nil {
+		t.Fatal(err)
+	}
+	if got == nil || got.service.name != "lazy" {
+		t.Fatalf("consumer = %+v", got)
+	}
+	if want := []string{"register:lazy", "boot:lazy"}; !reflect.DeepEqual(events, want) {
+		t.Fatalf("events = %v, want %v", events, want)
+	}
+
+	type missing struct{}
+	if err := stage.Invoke(func(*missing) {}); err == nil {
+		t.Fatal("types without a provider should still fail")
+	}
+}
+
+func TestStageRegisterRunsAllRegisterBeforeBoot(t *testing.T) {
+	stage := &Stage{Container: dig.New()}
+	var events []string
+
+	stage.Register(
+		&testProvider{name: "a", events: &events},
+		&lazyProvider{stage: stage, events: &events},
+		&testProvider{name: "b", events: &events},
+	)
+
+	want := []string{"register:a", "register:b", "boot:a", "boot:b"}
+	if !reflect.DeepEqual(events, want) {
+		t.Fatalf("events = %v, want %v", events, want)
+	}
+
+	type params struct {
+		dig.In
+		Service *lazyService
+	}
+	var got *lazyService
+	if err := stage.Invoke(func(p params) { got = p.Service }); err != nil {
+		t.Fatal(err)
+	}
+	if got == nil || got.name != "lazy" {
+		t.Fatalf("lazy service = %v", got)
+	}
+	if err := stage.Invoke(func(*lazyService) {}); err != nil {
+		t.Fatal(err)
+	}
+
+	want = append(want, "register:lazy", "boot:lazy")
+	if !reflect.DeepEqual(events, want) {
+		t.Fatalf("events = %v, want %v", events, want)
+	}
+
+	timings := stage.ProviderTimings()
+	if len(timings) != 3 || timings[0].Name != "*owl.testProvider" || !timings[2].Deferred {
+		t.Fatalf("timings = %+v", timings)
+	}
+}
+
+type lazyServiceConfig struct{}
+
+func TestStageDoesNotLoadProvidersForSimilarTypeNames(t *testing.T) {
+	stage := &Stage{Container: dig.New()}
+	var events []string
+
+	stage.Register(&lazyProvider{stage: stage, events: &events})
+	_ = stage.Provide(func(*lazyServiceConfig) *lazyConsumer { return &lazyConsumer{} })
+
+	// *owl.lazyServiceConfig 的名称包含 *owl.lazyService，但不是它提供的类型
+	if err := stage.Invoke(func(*lazyConsumer) {}); err == nil {
+		t.Fatal("missing *lazyServiceConfig should fail")
+	}
+	if len(events) != 0 {
+		t.Fatalf("unrelated deferred provider was loaded: %v", events)
+	}
+}
@@ -6,14 +6,18 @@ import (
 	"go.uber.org/dig"
 	_ "net/http/pprof"
 	"os"
+	"owl/contract"
 	"owl/utils/file"
 	"path/filepath"
+	"reflect"
+	"sync"
 )
 
 const (
 	StoragePath   = "storage"
 	ConfPath      = "conf"
 	LogsPath      = StoragePath + "/logs"
+	CachePath     = StoragePath + "/framework/cache"
 	ResourcesPath = "resource"
 	ViewsPath     = ResourcesPath + "/views"
 )
@@ -30,9 +34,38 @@ type Stage struct {
 	*dig.Container
 	runDir string // 运行程序的目录
 	binDir string // 程序所在目录
+
+	providerLock sync.Mutex
+	deferred     map[reflect.Type]contract.DeferrableProvider // 类型 -> 延迟的服务提供者
+	dependencies map[reflect.Type][]reflect.Type              // 类型 -> 构造函数的参数类型
+	timings      []ProviderTiming
+	lifecycle    *Lifecycle
 }
 
 func New() *Stage {
+	stage := newStage()
+
+	_ = stage.Provide(func() *gin.Engine {
+		e := gin.Default()
+		e.Static(ResourcesPath, stage.ResourcePath())
+		return e
+	})
+
+	_ = stage.Provide(stage)
+	_ = stage.Provide(NewLoggerFactory)
+	_ = stage.Provide(NewConfigManager)
+
+	configAbsPath := stage.RuntimePath("conf")
+	file.CreateDirIfNotExists(configAbsPath)
+
+	dataAbsPath := stage.RuntimePath("resource")
+	file.CreateDirIfNotExists(dataAbsPath)
+
+	return stage
+}
+
+// newStage 只计算运行目录和程序所在目录，不注册依赖也不创建目录
+func newStage() *Stage {
 
 	var err error
 	runDir, err := os.Getwd()
@@ -48,29 +81,21 @@ func New() *Stage {
 
 	binDir := filepath.Dir(exePath)
 
-	stage := &Stage{
+	return &Stage{
 		Container: dig.New(),
 		runDir:    file.NormalizedPath(runDir),
 		binDir:    file.NormalizedPath(binDir),
 	}
+}
 
-	_ = stage.Provide(func() *gin.Engine {
-		e := gin.Default()
-		e.Static(ResourcesPath, stage.ResourcePath())
-		return e
-	})
-
-	_ = stage.Provide(stage)
-	_ = stage.Provide(NewLoggerFactory)
-	_ = stage.Provide(NewConfigManager)
-
-	configAbsPath := stage.RuntimePath("conf")
-	file.CreateDirIfNotExists(configAbsPath)
-
-	dataAbsPath := stage.RuntimePath("resource")
-	file.CreateDirIfNotExists(dataAbsPath)
-
-	return stage
+// Lifecycle 服务的启动和停止钩子，App 停止时按相反顺序停止
+func (i *Stage) Lifecycle() *Lifecycle {
+	i.providerLock.Lock()
+	defer i.providerLock.Unlock()
+	if i.lifecycle == nil {
+		i.lifecycle = NewLifecycle()
+	}
+	return i.lifecycle
 }
 
 // AbsRunDir 获取运行程序的目录
@@ -105,6 +130,11 @@ func (i *Stage) LogPath() string {
 	return i.RuntimePath(LogsPath)
 }
 
+// CachePath 文件缓存所在目录
+func (i *Stage) CachePath() string {
+	return i.RuntimePath(CachePath)
+}
+
 // AbsBinDir 获取程序所在目录
 func (i *Stage) AbsBinDir() string {
 	return i.binDir
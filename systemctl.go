@@ -1,12 +1,15 @@
 package owl
 
 import (
+	"context"
 	"fmt"
 	"github.com/kardianos/service"
 	"github.com/spf13/cobra"
+	"os"
 	"os/exec"
 	"owl/log"
 	"runtime"
+	"time"
 )
 
 /*
@@ -75,14 +78,42 @@ type App struct {
 	svc       service.Service // 程序注册的系统服务
 	startFunc func()          // 程序启动执行的方法
 	Console   *cobra.Command  // 命令行调用程序
+
+	stage           *Stage        // 停止时关闭 stage 中注册的服务
+	shutdownTimeout time.Duration // 停止服务的最长时间
+	env             string        // --env 参数，覆盖 APP_ENV
 }
 
+// Start 先执行 stage 中已添加的 OnStart 钩子，再在协程中执行 startFunc
+// startFunc 通常会阻塞（例如 http 服务），其中添加的钩子在添加时立即执行 OnStart
 func (i *App) Start(s service.Service) error {
+	if i.stage != nil {
+		if err := i.stage.Lifecycle().Start(context.Background()); err != nil {
+			log.PrintLnRed(i.name+"启动失败: ", err)
+			return err
+		}
+	}
 	go i.startFunc()
 	return nil
 }
+
+// Stop 收到停止信号（systemd 发送 SIGTERM）时，在 shutdownTimeout 内停止所有服务
 func (i *App) Stop(s service.Service) error {
-	return nil
+	if i.stage == nil {
+		return nil
+	}
+	err := i.stage.Lifecycle().Shutdown(i.shutdownTimeout)
+	if err != nil {
+		log.PrintLnRed(i.name+"停止失败: ", err)
+	}
+	return err
+}
+
+// WithStage 替换 NewApp 传入的 stage 并设置停止服务的最长时间，timeout <= 0 时使用 DefaultShutdownTimeout
+func (i *App) WithStage(stage *Stage, timeout time.Duration) *App {
+	i.stage = stage
+	i.shutdownTimeout = timeout
+	return i
 }
 
 // AddApp 增加app
@@ -101,7 +132,10 @@ func (i *App) Run() {
 		}
 	}
 
-	i.Console.Execute()
+	// 命令失败时以状态码 1 退出，部署脚本可以根据 config:check 的结果中止
+	if err := i.Console.Execute(); err != nil {
+		os.Exit(1)
+	}
 }
 
 // install 安装应用
@@ -144,7 +178,12 @@ func (i *App) control(command string) error {
 	return err
 }
 
-func NewApp(binName, name, description string, killMode KillMode, startFunc func()) *App {
+// NewApp 创建应用，startFunc 为 nil 时只注册命令行，不注册系统服务
+// 启动时执行 stage 中的 OnStart 钩子，收到停止信号时执行 OnStop 钩子，关闭 http 服务、停止消费者、关闭连接池；
+// stage 为 nil 时 Stop 不执行任何钩子
+//
+//	owl.NewApp("demo", "demo", "示例", owl.KillAll, stage, start).Run()
+func NewApp(binName, name, description string, killMode KillMode, stage *Stage, startFunc func()) *App {
 	cmd := &SystemCtl{
 		Command: &cobra.Command{
 			Use:   binName,
@@ -158,8 +197,31 @@ func NewApp(binName, name, description string, killMode KillMode, startFunc func
 		description: description,
 		startFunc:   startFunc,
 		Console:     cmd.Command,
+		stage:       stage,
+	}
+
+	// 系统服务中通过 /etc/sysconfig/{name} 中的 APP_ENV 指定环境
+	cmd.PersistentFlags().StringVar(&app.env, "env", "", "运行环境，覆盖 APP_ENV，例如 production 时 conf/app.production.yml 覆盖 conf/app.yml")
+	cmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		if app.env == "" {
+			return nil
+		}
+		if err := os.Setenv(AppEnvKey, app.env); err != nil {
+			return err
+		}
+		// ConfManager 可能在解析参数前已经创建，读取的是之前的 APP_ENV
+		if app.stage == nil {
+			return nil
+		}
+		var err error
+		_ = app.stage.Container.Invoke(func(cfgManager *ConfManager) {
+			err = cfgManager.SetEnvironment(app.env)
+		})
+		return err
 	}
 
+	cmd.regConfigCmd(app) // 不启动服务检查配置
+
 	if startFunc != nil {
 
 		cmd.regCtlCmd(app) // 注册基本的命令行命令
@@ -1,15 +1,22 @@
 package web_server
 
 import (
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"net"
 	"owl"
 	"owl/log"
 )
 
+func init() {
+	// config:check 校验 conf/app 中的 http https，和 NewHttpOptionFromConfigFile NewHttpsOptionFromConfigFile 一样使用 app 合并 app.http app.https 的配置
+	owl.RegisterConfigSchema[HttpOptions]("app.http", "app")
+	owl.RegisterConfigSchema[HttpsOptions]("app.https", "app")
+}
+
 type HttpOptions struct {
 	*WebServerOptions
-	Port int `json:"port"`
+	Port int `json:"port" default:"80" validate:"max=65535"`
 }
 
 func NewHttpOptionFromConfigFile(cfgManager *owl.ConfManager, cfgFile string) (opt *HttpOptions) {
@@ -61,14 +68,11 @@ func (i *HttpService) BlockRun() {
 	server, listener := i.getServerAndListener(i.opt.Port)
 	i.opt.Port = listener.Addr().(*net.TCPAddr).Port
 	log.PrintLnBlue("http server start on port:", i.opt.Port)
-	go func() {
-
-		err := server.Serve(listener)
-		if err != nil {
-			log.PrintRed("http server start on port:", i.opt.Port)
-		}
-	}()
+	i.serve(fmt.Sprintf("http:%d", i.opt.Port), server, func() error {
+		return server.Serve(listener)
+	})
 }
+
 func (i *HttpService) GetOptions() *HttpOptions {
 	return i.opt
 }
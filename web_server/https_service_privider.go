@@ -18,13 +18,18 @@ import (
 
 type HttpsOptions struct {
 	*WebServerOptions
-	Port     int    `json:"port"`
+	Port     int    `json:"port" default:"443" validate:"max=65535"`
 	KeyFile  string `json:"key-file"`
 	CertFile string `json:"cert-file"`
 }
 
+// NewHttpsOptionFromConfigFile 和 http 一样，file 中的公共配置（max-cons mode 等）被 file.https 中的配置覆盖
 func NewHttpsOptionFromConfigFile(cfgManager *owl.ConfManager, file string) (opt *HttpsOptions) {
-	err := cfgManager.GetConfig(file+".https", &opt)
+	err := cfgManager.GetConfig(file, &opt)
+	if err != nil {
+		return nil
+	}
+	err = cfgManager.GetConfig(file+".https", &opt)
 	if err != nil {
 		return nil
 	}
@@ -79,14 +84,10 @@ func (i *HttpsService) BlockRun() {
 	keyFile := key
 	certFile := cert
 
-	go func() {
-
-		server, listener := i.getServerAndListener(httpsPort)
-		err := server.ServeTLS(listener, certFile, keyFile) // 启动 HTTPS 服务器
-		if err != nil {
-			log.Fatal("Failed to start server: ", err)
-		}
-	}()
+	server, listener := i.getServerAndListener(httpsPort)
+	i.serve(fmt.Sprintf("https:%d", httpsPort), server, func() error {
+		return server.ServeTLS(listener, certFile, keyFile) // 启动 HTTPS 服务器
+	})
 }
 
 func (i *HttpsService) GetOptions() *HttpsOptions {
@@ -0,0 +1,33 @@
+package web_server
+
+import (
+	"os"
+	"owl"
+	"path/filepath"
+	"testing"
+)
+
+func TestOptionsFromAppStub(t *testing.T) {
+	content, err := os.ReadFile("../config/app.stub.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err = os.WriteFile(filepath.Join(dir, "app.yml"), content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfgManager := owl.NewConfigManagerFromDir(dir, nil)
+
+	https := NewHttpsOptionFromConfigFile(cfgManager, "app")
+	if https == nil || https.Port != 443 || https.KeyFile != "./certs/self-signed-key.pem" || https.CertFile != "./certs/self-signed-cert.pem" {
+		t.Fatalf("https options = %+v", https)
+	}
+	if https.WebServerOptions == nil || https.MaxCons != 1024 || https.Mode != "release" {
+		t.Fatalf("https should merge the app options: %+v", https.WebServerOptions)
+	}
+
+	http := NewHttpOptionFromConfigFile(cfgManager, "app")
+	if http == nil || http.Port != 80 || http.WebServerOptions == nil || http.MaxCons != 1024 {
+		t.Fatalf("http options = %+v", http)
+	}
+}
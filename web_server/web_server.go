@@ -1,22 +1,24 @@
 package web_server
 
 import (
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"golang.org/x/net/netutil"
 	"net"
 	"net/http"
 	"owl"
+	"owl/log"
 	"time"
 )
 
 type WebServerOptions struct {
 	Domain       string `json:"domain"`
-	MaxCons      int    `json:"max-cons"`
-	ReadTimeout  int    `json:"read-timeout"`
-	WriteTimeout int    `json:"write-timeout"`
-	IdleTimeout  int    `json:"idle-timeout"`
-	Mode         string `json:"mode"`
+	MaxCons      int    `json:"max-cons" default:"1024" validate:"min=1"`
+	ReadTimeout  int    `json:"read-timeout" default:"100" validate:"min=0"`  // 分钟
+	WriteTimeout int    `json:"write-timeout" default:"100" validate:"min=0"` // 分钟
+	IdleTimeout  int    `json:"idle-timeout" default:"100" validate:"min=0"`
+	Mode         string `json:"mode" default:"release" validate:"oneof=debug release test"`
 }
 
 type WebServer struct {
@@ -61,6 +63,21 @@ func (i *WebServer) getServerAndListener(port int) (*http.Server, net.Listener)
 	return server, listener
 }
 
+// serve 启动服务，并在应用停止时调用 http.Server.Shutdown，等待处理中的请求完成
+func (i *WebServer) serve(name string, server *http.Server, run func() error) {
+	if i.stage != nil {
+		if err := i.stage.Lifecycle().OnStop(name, server.Shutdown); err != nil {
+			log.PrintLnRed(err)
+			return
+		}
+	}
+	go func() {
+		if err := run(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.PrintLnRed(name+" 启动失败: ", err)
+		}
+	}()
+}
+
 func (i *WebServer) Use(middlewares ...gin.HandlerFunc) {
 	for _, middleware := range middlewares {
 		i.e.Use(middleware)